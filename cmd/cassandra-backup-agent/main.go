@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/camilocot/cassandra-operator/pkg/backupagent"
+	"github.com/camilocot/cassandra-operator/pkg/util/s3"
+
+	"github.com/sirupsen/logrus"
+)
+
+func printVersion() {
+	logrus.Infof("Go Version: %s", runtime.Version())
+	logrus.Infof("Go OS/Arch: %s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+func main() {
+	printVersion()
+
+	client, err := s3.New(os.Getenv(backupagent.EnvEndpoint), os.Getenv(backupagent.EnvRegion),
+		os.Getenv(backupagent.EnvAccessKeyID), os.Getenv(backupagent.EnvSecretAccessKey))
+	if err != nil {
+		logrus.Fatalf("Failed to create the storage client: %v", err)
+	}
+	intervalSeconds, err := strconv.Atoi(os.Getenv(backupagent.EnvIntervalSeconds))
+	if err != nil || intervalSeconds <= 0 {
+		logrus.Fatalf("Invalid %s: %q", backupagent.EnvIntervalSeconds, os.Getenv(backupagent.EnvIntervalSeconds))
+	}
+
+	agent := &backupagent.Agent{
+		Uploader:                  client,
+		Bucket:                    os.Getenv(backupagent.EnvBucket),
+		Prefix:                    os.Getenv(backupagent.EnvPrefix),
+		CommitlogArchiveDirectory: os.Getenv(backupagent.EnvCommitlogArchiveDirectory),
+		RecoveryPointFile:         os.Getenv(backupagent.EnvRecoveryPointFile),
+	}
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	logrus.Infof("Shipping to %s/%s every %ds", agent.Bucket, agent.Prefix, intervalSeconds)
+	agent.Run(time.Duration(intervalSeconds)*time.Second, stop)
+}
@@ -26,7 +26,7 @@ func main() {
 	printVersion()
 
 	resource := "database.camilocot/v1alpha1"
-	kind := "Cassandra"
+	kinds := []string{"Cassandra", "CassandraBackup", "CassandraBackupSchedule", "CassandraRestore", "CassandraKeyspace", "CassandraRole", "CassandraGrant"}
 	namespace, err := k8sutil.GetWatchNamespace()
 	if err != nil {
 		logrus.Fatalf("Failed to get watch namespace: %v", err)
@@ -35,8 +35,10 @@ func main() {
 	http.HandleFunc(probe.HTTPReadyzEndpoint, probe.ReadyzHandler)
 	http.Handle("/metrics", prometheus.Handler())
 	go http.ListenAndServe(listenAddr, nil)
-	logrus.Infof("Watching %s, %s, %s, %d", resource, kind, namespace, resyncPeriod)
-	sdk.Watch(resource, kind, namespace, resyncPeriod)
+	for _, kind := range kinds {
+		logrus.Infof("Watching %s, %s, %s, %d", resource, kind, namespace, resyncPeriod)
+		sdk.Watch(resource, kind, namespace, resyncPeriod)
+	}
 	sdk.Handle(stub.NewHandler())
 	sdk.Run(context.TODO())
 }
@@ -0,0 +1,231 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BackupPhase represents the status of a backup
+type BackupPhase string
+
+const (
+	// BackupPhaseRunning represents a backup being taken
+	BackupPhaseRunning BackupPhase = "Running"
+	// BackupPhaseCompleted represents a backup uploaded from every node
+	BackupPhaseCompleted BackupPhase = "Completed"
+	// BackupPhaseFailed represents a failed backup
+	BackupPhaseFailed BackupPhase = "Failed"
+
+	// AccessKeyIDKey is the key of the access key ID in the storage credentials secret
+	AccessKeyIDKey = "accessKeyId"
+	// SecretAccessKeyKey is the key of the secret access key in the storage credentials secret
+	SecretAccessKeyKey = "secretAccessKey"
+
+	// BackupScheduleLabel is the label with the name of the schedule that created a backup
+	BackupScheduleLabel = "database.camilocot/backup-schedule"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CassandraBackupList is a list of Cassandra backups
+type CassandraBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata
+	// More info: https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#metadata
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CassandraBackup `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CassandraBackup represents a backup of a Cassandra cluster to object storage
+type CassandraBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              CassandraBackupSpec   `json:"spec"`
+	Status            CassandraBackupStatus `json:"status,omitempty"`
+}
+
+// CassandraBackupSpec contains the specification of a backup
+type CassandraBackupSpec struct {
+	// Cluster is the name of the Cassandra cluster backed up, in the same namespace
+	Cluster string `json:"cluster"`
+	// Keyspaces are the keyspaces backed up.
+	//
+	// If keyspaces is not set, all the keyspaces but the system ones are backed up.
+	Keyspaces []string `json:"keyspaces,omitempty"`
+	// Storage is where the backup is uploaded
+	Storage BackupStorage `json:"storage"`
+}
+
+// BackupStorage is an S3 compatible object storage
+type BackupStorage struct {
+	// Endpoint is the URL of the object storage, e.g. https://s3.amazonaws.com or http://minio:9000
+	Endpoint string `json:"endpoint"`
+	// Region is the region of the bucket.
+	//
+	// If region is not set, default is us-east-1.
+	Region string `json:"region,omitempty"`
+	// Bucket is the bucket the backups are stored in
+	Bucket string `json:"bucket"`
+	// Prefix is prepended to the key of every object
+	Prefix string `json:"prefix,omitempty"`
+	// CredentialsSecret is the name of the secret with the accessKeyId and
+	// secretAccessKey of the object storage
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// CassandraBackupStatus represents the current state of a backup
+type CassandraBackupStatus struct {
+	// Phase is the state of the backup
+	Phase BackupPhase `json:"phase,omitempty"`
+	// Reason is why the backup failed
+	Reason string `json:"reason,omitempty"`
+	// Name is the name of the backup in the object storage and of its snapshots
+	Name string `json:"name,omitempty"`
+	// Location is the URL of the backup in the object storage
+	Location string `json:"location,omitempty"`
+	// Schema is the key of the schema of the cluster
+	Schema string `json:"schema,omitempty"`
+	// Snapshotted is true once the snapshot of the backup is taken on every node
+	Snapshotted bool `json:"snapshotted,omitempty"`
+	// StartedAt is the time the backup was started
+	StartedAt string `json:"startedAt,omitempty"`
+	// CompletedAt is the time the backup completed or failed
+	CompletedAt string `json:"completedAt,omitempty"`
+	// Size is the number of bytes uploaded
+	Size int64 `json:"size,omitempty"`
+	// Nodes are the nodes uploaded
+	Nodes []BackupNode `json:"nodes,omitempty"`
+}
+
+// BackupNode represents the backup of a node
+type BackupNode struct {
+	// Node is the name of the pod
+	Node string `json:"node"`
+	// HostID is the host ID of the node in the ring
+	HostID string `json:"hostID"`
+	// Manifest is the key of the manifest listing the files and tokens of the node
+	Manifest string `json:"manifest"`
+	// Files is the number of files uploaded
+	Files int32 `json:"files"`
+	// Size is the number of bytes uploaded
+	Size int64 `json:"size"`
+}
+
+// Validate returns an error if the backup specification is not valid
+func (bs *CassandraBackupSpec) Validate() error {
+	if len(bs.Cluster) == 0 {
+		return fmt.Errorf("backup cluster is required")
+	}
+	for _, k := range bs.Keyspaces {
+		if !keyspaceNameRegexp.MatchString(k) {
+			return fmt.Errorf("invalid keyspace name %q", k)
+		}
+	}
+	return bs.Storage.Validate()
+}
+
+// Validate returns an error if the storage specification is not valid
+func (s *BackupStorage) Validate() error {
+	if len(s.Endpoint) == 0 {
+		return fmt.Errorf("storage endpoint is required")
+	}
+	if len(s.Bucket) == 0 {
+		return fmt.Errorf("storage bucket is required")
+	}
+	if len(s.CredentialsSecret) == 0 {
+		return fmt.Errorf("storage credentials secret is required")
+	}
+	return nil
+}
+
+// Key returns the key of an object of the storage, under its prefix
+func (s *BackupStorage) Key(elem ...string) string {
+	if len(s.Prefix) > 0 {
+		elem = append([]string{strings.Trim(s.Prefix, "/")}, elem...)
+	}
+	return strings.Join(elem, "/")
+}
+
+// IsFinished returns if the backup completed or failed
+func (bs *CassandraBackupStatus) IsFinished() bool {
+	return bs.Phase == BackupPhaseCompleted || bs.Phase == BackupPhaseFailed
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CassandraBackupScheduleList is a list of Cassandra backup schedules
+type CassandraBackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata
+	// More info: https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#metadata
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CassandraBackupSchedule `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CassandraBackupSchedule represents backups of a Cassandra cluster taken on a schedule
+type CassandraBackupSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              CassandraBackupScheduleSpec   `json:"spec"`
+	Status            CassandraBackupScheduleStatus `json:"status,omitempty"`
+}
+
+// CassandraBackupScheduleSpec contains the specification of a backup schedule
+type CassandraBackupScheduleSpec struct {
+	// Schedule is the cron expression of the backups, in UTC
+	Schedule string `json:"schedule"`
+	// CassandraBackupSpec is the specification of the backups created
+	CassandraBackupSpec `json:",inline"`
+	// Retention is how long the backups are kept
+	Retention BackupRetention `json:"retention,omitempty"`
+}
+
+// BackupRetention describes which backups of a schedule are kept. The last
+// completed backup is always kept
+type BackupRetention struct {
+	// Count is the number of completed backups kept, 0 keeps all
+	Count int32 `json:"count,omitempty"`
+	// MaxAgeSeconds is the age of the completed backups deleted, 0 keeps all
+	MaxAgeSeconds int64 `json:"maxAgeSeconds,omitempty"`
+}
+
+// CassandraBackupScheduleStatus represents the current state of a backup schedule
+type CassandraBackupScheduleStatus struct {
+	// LastScheduleTime is the schedule time of the last backup created
+	LastScheduleTime string `json:"lastScheduleTime,omitempty"`
+	// Active is the name of the backup running
+	Active string `json:"active,omitempty"`
+	// LastSuccessfulBackup is the last backup completed
+	LastSuccessfulBackup *ScheduledBackup `json:"lastSuccessfulBackup,omitempty"`
+	// LastFailedBackup is the last backup failed
+	LastFailedBackup *ScheduledBackup `json:"lastFailedBackup,omitempty"`
+}
+
+// ScheduledBackup represents a backup created by a schedule
+type ScheduledBackup struct {
+	// Name is the name of the CassandraBackup
+	Name string `json:"name"`
+	// StartedAt is the time the backup was started
+	StartedAt string `json:"startedAt,omitempty"`
+	// CompletedAt is the time the backup completed or failed
+	CompletedAt string `json:"completedAt,omitempty"`
+	// Reason is why the backup failed
+	Reason string `json:"reason,omitempty"`
+}
+
+// Validate returns an error if the backup schedule specification is not valid
+func (ss *CassandraBackupScheduleSpec) Validate() error {
+	if len(ss.Schedule) == 0 {
+		return fmt.Errorf("backup schedule is required")
+	}
+	if ss.Retention.Count < 0 || ss.Retention.MaxAgeSeconds < 0 {
+		return fmt.Errorf("backup retention cannot be negative")
+	}
+	return ss.CassandraBackupSpec.Validate()
+}
@@ -0,0 +1,182 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Permission is a permission granted on a keyspace or table
+type Permission string
+
+const (
+	// PermissionAll is every permission applicable to the resource
+	PermissionAll Permission = "ALL"
+	// PermissionCreate allows to create keyspaces and tables
+	PermissionCreate Permission = "CREATE"
+	// PermissionAlter allows to alter keyspaces and tables
+	PermissionAlter Permission = "ALTER"
+	// PermissionDrop allows to drop keyspaces and tables
+	PermissionDrop Permission = "DROP"
+	// PermissionSelect allows to read the tables
+	PermissionSelect Permission = "SELECT"
+	// PermissionModify allows to write the tables
+	PermissionModify Permission = "MODIFY"
+	// PermissionAuthorize allows to grant and revoke permissions on the resource
+	PermissionAuthorize Permission = "AUTHORIZE"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CassandraGrantList is a list of Cassandra grants
+type CassandraGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata
+	// More info: https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#metadata
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CassandraGrant `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CassandraGrant represents the permissions of a role on a keyspace or table
+type CassandraGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              CassandraGrantSpec   `json:"spec"`
+	Status            CassandraGrantStatus `json:"status,omitempty"`
+}
+
+// CassandraGrantSpec contains the specification of the permissions of a role. The
+// permissions of the role on the resource that are not listed are revoked
+type CassandraGrantSpec struct {
+	// Cluster is the name of the Cassandra cluster of the role, in the same namespace
+	Cluster string `json:"cluster"`
+	// Role is the name of the role the permissions are granted to
+	Role string `json:"role"`
+	// Permissions are the permissions granted: ALL, CREATE, ALTER, DROP, SELECT,
+	// MODIFY or AUTHORIZE. CREATE is not applicable to a table
+	Permissions []Permission `json:"permissions"`
+	// Keyspace is the keyspace the permissions are granted on.
+	//
+	// If keyspace is not set, the permissions are granted on all keyspaces.
+	Keyspace string `json:"keyspace,omitempty"`
+	// Table is the table of the keyspace the permissions are granted on.
+	//
+	// If table is not set, the permissions are granted on the keyspace.
+	Table string `json:"table,omitempty"`
+}
+
+// CassandraGrantStatus represents the current state of the permissions of a role
+type CassandraGrantStatus struct {
+	// Resource is the resource the permissions are granted on, as in system_auth
+	Resource string `json:"resource,omitempty"`
+	// Permissions are the permissions of the role on the resource in the cluster
+	Permissions []Permission `json:"permissions,omitempty"`
+	// Synced is true when the permissions in the cluster match the spec
+	Synced bool `json:"synced"`
+	// Reason is why the permissions could not be reconciled
+	Reason string `json:"reason,omitempty"`
+}
+
+// Resource returns the resource the permissions are granted on, as in system_auth
+func (g *CassandraGrant) Resource() string {
+	elem := []string{"data"}
+	if len(g.Spec.Keyspace) > 0 {
+		elem = append(elem, g.Spec.Keyspace)
+	}
+	if len(g.Spec.Table) > 0 {
+		elem = append(elem, g.Spec.Table)
+	}
+	return strings.Join(elem, "/")
+}
+
+// ApplicablePermissions returns the permissions that can be granted on the resource
+func (g *CassandraGrant) ApplicablePermissions() []Permission {
+	if len(g.Spec.Table) > 0 {
+		return []Permission{PermissionAlter, PermissionDrop, PermissionSelect, PermissionModify, PermissionAuthorize}
+	}
+	return []Permission{PermissionCreate, PermissionAlter, PermissionDrop, PermissionSelect, PermissionModify, PermissionAuthorize}
+}
+
+// DesiredPermissions returns the sorted permissions of the spec, with ALL expanded to
+// every permission applicable to the resource
+func (g *CassandraGrant) DesiredPermissions() []Permission {
+	set := map[Permission]bool{}
+	for _, p := range g.Spec.Permissions {
+		if p == PermissionAll {
+			for _, a := range g.ApplicablePermissions() {
+				set[a] = true
+			}
+		} else {
+			set[p] = true
+		}
+	}
+	return SortPermissions(set)
+}
+
+// SortPermissions returns the permissions of the set sorted by name
+func SortPermissions(set map[Permission]bool) []Permission {
+	permissions := []Permission{}
+	for p := range set {
+		permissions = append(permissions, p)
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i] < permissions[j] })
+	return permissions
+}
+
+// Validate returns an error if the grant specification is not valid
+func (g *CassandraGrant) Validate() error {
+	gs := &g.Spec
+	if len(gs.Cluster) == 0 {
+		return fmt.Errorf("grant cluster is required")
+	}
+	if len(gs.Role) == 0 {
+		return fmt.Errorf("grant role is required")
+	}
+	if len(gs.Permissions) == 0 {
+		return fmt.Errorf("grant permissions are required")
+	}
+	if len(gs.Keyspace) > 0 && !keyspaceNameRegexp.MatchString(gs.Keyspace) {
+		return fmt.Errorf("invalid keyspace name %q", gs.Keyspace)
+	}
+	if len(gs.Table) > 0 && len(gs.Keyspace) == 0 {
+		return fmt.Errorf("the keyspace of table %v is required", gs.Table)
+	}
+	if len(gs.Table) > 0 && !keyspaceNameRegexp.MatchString(gs.Table) {
+		return fmt.Errorf("invalid table name %q", gs.Table)
+	}
+	applicable := append(g.ApplicablePermissions(), PermissionAll)
+	for _, p := range gs.Permissions {
+		found := false
+		for _, a := range applicable {
+			found = found || p == a
+		}
+		if !found {
+			return fmt.Errorf("permission %q cannot be granted on %v", p, g.Resource())
+		}
+	}
+	return nil
+}
+
+// HasFinalizer returns if the cassandra finalizer is set
+func (g *CassandraGrant) HasFinalizer() bool {
+	return hasFinalizer(&g.ObjectMeta)
+}
+
+// AddFinalizer adds the cassandra finalizer, returns false if it was already set
+func (g *CassandraGrant) AddFinalizer() bool {
+	return addFinalizer(&g.ObjectMeta)
+}
+
+// RemoveFinalizer removes the cassandra finalizer, returns false if it was not set
+func (g *CassandraGrant) RemoveFinalizer() bool {
+	return removeFinalizer(&g.ObjectMeta)
+}
+
+// IsBeingDeleted returns if the CassandraGrant object has been marked for deletion
+func (g *CassandraGrant) IsBeingDeleted() bool {
+	return g.DeletionTimestamp != nil
+}
@@ -0,0 +1,202 @@
+package v1alpha1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReplicationStrategy is the replication strategy of a keyspace
+type ReplicationStrategy string
+
+// KeyspaceDeletionPolicy describes what is done with the keyspace when the
+// CassandraKeyspace object is deleted
+type KeyspaceDeletionPolicy string
+
+const (
+	// SimpleStrategy places the replicas on the next nodes of the ring
+	SimpleStrategy ReplicationStrategy = "SimpleStrategy"
+	// NetworkTopologyStrategy places the replicas of every datacenter on distinct racks
+	NetworkTopologyStrategy ReplicationStrategy = "NetworkTopologyStrategy"
+
+	// KeyspaceDeletionPolicyDelete drops the keyspace when it has no data, the
+	// deletion is blocked otherwise
+	KeyspaceDeletionPolicyDelete KeyspaceDeletionPolicy = "Delete"
+	// KeyspaceDeletionPolicyForceDelete drops the keyspace even if it has data
+	KeyspaceDeletionPolicyForceDelete KeyspaceDeletionPolicy = "ForceDelete"
+	// KeyspaceDeletionPolicyRetain keeps the keyspace
+	KeyspaceDeletionPolicyRetain KeyspaceDeletionPolicy = "Retain"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CassandraKeyspaceList is a list of Cassandra keyspaces
+type CassandraKeyspaceList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata
+	// More info: https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#metadata
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CassandraKeyspace `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CassandraKeyspace represents a keyspace of a Cassandra cluster
+type CassandraKeyspace struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              CassandraKeyspaceSpec   `json:"spec"`
+	Status            CassandraKeyspaceStatus `json:"status,omitempty"`
+}
+
+// CassandraKeyspaceSpec contains the specification of a keyspace
+type CassandraKeyspaceSpec struct {
+	// Cluster is the name of the Cassandra cluster of the keyspace, in the same namespace
+	Cluster string `json:"cluster"`
+	// Name is the name of the keyspace.
+	//
+	// If name is not set, default is the name of the object.
+	Name string `json:"name,omitempty"`
+	// Strategy is the replication strategy, SimpleStrategy or NetworkTopologyStrategy.
+	//
+	// If strategy is not set, default is NetworkTopologyStrategy.
+	Strategy ReplicationStrategy `json:"strategy,omitempty"`
+	// ReplicationFactor is the replication factor of the SimpleStrategy
+	ReplicationFactor int32 `json:"replicationFactor,omitempty"`
+	// Datacenters are the replication factors of every datacenter of the
+	// NetworkTopologyStrategy
+	Datacenters map[string]int32 `json:"datacenters,omitempty"`
+	// DurableWrites writes the mutations of the keyspace to the commitlog.
+	//
+	// If durable writes is not set, default is true.
+	DurableWrites *bool `json:"durableWrites,omitempty"`
+	// DeletionPolicy is applied to the keyspace when the object is deleted.
+	// One of Delete, ForceDelete or Retain.
+	//
+	// If deletion policy is not set, default is Delete.
+	DeletionPolicy KeyspaceDeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// CassandraKeyspaceStatus represents the current state of a keyspace
+type CassandraKeyspaceStatus struct {
+	// Strategy is the replication strategy of the keyspace in the cluster
+	Strategy ReplicationStrategy `json:"strategy,omitempty"`
+	// Replication are the replication factors of the keyspace in the cluster, by
+	// datacenter or as replication_factor for the SimpleStrategy
+	Replication map[string]int32 `json:"replication,omitempty"`
+	// DurableWrites is the durable writes of the keyspace in the cluster
+	DurableWrites bool `json:"durableWrites,omitempty"`
+	// Synced is true when the keyspace in the cluster matches the spec
+	Synced bool `json:"synced"`
+	// DriftDetectedAt is the last time the keyspace was changed out of the operator
+	DriftDetectedAt string `json:"driftDetectedAt,omitempty"`
+	// Repair is the repair of the keyspace following a replication change
+	Repair *KeyspaceRepairRun `json:"repair,omitempty"`
+	// Reason is why the keyspace could not be reconciled or deleted
+	Reason string `json:"reason,omitempty"`
+}
+
+// KeyspaceRepairRun represents the repair of a keyspace on every node after its
+// replication changed
+type KeyspaceRepairRun struct {
+	// StartedAt is the time the run was started
+	StartedAt string `json:"startedAt"`
+	// Ordinal is the ordinal of the pod being repaired
+	Ordinal int32 `json:"ordinal"`
+	// Cleanup runs nodetool cleanup after the repair, when replicas were removed
+	Cleanup bool `json:"cleanup,omitempty"`
+	// Job is the name of the repair running in the pod, empty if none is running
+	Job string `json:"job,omitempty"`
+}
+
+// KeyspaceName returns the name of the keyspace
+func (k *CassandraKeyspace) KeyspaceName() string {
+	if len(k.Spec.Name) > 0 {
+		return k.Spec.Name
+	}
+	return k.Name
+}
+
+// SetDefaults sets the default values of the keyspace, returns if any was changed
+func (k *CassandraKeyspace) SetDefaults() bool {
+	changed := false
+	ks := &k.Spec
+	if len(ks.Strategy) == 0 {
+		ks.Strategy = NetworkTopologyStrategy
+		changed = true
+	}
+	if ks.DurableWrites == nil {
+		durableWrites := true
+		ks.DurableWrites = &durableWrites
+		changed = true
+	}
+	if len(ks.DeletionPolicy) == 0 {
+		ks.DeletionPolicy = KeyspaceDeletionPolicyDelete
+		changed = true
+	}
+	return changed
+}
+
+// Validate returns an error if the keyspace specification is not valid
+func (k *CassandraKeyspace) Validate() error {
+	ks := &k.Spec
+	if len(ks.Cluster) == 0 {
+		return fmt.Errorf("keyspace cluster is required")
+	}
+	if !keyspaceNameRegexp.MatchString(k.KeyspaceName()) {
+		return fmt.Errorf("invalid keyspace name %q, set a name with only alphanumeric characters and underscores", k.KeyspaceName())
+	}
+	switch ks.Strategy {
+	case SimpleStrategy:
+		if ks.ReplicationFactor < 1 {
+			return fmt.Errorf("replication factor is required by the %v", ks.Strategy)
+		}
+	case NetworkTopologyStrategy:
+		if len(ks.Datacenters) == 0 {
+			return fmt.Errorf("datacenters are required by the %v", ks.Strategy)
+		}
+		for dc, rf := range ks.Datacenters {
+			if rf < 0 {
+				return fmt.Errorf("invalid replication factor %v of datacenter %v", rf, dc)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid replication strategy %q", ks.Strategy)
+	}
+	return nil
+}
+
+// Replication returns the desired replication factors, by datacenter or as
+// replication_factor for the SimpleStrategy. Datacenters without replicas are left out
+func (k *CassandraKeyspace) Replication() map[string]int32 {
+	if k.Spec.Strategy == SimpleStrategy {
+		return map[string]int32{"replication_factor": k.Spec.ReplicationFactor}
+	}
+	replication := map[string]int32{}
+	for dc, rf := range k.Spec.Datacenters {
+		if rf > 0 {
+			replication[dc] = rf
+		}
+	}
+	return replication
+}
+
+// HasFinalizer returns if the cassandra finalizer is set
+func (k *CassandraKeyspace) HasFinalizer() bool {
+	return hasFinalizer(&k.ObjectMeta)
+}
+
+// AddFinalizer adds the cassandra finalizer, returns false if it was already set
+func (k *CassandraKeyspace) AddFinalizer() bool {
+	return addFinalizer(&k.ObjectMeta)
+}
+
+// RemoveFinalizer removes the cassandra finalizer, returns false if it was not set
+func (k *CassandraKeyspace) RemoveFinalizer() bool {
+	return removeFinalizer(&k.ObjectMeta)
+}
+
+// IsBeingDeleted returns if the CassandraKeyspace object has been marked for deletion
+func (k *CassandraKeyspace) IsBeingDeleted() bool {
+	return k.DeletionTimestamp != nil
+}
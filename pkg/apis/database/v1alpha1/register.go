@@ -31,6 +31,18 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&Cassandra{},
 		&CassandraList{},
+		&CassandraBackup{},
+		&CassandraBackupList{},
+		&CassandraBackupSchedule{},
+		&CassandraBackupScheduleList{},
+		&CassandraRestore{},
+		&CassandraRestoreList{},
+		&CassandraKeyspace{},
+		&CassandraKeyspaceList{},
+		&CassandraRole{},
+		&CassandraRoleList{},
+		&CassandraGrant{},
+		&CassandraGrantList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
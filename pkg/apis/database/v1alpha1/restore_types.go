@@ -0,0 +1,162 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RestorePhase represents the status of a restore
+type RestorePhase string
+
+// RestoreMethod represents how the backed up files are loaded in the cluster
+type RestoreMethod string
+
+const (
+	// RestorePhaseRunning represents a restore in progress
+	RestorePhaseRunning RestorePhase = "Running"
+	// RestorePhaseCompleted represents a restore loaded from every backed up node
+	RestorePhaseCompleted RestorePhase = "Completed"
+	// RestorePhaseFailed represents a failed restore
+	RestorePhaseFailed RestorePhase = "Failed"
+
+	// RestoreMethodRefresh moves the files of every backed up node to the node with the
+	// same tokens and runs nodetool refresh
+	RestoreMethodRefresh RestoreMethod = "Refresh"
+	// RestoreMethodSSTableLoader streams the files of every backed up node to the
+	// nodes owning their tokens with sstableloader, for any topology
+	RestoreMethodSSTableLoader RestoreMethod = "SSTableLoader"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CassandraRestoreList is a list of Cassandra restores
+type CassandraRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata
+	// More info: https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#metadata
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CassandraRestore `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CassandraRestore represents the restore of a backup into a Cassandra cluster
+type CassandraRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              CassandraRestoreSpec   `json:"spec"`
+	Status            CassandraRestoreStatus `json:"status,omitempty"`
+}
+
+// CassandraRestoreSpec contains the specification of a restore
+type CassandraRestoreSpec struct {
+	// Cluster is the name of the Cassandra cluster restored, in the same namespace
+	Cluster string `json:"cluster"`
+	// Backup is the name of the completed CassandraBackup restored
+	Backup string `json:"backup,omitempty"`
+	// Source is the backup restored when the CassandraBackup object is not available
+	Source *BackupSource `json:"source,omitempty"`
+	// Keyspaces are the keyspaces restored.
+	//
+	// If keyspaces is not set, all the keyspaces of the backup are restored.
+	Keyspaces []string `json:"keyspaces,omitempty"`
+	// ClusterSpec is the specification of the cluster created when it does not exist.
+	// Its size is the number of nodes of the backup, and every node gets the tokens of
+	// a backed up node
+	ClusterSpec *CassandraSpec `json:"clusterSpec,omitempty"`
+	// PointInTime is the time, in RFC3339, the cluster is restored to by replaying
+	// the commitlog segments archived by the continuous backup of the backed up
+	// cluster, in the same storage. It must be after the start of the backup.
+	//
+	// If point in time is not set, the cluster is restored to the backup.
+	PointInTime string `json:"pointInTime,omitempty"`
+}
+
+// BackupSource is a backup in an object storage
+type BackupSource struct {
+	// Storage is where the backup is stored
+	Storage BackupStorage `json:"storage"`
+	// Cluster is the name of the cluster backed up
+	Cluster string `json:"cluster"`
+	// Name is the name of the backup
+	Name string `json:"name"`
+}
+
+// CassandraRestoreStatus represents the current state of a restore
+type CassandraRestoreStatus struct {
+	// Phase is the state of the restore
+	Phase RestorePhase `json:"phase,omitempty"`
+	// Reason is why the restore failed
+	Reason string `json:"reason,omitempty"`
+	// Source is the backup restored
+	Source *BackupSource `json:"source,omitempty"`
+	// Provisioned is true when the cluster was created by the restore
+	Provisioned bool `json:"provisioned,omitempty"`
+	// SchemaRestored is true once the schema of the backup has been applied
+	SchemaRestored bool `json:"schemaRestored,omitempty"`
+	// Method is how the files are loaded in the cluster
+	Method RestoreMethod `json:"method,omitempty"`
+	// StartedAt is the time the restore was started
+	StartedAt string `json:"startedAt,omitempty"`
+	// CompletedAt is the time the restore completed or failed
+	CompletedAt string `json:"completedAt,omitempty"`
+	// Nodes are the backed up nodes restored
+	Nodes []RestoreNode `json:"nodes,omitempty"`
+	// Job is the name of the job loading the files of the node being restored
+	Job string `json:"job,omitempty"`
+}
+
+// RestoreNode represents the restore of a backed up node
+type RestoreNode struct {
+	// Node is the name of the backed up node
+	Node string `json:"node"`
+	// Manifest is the key of the manifest of the backed up node
+	Manifest string `json:"manifest"`
+	// Target is the pod the files of the node are loaded from
+	Target string `json:"target"`
+	// Restored is true once the files of the node have been loaded
+	Restored bool `json:"restored,omitempty"`
+	// ReplayStartedAt is the time the target was restarted to replay the commitlog
+	// segments of the node
+	ReplayStartedAt string `json:"replayStartedAt,omitempty"`
+	// Replayed is true once the commitlog segments of the node have been replayed
+	Replayed bool `json:"replayed,omitempty"`
+}
+
+// Validate returns an error if the restore specification is not valid
+func (rs *CassandraRestoreSpec) Validate() error {
+	if len(rs.Cluster) == 0 {
+		return fmt.Errorf("restore cluster is required")
+	}
+	if len(rs.Backup) == 0 && rs.Source == nil {
+		return fmt.Errorf("restore backup or source is required")
+	}
+	if rs.Source != nil {
+		if len(rs.Source.Cluster) == 0 || len(rs.Source.Name) == 0 {
+			return fmt.Errorf("restore source cluster and name are required")
+		}
+		err := rs.Source.Storage.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if len(rs.PointInTime) > 0 {
+		_, err := time.Parse(time.RFC3339, rs.PointInTime)
+		if err != nil {
+			return fmt.Errorf("invalid point in time %q: %v", rs.PointInTime, err)
+		}
+	}
+	for _, k := range rs.Keyspaces {
+		if !keyspaceNameRegexp.MatchString(k) {
+			return fmt.Errorf("invalid keyspace name %q", k)
+		}
+	}
+	return nil
+}
+
+// IsFinished returns if the restore completed or failed
+func (rs *CassandraRestoreStatus) IsFinished() bool {
+	return rs.Phase == RestorePhaseCompleted || rs.Phase == RestorePhaseFailed
+}
@@ -0,0 +1,111 @@
+package v1alpha1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CassandraRoleList is a list of Cassandra roles
+type CassandraRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata
+	// More info: https://github.com/kubernetes/community/blob/master/contributors/devel/api-conventions.md#metadata
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CassandraRole `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CassandraRole represents a role of a Cassandra cluster
+type CassandraRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              CassandraRoleSpec   `json:"spec"`
+	Status            CassandraRoleStatus `json:"status,omitempty"`
+}
+
+// CassandraRoleSpec contains the specification of a role
+type CassandraRoleSpec struct {
+	// Cluster is the name of the Cassandra cluster of the role, in the same namespace
+	Cluster string `json:"cluster"`
+	// Name is the name of the role.
+	//
+	// If name is not set, default is the name of the object.
+	Name string `json:"name,omitempty"`
+	// PasswordSecret is the name of the secret with the password of the role in its
+	// password key. The password of the role is changed when the secret changes
+	PasswordSecret string `json:"passwordSecret,omitempty"`
+	// Superuser grants every permission to the role
+	Superuser bool `json:"superuser,omitempty"`
+	// Login allows clients to log in with the role.
+	//
+	// If login is not set, default is true.
+	Login *bool `json:"login,omitempty"`
+}
+
+// CassandraRoleStatus represents the current state of a role
+type CassandraRoleStatus struct {
+	// Superuser is the superuser flag of the role in the cluster
+	Superuser bool `json:"superuser,omitempty"`
+	// Login is the login flag of the role in the cluster
+	Login bool `json:"login,omitempty"`
+	// PasswordSecretVersion is the resource version of the password secret last
+	// applied to the role
+	PasswordSecretVersion string `json:"passwordSecretVersion,omitempty"`
+	// PasswordChangedAt is the last time the password of the role was changed
+	PasswordChangedAt string `json:"passwordChangedAt,omitempty"`
+	// Reason is why the role could not be reconciled
+	Reason string `json:"reason,omitempty"`
+}
+
+// RoleName returns the name of the role
+func (r *CassandraRole) RoleName() string {
+	if len(r.Spec.Name) > 0 {
+		return r.Spec.Name
+	}
+	return r.Name
+}
+
+// SetDefaults sets the default values of the role, returns if any was changed
+func (r *CassandraRole) SetDefaults() bool {
+	if r.Spec.Login == nil {
+		login := true
+		r.Spec.Login = &login
+		return true
+	}
+	return false
+}
+
+// Validate returns an error if the role specification is not valid
+func (r *CassandraRole) Validate() error {
+	if len(r.Spec.Cluster) == 0 {
+		return fmt.Errorf("role cluster is required")
+	}
+	if *r.Spec.Login && len(r.Spec.PasswordSecret) == 0 {
+		return fmt.Errorf("password secret is required by roles that can log in")
+	}
+	return nil
+}
+
+// HasFinalizer returns if the cassandra finalizer is set
+func (r *CassandraRole) HasFinalizer() bool {
+	return hasFinalizer(&r.ObjectMeta)
+}
+
+// AddFinalizer adds the cassandra finalizer, returns false if it was already set
+func (r *CassandraRole) AddFinalizer() bool {
+	return addFinalizer(&r.ObjectMeta)
+}
+
+// RemoveFinalizer removes the cassandra finalizer, returns false if it was not set
+func (r *CassandraRole) RemoveFinalizer() bool {
+	return removeFinalizer(&r.ObjectMeta)
+}
+
+// IsBeingDeleted returns if the CassandraRole object has been marked for deletion
+func (r *CassandraRole) IsBeingDeleted() bool {
+	return r.DeletionTimestamp != nil
+}
@@ -25,8 +25,32 @@ const (
 	ClusterConditionAvailable ClusterConditionType = "Available"
 	// ClusterConditionScaling represents scaling cluster condition
 	ClusterConditionScaling = "Scaling"
+	// ClusterConditionPaused represents paused reconciliation cluster condition
+	ClusterConditionPaused = "Paused"
+	// ClusterConditionRestarting represents rolling restart cluster condition
+	ClusterConditionRestarting = "Restarting"
+	// ClusterConditionReplacing represents nodes replacing their lost previous host cluster condition
+	ClusterConditionReplacing = "Replacing"
+	// ClusterConditionRepairOverdue represents keyspaces not repaired in time cluster condition
+	ClusterConditionRepairOverdue = "RepairOverdue"
+	// ClusterConditionUncleanShutdown represents nodes stopped without being drained cluster condition
+	ClusterConditionUncleanShutdown = "UncleanShutdown"
+	// ClusterConditionCAExpiring represents the internode CA expiring within the renew window cluster condition
+	ClusterConditionCAExpiring = "CAExpiring"
+
+	// ShutdownDrained represents a node drained before being stopped
+	ShutdownDrained ShutdownType = "Drained"
+	// ShutdownUnclean represents a node stopped without being drained
+	ShutdownUnclean ShutdownType = "Unclean"
+	// ShutdownNone represents a node started for the first time
+	ShutdownNone ShutdownType = "None"
+	// ShutdownUnknown represents a node whose shutdown could not be determined
+	ShutdownUnknown ShutdownType = "Unknown"
 )
 
+// ShutdownType represents how a cassandra container was stopped
+type ShutdownType string
+
 // ClusterStatus represents the current status of the cluster
 type ClusterStatus struct {
 	// Phase is the cluster running phase
@@ -44,6 +68,176 @@ type ClusterStatus struct {
 	// TargetVersion is the version the cluster upgrading to.
 	// If the cluster is not upgrading, TargetVersion is empty.
 	TargetVersion string `json:"targetVersion"`
+	// RestartRequestedAt is the time of the last rolling restart requested by the
+	// operator, to load rotated certificates or broadcast a new external address
+	RestartRequestedAt string `json:"restartRequestedAt,omitempty"`
+	// Restart is the progress of the last rolling restart
+	Restart *RestartStatus `json:"restart,omitempty"`
+	// Repair is the state of the scheduled repairs
+	Repair *RepairStatus `json:"repair,omitempty"`
+	// RecoveryPoints are the recovery points of the continuous backup of every node
+	RecoveryPoints []NodeRecoveryPoint `json:"recoveryPoints,omitempty"`
+	// Auth is the progress of the authentication bootstrap
+	Auth *AuthStatus `json:"auth,omitempty"`
+	// TLS are the certificates of the cluster
+	TLS *TLSStatus `json:"tls,omitempty"`
+	// ExternalAddresses are the addresses of the nodes outside the kubernetes
+	// cluster, when external access is enabled
+	ExternalAddresses []ExternalAddress `json:"externalAddresses,omitempty"`
+}
+
+// ExternalAddress represents the address of a node outside the kubernetes cluster
+type ExternalAddress struct {
+	// Node is the cassandra pod name
+	Node string `json:"node"`
+	// Address is the broadcast_rpc_address of the node, empty until it is allocated
+	Address string `json:"address,omitempty"`
+	// Port is the port of CQL at the address
+	Port int32 `json:"port,omitempty"`
+}
+
+// TLSStatus represents the certificates of the cluster
+type TLSStatus struct {
+	// Client is the certificate of the nodes presented to the clients
+	Client *CertificateStatus `json:"client,omitempty"`
+	// CA is the certificate of the CA issuing the internode certificates
+	CA *CertificateStatus `json:"ca,omitempty"`
+	// Internode are the internode certificates of the nodes
+	Internode []CertificateStatus `json:"internode,omitempty"`
+	// CARotation is the progress of the replacement of the CA, while the nodes trust
+	// the previous one
+	CARotation *CARotationStatus `json:"caRotation,omitempty"`
+}
+
+// CARotationStatus represents the replacement of the CA issuing the internode
+// certificates. The nodes are restarted to trust both CAs, then to load the
+// certificates issued by the new CA, and the previous CA is dropped
+type CARotationStatus struct {
+	// PreviousCA is the certificate of the replaced CA, in PEM
+	PreviousCA string `json:"previousCA"`
+	// TrustedAt is when the rolling restart loading the truststore with both CAs
+	// was requested
+	TrustedAt string `json:"trustedAt,omitempty"`
+	// IssuedAt is when the rolling restart loading the certificates issued by the new
+	// CA was requested
+	IssuedAt string `json:"issuedAt,omitempty"`
+}
+
+// CertificateStatus represents a certificate in use by the nodes
+type CertificateStatus struct {
+	// Secret is the name of the secret of the certificate
+	Secret string `json:"secret"`
+	// Subject is the subject of the certificate
+	Subject string `json:"subject,omitempty"`
+	// NotAfter is the expiry time of the certificate
+	NotAfter string `json:"notAfter,omitempty"`
+	// Checksum is the checksum of the secret the keystores were converted from, the
+	// nodes are restarted when it changes
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// AuthStatus represents the progress of the authentication bootstrap of the cluster.
+// Every step is checked against the cluster, so it is resumed where it was left
+type AuthStatus struct {
+	// AdminRoleCreated is true once the cassandra-operator logs in with the admin role
+	AdminRoleCreated bool `json:"adminRoleCreated,omitempty"`
+	// SystemAuthReplication are the replication factors of system_auth by datacenter
+	SystemAuthReplication map[string]int32 `json:"systemAuthReplication,omitempty"`
+	// Repair is the repair of system_auth following a replication change
+	Repair *KeyspaceRepairRun `json:"repair,omitempty"`
+	// DefaultSuperuserDisabled is true once the default cassandra superuser can
+	// neither log in nor is a superuser
+	DefaultSuperuserDisabled bool `json:"defaultSuperuserDisabled,omitempty"`
+}
+
+// IsAdminRoleCreated returns if the cassandra-operator logs in with the admin role
+func (as *AuthStatus) IsAdminRoleCreated() bool {
+	return as != nil && as.AdminRoleCreated
+}
+
+// NodeRecoveryPoint represents how far the continuous backup of a node has shipped
+type NodeRecoveryPoint struct {
+	// Node is the cassandra pod name
+	Node string `json:"node"`
+	// RecoveryPoint is the start time of the last shipment of every pending file,
+	// empty if the node has not shipped yet
+	RecoveryPoint string `json:"recoveryPoint,omitempty"`
+	// LagSeconds is the time since the recovery point
+	LagSeconds int64 `json:"lagSeconds,omitempty"`
+}
+
+// RepairStatus represents the state of the scheduled repairs of the cluster
+type RepairStatus struct {
+	// LastScheduleTime is the schedule time of the last repair run started
+	LastScheduleTime string `json:"lastScheduleTime,omitempty"`
+	// Run is the repair run in progress
+	Run *RepairRun `json:"run,omitempty"`
+	// Keyspaces is the last successful repair of every keyspace
+	Keyspaces []KeyspaceRepair `json:"keyspaces,omitempty"`
+}
+
+// RepairRun represents the progress of a repair of all the keyspaces on every node
+type RepairRun struct {
+	// StartedAt is the time the run was started
+	StartedAt string `json:"startedAt"`
+	// Ordinal is the ordinal of the pod being repaired
+	Ordinal int32 `json:"ordinal"`
+	// Keyspace is the keyspace being repaired
+	Keyspace string `json:"keyspace"`
+	// Job is the name of the repair running in the pod, empty if none is running
+	Job string `json:"job,omitempty"`
+	// Failed are the keyspaces whose repair failed on any node
+	Failed []string `json:"failed,omitempty"`
+}
+
+// KeyspaceRepair represents the last successful repair of a keyspace
+type KeyspaceRepair struct {
+	// Keyspace is the name of the keyspace
+	Keyspace string `json:"keyspace"`
+	// LastSuccessfulRepair is the start time of the last run that repaired
+	// the keyspace on every node
+	LastSuccessfulRepair string `json:"lastSuccessfulRepair"`
+}
+
+// LastSuccessfulRepair returns the last successful repair of a keyspace, empty if it never was
+func (rs *RepairStatus) LastSuccessfulRepair(keyspace string) string {
+	for _, k := range rs.Keyspaces {
+		if k.Keyspace == keyspace {
+			return k.LastSuccessfulRepair
+		}
+	}
+	return ""
+}
+
+// SetLastSuccessfulRepair records the last successful repair of a keyspace
+func (rs *RepairStatus) SetLastSuccessfulRepair(keyspace, t string) {
+	for i := range rs.Keyspaces {
+		if rs.Keyspaces[i].Keyspace == keyspace {
+			rs.Keyspaces[i].LastSuccessfulRepair = t
+			return
+		}
+	}
+	rs.Keyspaces = append(rs.Keyspaces, KeyspaceRepair{Keyspace: keyspace, LastSuccessfulRepair: t})
+}
+
+// RestartStatus represents the progress of a rolling restart of the cluster
+type RestartStatus struct {
+	// RequestedAt is the restartRequestedAt value of the restart, of the spec or of
+	// the status
+	RequestedAt string `json:"requestedAt"`
+	// Ordinal is the lowest ordinal of the pods restarted so far. The pod with
+	// the previous ordinal is restarted once this one is ready and up in the ring
+	Ordinal int32 `json:"ordinal"`
+	// Completed is true when all the pods have been restarted
+	Completed bool `json:"completed,omitempty"`
+}
+
+// IsInProgress returns if the restart requested at requestedAt is not completed
+func (rs *RestartStatus) IsInProgress(requestedAt string) bool {
+	if rs == nil {
+		return false
+	}
+	return rs.RequestedAt == requestedAt && !rs.Completed
 }
 
 // ClusterCondition represents one current condition of an cassandra cluster.
@@ -70,6 +264,66 @@ type ClusterCondition struct {
 type MembersStatus struct {
 	// The nodes names are the same as the cassandra pod names
 	Nodes []string `json:"nodes,omitempty"`
+	// Shutdowns is how the previous container of every node was stopped
+	Shutdowns []NodeShutdown `json:"shutdowns,omitempty"`
+	// Hosts is the last identity in the ring of every node seen up and normal
+	Hosts []MemberHost `json:"hosts,omitempty"`
+	// Replacing are the nodes replacing their previous host after losing their volume
+	Replacing []string `json:"replacing,omitempty"`
+	// Dead are the hosts down in the ring whose pod or volume are gone
+	Dead []DeadHost `json:"dead,omitempty"`
+}
+
+// DeadHost represents a host down in the ring whose pod or volume are gone
+type DeadHost struct {
+	// HostID is the host ID of the node in the ring
+	HostID string `json:"hostID"`
+	// Address is the address of the node in the ring
+	Address string `json:"address"`
+	// Since is the first time the host was found dead
+	Since string `json:"since"`
+}
+
+// MemberHost represents the identity of a node in the ring
+type MemberHost struct {
+	// Node is the cassandra pod name
+	Node string `json:"node"`
+	// HostID is the host ID of the node in the ring
+	HostID string `json:"hostID"`
+	// Address is the address of the node in the ring
+	Address string `json:"address"`
+}
+
+// NodeShutdown represents how the previous cassandra container of a node was stopped
+type NodeShutdown struct {
+	// Node is the cassandra pod name
+	Node string `json:"node"`
+	// StartedAt is the start time of the current container
+	StartedAt string `json:"startedAt"`
+	// Shutdown is how the previous container was stopped
+	Shutdown ShutdownType `json:"shutdown"`
+	// DrainedAt is the time the node was drained, if it was
+	DrainedAt string `json:"drainedAt,omitempty"`
+}
+
+// RecoveryPoint returns the recovery point of the given node, nil if not found
+func (cs *ClusterStatus) RecoveryPoint(node string) *NodeRecoveryPoint {
+	for i := range cs.RecoveryPoints {
+		if cs.RecoveryPoints[i].Node == node {
+			return &cs.RecoveryPoints[i]
+		}
+	}
+	return nil
+}
+
+// Shutdown returns the shutdown of the given node, nil if not found
+func (ms *MembersStatus) Shutdown(node string) *NodeShutdown {
+	for i := range ms.Shutdowns {
+		if ms.Shutdowns[i].Node == node {
+			return &ms.Shutdowns[i]
+		}
+	}
+	return nil
 }
 
 // Size is the number of the members of the cluster
@@ -77,6 +331,70 @@ func (ms *MembersStatus) Size() int {
 	return len(ms.Nodes)
 }
 
+// Host returns the last known host of the given node, nil if not found
+func (ms *MembersStatus) Host(node string) *MemberHost {
+	for i := range ms.Hosts {
+		if ms.Hosts[i].Node == node {
+			return &ms.Hosts[i]
+		}
+	}
+	return nil
+}
+
+// SetHost records the host of a node
+func (ms *MembersStatus) SetHost(h MemberHost) {
+	if cur := ms.Host(h.Node); cur != nil {
+		*cur = h
+		return
+	}
+	ms.Hosts = append(ms.Hosts, h)
+}
+
+// RemoveHost forgets the host of the given node
+func (ms *MembersStatus) RemoveHost(node string) {
+	var hosts []MemberHost
+	for _, h := range ms.Hosts {
+		if h.Node != node {
+			hosts = append(hosts, h)
+		}
+	}
+	ms.Hosts = hosts
+}
+
+// IsReplacing returns if the given node is replacing its previous host
+func (ms *MembersStatus) IsReplacing(node string) bool {
+	for _, n := range ms.Replacing {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// SetReplacing sets if the given node is replacing its previous host
+func (ms *MembersStatus) SetReplacing(node string, replacing bool) {
+	var nodes []string
+	for _, n := range ms.Replacing {
+		if n != node {
+			nodes = append(nodes, n)
+		}
+	}
+	if replacing {
+		nodes = append(nodes, node)
+	}
+	ms.Replacing = nodes
+}
+
+// DeadHost returns the dead host with the given host ID, nil if not found
+func (ms *MembersStatus) DeadHost(hostID string) *DeadHost {
+	for i := range ms.Dead {
+		if ms.Dead[i].HostID == hostID {
+			return &ms.Dead[i]
+		}
+	}
+	return nil
+}
+
 // IsFailed returns if the cluster is in a failed phase
 func (cs *ClusterStatus) IsFailed() bool {
 	if cs == nil {
@@ -121,6 +439,79 @@ func (cs *ClusterStatus) SetReadyCondition() {
 	c := newClusterCondition(ClusterConditionAvailable, v1.ConditionTrue, "Cluster available", "")
 	cs.setClusterCondition(*c)
 }
+
+// SetPausedCondition set paused condition
+func (cs *ClusterStatus) SetPausedCondition() {
+	c := newClusterCondition(ClusterConditionPaused, v1.ConditionTrue, "Reconciliation paused", "")
+	cs.setClusterCondition(*c)
+}
+
+// ClearPausedCondition removes the paused condition
+func (cs *ClusterStatus) ClearPausedCondition() {
+	cs.removeClusterCondition(ClusterConditionPaused)
+}
+
+// SetRestartingCondition set rolling restart condition
+func (cs *ClusterStatus) SetRestartingCondition(requestedAt string) {
+	c := newClusterCondition(ClusterConditionRestarting, v1.ConditionTrue, "Rolling restart", "Restart requested at "+requestedAt)
+	cs.setClusterCondition(*c)
+}
+
+// ClearRestartingCondition removes the rolling restart condition
+func (cs *ClusterStatus) ClearRestartingCondition() {
+	cs.removeClusterCondition(ClusterConditionRestarting)
+}
+
+// SetReplacingCondition set replacing condition
+func (cs *ClusterStatus) SetReplacingCondition(nodes []string) {
+	c := newClusterCondition(ClusterConditionReplacing, v1.ConditionTrue, "Replacing lost nodes", fmt.Sprintf("Nodes replacing their previous host: %v", nodes))
+	cs.setClusterCondition(*c)
+}
+
+// ClearReplacingCondition removes the replacing condition
+func (cs *ClusterStatus) ClearReplacingCondition() {
+	cs.removeClusterCondition(ClusterConditionReplacing)
+}
+
+// SetRepairOverdueCondition set repair overdue condition
+func (cs *ClusterStatus) SetRepairOverdueCondition(keyspaces []string) {
+	c := newClusterCondition(ClusterConditionRepairOverdue, v1.ConditionTrue, "Repairs overdue", fmt.Sprintf("Keyspaces not repaired in time: %v", keyspaces))
+	cs.setClusterCondition(*c)
+}
+
+// ClearRepairOverdueCondition removes the repair overdue condition
+func (cs *ClusterStatus) ClearRepairOverdueCondition() {
+	cs.removeClusterCondition(ClusterConditionRepairOverdue)
+}
+
+// SetUncleanShutdownCondition set unclean shutdown condition
+func (cs *ClusterStatus) SetUncleanShutdownCondition(nodes []string) {
+	c := newClusterCondition(ClusterConditionUncleanShutdown, v1.ConditionTrue, "Nodes not drained", fmt.Sprintf("Nodes stopped without being drained: %v", nodes))
+	cs.setClusterCondition(*c)
+}
+
+// ClearUncleanShutdownCondition removes the unclean shutdown condition
+func (cs *ClusterStatus) ClearUncleanShutdownCondition() {
+	cs.removeClusterCondition(ClusterConditionUncleanShutdown)
+}
+
+// SetCAExpiringCondition set CA expiring condition
+func (cs *ClusterStatus) SetCAExpiringCondition(secret, notAfter string) {
+	c := newClusterCondition(ClusterConditionCAExpiring, v1.ConditionTrue, "CA expiring", fmt.Sprintf("The internode CA of %v expires at %v, the certificates of the nodes are not renewed until it is replaced", secret, notAfter))
+	cs.setClusterCondition(*c)
+}
+
+// ClearCAExpiringCondition removes the CA expiring condition
+func (cs *ClusterStatus) ClearCAExpiringCondition() {
+	cs.removeClusterCondition(ClusterConditionCAExpiring)
+}
+
+// HasCondition returns if the cluster has a condition of the type
+func (cs *ClusterStatus) HasCondition(t ClusterConditionType) bool {
+	_, c := getClusterCondition(cs, t)
+	return c != nil
+}
+
 func (cs *ClusterStatus) setClusterCondition(c ClusterCondition) {
 	pos, cp := getClusterCondition(cs, c.Type)
 	if cp != nil &&
@@ -135,6 +526,14 @@ func (cs *ClusterStatus) setClusterCondition(c ClusterCondition) {
 	}
 }
 
+func (cs *ClusterStatus) removeClusterCondition(t ClusterConditionType) {
+	pos, _ := getClusterCondition(cs, t)
+	if pos == -1 {
+		return
+	}
+	cs.Conditions = append(cs.Conditions[:pos], cs.Conditions[pos+1:]...)
+}
+
 func getClusterCondition(status *ClusterStatus, t ClusterConditionType) (int, *ClusterCondition) {
 	for i, c := range status.Conditions {
 		if t == c.Type {
@@ -1,7 +1,12 @@
 package v1alpha1
 
 import (
+	"fmt"
+	"regexp"
+	"time"
+
 	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -12,6 +17,71 @@ const (
 
 	// DefaultPartition default value for .spec.updateStrategy.rollingUpdate.partition
 	DefaultPartition = 0
+
+	// DefaultTerminationGracePeriodSeconds default time given to a node to be drained
+	DefaultTerminationGracePeriodSeconds = 600
+
+	// DefaultRepairOverdueAfterSeconds default time after which a keyspace repair is
+	// overdue, the default gc_grace_seconds
+	DefaultRepairOverdueAfterSeconds = 864000
+
+	// DefaultBackupAgentImage default image of the continuous backup sidecar, the
+	// operator image ships the backup agent
+	DefaultBackupAgentImage = "camilocot/operator:v0.0.1"
+
+	// DefaultContinuousBackupIntervalSeconds default time between two shipments of
+	// the continuous backup
+	DefaultContinuousBackupIntervalSeconds = 60
+
+	// DefaultMaxUnavailable default number of nodes the pod disruption budget allows
+	// to be unavailable at once
+	DefaultMaxUnavailable = 1
+
+	// DefaultCertificateValidityDays default validity of the certificates issued to
+	// the nodes
+	DefaultCertificateValidityDays = 365
+	// DefaultCertificateRenewBeforeDays default time before their expiry the
+	// certificates of the nodes are renewed
+	DefaultCertificateRenewBeforeDays = 30
+
+	// PasswordAuthenticator authenticates the clients with the password of their role
+	PasswordAuthenticator = "PasswordAuthenticator"
+	// AllowAllAuthenticator does not authenticate the clients
+	AllowAllAuthenticator = "AllowAllAuthenticator"
+	// CassandraAuthorizer grants the permissions stored in system_auth
+	CassandraAuthorizer = "CassandraAuthorizer"
+	// AllowAllAuthorizer grants every permission to every client
+	AllowAllAuthorizer = "AllowAllAuthorizer"
+
+	// DefaultSuperuser is the superuser created by cassandra on its first start with
+	// the password authenticator, its password is its name
+	DefaultSuperuser = "cassandra"
+	// DefaultAdminRole is the name of the admin role of a generated credentials secret
+	DefaultAdminRole = "admin"
+	// DefaultJMXUsername is the JMX user of a generated JMX credentials secret
+	DefaultJMXUsername = "operator"
+	// UsernameKey is the key of the username in a credentials secret
+	UsernameKey = "username"
+	// PasswordKey is the key of the password in a credentials secret
+	PasswordKey = "password"
+
+	// CassandraFinalizer is the finalizer that keeps the Cassandra object until the
+	// deletion policy has been applied
+	CassandraFinalizer = "finalizer.database.camilocot"
+)
+
+// DeletionPolicy describes what is done with the cluster data when the
+// Cassandra object is deleted
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the persistent volume claims of the cluster
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain keeps the persistent volume claims of the cluster
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicySnapshotThenDelete uploads a final backup of the cluster to the
+	// final backup storage, then deletes the persistent volume claims of the cluster
+	DeletionPolicySnapshotThenDelete DeletionPolicy = "SnapshotThenDelete"
 )
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -67,6 +137,448 @@ type CassandraSpec struct {
 	// bad environement variables are provided.
 	// This field cannot be updated.
 	CassandraEnv []v1.EnvVar `json:"cassandraEnv,omitempty"`
+
+	// DeletionPolicy is applied to the cluster data when the Cassandra object is deleted.
+	// One of Delete, Retain or SnapshotThenDelete.
+	//
+	// If deletion policy is not set, default is Retain.
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// FinalBackup is where the final backup of the SnapshotThenDelete deletion
+	// policy is uploaded. It is required by that policy.
+	FinalBackup *BackupStorage `json:"finalBackup,omitempty"`
+
+	// Paused stops the cassandra-operator from changing the cluster. Only the
+	// cluster status is kept up to date while it is paused.
+	Paused bool `json:"paused,omitempty"`
+
+	// RestartRequestedAt requests a rolling restart of the cluster when its value
+	// changes, usually to the current time. Pods are restarted one by one from the
+	// highest ordinal down to the partition, draining every node before its restart.
+	// It is ignored while an earlier restart requested by the operator is the latest.
+	RestartRequestedAt string `json:"restartRequestedAt,omitempty"`
+
+	// TerminationGracePeriodSeconds is the time given to a node to be drained before
+	// it is killed. Nodes with large memtables need more time to flush them.
+	//
+	// If termination grace period is not set, default is 600.
+	TerminationGracePeriodSeconds int64 `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// NodeSelector restricts the kubernetes nodes the pods are scheduled on to the
+	// ones with these labels.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations let the pods be scheduled on the kubernetes nodes with matching
+	// taints, as the ones of a node pool dedicated to cassandra.
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+	// Affinity are the scheduling constraints of the pods. Its pod anti-affinity
+	// replaces the default one.
+	//
+	// If the pod anti-affinity is not set, default is to spread the pods across the
+	// kubernetes nodes, preferred or required with strict anti-affinity.
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
+	// StrictAntiAffinity requires the default pod anti-affinity, so a pod stays
+	// pending rather than being scheduled on the kubernetes node of another pod of
+	// the cluster.
+	StrictAntiAffinity bool `json:"strictAntiAffinity,omitempty"`
+	// PriorityClassName is the priority class of the pods, so they preempt the pods
+	// of lower priority rather than staying pending.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// PodTemplate customizes the pods, merged onto the pod template generated by the
+	// cassandra-operator.
+	//
+	// If pod template is not set, the pods are only labeled and annotated by the
+	// cassandra-operator and run with the default service account.
+	PodTemplate *PodTemplateSpec `json:"podTemplate,omitempty"`
+
+	// Sidecars are containers added to the pods, as log shippers or exporters. They
+	// share the pod network and the volumes they mount, the commands of the
+	// cassandra-operator are only run in the cassandra container.
+	Sidecars []v1.Container `json:"sidecars,omitempty"`
+	// InitContainers are run in order before the containers of the pods start, as
+	// the ones tuning the kernel of the kubernetes node.
+	InitContainers []v1.Container `json:"initContainers,omitempty"`
+	// Volumes are added to the volumes of the pods, to be mounted by the sidecars,
+	// the init containers or the cassandra container.
+	Volumes []v1.Volume `json:"volumes,omitempty"`
+	// VolumeMounts are added to the volume mounts of the cassandra container.
+	VolumeMounts []v1.VolumeMount `json:"volumeMounts,omitempty"`
+
+	// DeadNodeGracePeriodSeconds is the time a node can be down in the ring once its
+	// pod or volume is gone, before it is removed with nodetool removenode.
+	//
+	// If dead node grace period is not set, dead nodes are not removed.
+	DeadNodeGracePeriodSeconds int64 `json:"deadNodeGracePeriodSeconds,omitempty"`
+	// AssassinateDeadNodes runs nodetool assassinate when a dead node cannot be
+	// removed with nodetool removenode. Its data is not streamed to other nodes.
+	AssassinateDeadNodes bool `json:"assassinateDeadNodes,omitempty"`
+
+	// Repair configures scheduled anti-entropy repairs.
+	//
+	// If repair is not set, the cassandra-operator does not run repairs.
+	Repair *RepairSpec `json:"repair,omitempty"`
+
+	// ContinuousBackup ships the archived commitlog segments to an object storage
+	// as they are written, for point-in-time restores.
+	//
+	// If continuous backup is not set, only CassandraBackup snapshots are taken.
+	ContinuousBackup *ContinuousBackupSpec `json:"continuousBackup,omitempty"`
+
+	// Auth configures the authenticator and authorizer of the cluster. Changing it
+	// restarts the nodes.
+	//
+	// If auth is not set, clients are neither authenticated nor authorized.
+	Auth *AuthSpec `json:"auth,omitempty"`
+
+	// TLS configures the encryption of the connections to the cluster. Changing it,
+	// or the certificates, restarts the nodes.
+	//
+	// If TLS is not set, connections are not encrypted.
+	TLS *TLSSpec `json:"tls,omitempty"`
+
+	// JMX configures the authentication of JMX, the management interface used by
+	// nodetool. Changing it restarts the nodes.
+	//
+	// If JMX is not set, JMX is not authenticated.
+	JMX *JMXSpec `json:"jmx,omitempty"`
+
+	// ClientService configures the service the clients connect to, <name>, which only
+	// selects the ready pods.
+	//
+	// If client service is not set, it is a ClusterIP service exposing CQL.
+	ClientService *ClientServiceSpec `json:"clientService,omitempty"`
+
+	// ExternalAccess exposes every node to the clients outside the kubernetes
+	// cluster with a service of its own, <pod name>-external, and sets the
+	// broadcast_rpc_address of the node to its external address.
+	//
+	// If external access is not set, the nodes are only reachable within the
+	// kubernetes cluster.
+	ExternalAccess *ExternalAccessSpec `json:"externalAccess,omitempty"`
+
+	// NetworkPolicy isolates the cluster with a network policy, <name>, only allowing
+	// the traffic between the nodes, to the client ports and to JMX.
+	//
+	// If network policy is not set, no network policy is created.
+	NetworkPolicy *NetworkPolicySpec `json:"networkPolicy,omitempty"`
+
+	// DisruptionBudget configures the pod disruption budget of the cluster, <name>,
+	// limiting the nodes stopped at once by voluntary disruptions as node drains.
+	//
+	// If disruption budget is not set, a single node may be unavailable.
+	DisruptionBudget *DisruptionBudgetSpec `json:"disruptionBudget,omitempty"`
+}
+
+// RepairSpec contains the specification of the scheduled repairs of the cluster.
+// The nodes are repaired one at a time, so replicas of the same range are never
+// repaired concurrently
+type RepairSpec struct {
+	// Schedule is the cron expression of the repairs, in UTC
+	Schedule string `json:"schedule"`
+	// Keyspaces are the keyspaces repaired, one after the other on every node
+	Keyspaces []string `json:"keyspaces"`
+	// Incremental runs incremental repairs instead of full repairs
+	Incremental bool `json:"incremental,omitempty"`
+	// PrimaryRange only repairs the primary range of every node
+	PrimaryRange bool `json:"primaryRange,omitempty"`
+	// Subrange repairs the primary ranges of every node one token range at a time.
+	// It cannot be used with incremental repairs
+	Subrange bool `json:"subrange,omitempty"`
+	// OverdueAfterSeconds is the time after which a keyspace not successfully
+	// repaired is overdue. It should not be greater than gc_grace_seconds.
+	//
+	// If overdue after seconds is not set, default is 864000.
+	OverdueAfterSeconds int64 `json:"overdueAfterSeconds,omitempty"`
+}
+
+// ContinuousBackupSpec contains the specification of the continuous backup of the
+// cluster. A sidecar ships the files of every node under
+// <prefix>/<cluster>/continuous/<node>/ in the storage
+type ContinuousBackupSpec struct {
+	// Storage is where the files are shipped
+	Storage BackupStorage `json:"storage"`
+	// CommitlogArchiving archives every commitlog segment once it is full, every
+	// archived segment is shipped. It is required by point-in-time restores
+	CommitlogArchiving bool `json:"commitlogArchiving,omitempty"`
+	// Image is the image of the sidecar running the backup agent.
+	//
+	// If image is not set, default is the operator image.
+	Image string `json:"image,omitempty"`
+	// IntervalSeconds is the time between two shipments.
+	//
+	// If interval seconds is not set, default is 60.
+	IntervalSeconds int64 `json:"intervalSeconds,omitempty"`
+}
+
+// Validate returns an error if the continuous backup specification is not valid
+func (cb *ContinuousBackupSpec) Validate() error {
+	if !cb.CommitlogArchiving {
+		return fmt.Errorf("continuous backup requires commitlog archiving")
+	}
+	return cb.Storage.Validate()
+}
+
+// AuthSpec contains the specification of the authentication and authorization of
+// the cluster
+type AuthSpec struct {
+	// Authenticator is PasswordAuthenticator or AllowAllAuthenticator.
+	//
+	// If authenticator is not set, default is PasswordAuthenticator.
+	Authenticator string `json:"authenticator,omitempty"`
+	// Authorizer is CassandraAuthorizer or AllowAllAuthorizer. CassandraAuthorizer
+	// requires the PasswordAuthenticator.
+	//
+	// If authorizer is not set, default is CassandraAuthorizer.
+	Authorizer string `json:"authorizer,omitempty"`
+	// CredentialsSecret is the name of the secret with the username and password of
+	// the admin role the cassandra-operator logs in with. The role is created as a
+	// superuser, and the secret is generated when it does not exist.
+	//
+	// If credentials secret is not set, default is "<cluster name>-admin".
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	// KeepDefaultSuperuser keeps the default cassandra superuser, which is otherwise
+	// disabled once the admin role is created
+	KeepDefaultSuperuser bool `json:"keepDefaultSuperuser,omitempty"`
+}
+
+// Validate returns an error if the auth specification is not valid
+func (as *AuthSpec) Validate() error {
+	switch as.Authenticator {
+	case PasswordAuthenticator, AllowAllAuthenticator:
+	default:
+		return fmt.Errorf("invalid authenticator %q", as.Authenticator)
+	}
+	switch as.Authorizer {
+	case CassandraAuthorizer, AllowAllAuthorizer:
+	default:
+		return fmt.Errorf("invalid authorizer %q", as.Authorizer)
+	}
+	if as.Authorizer == CassandraAuthorizer && as.Authenticator != PasswordAuthenticator {
+		return fmt.Errorf("the %v requires the %v", CassandraAuthorizer, PasswordAuthenticator)
+	}
+	return nil
+}
+
+// ClientServiceSpec contains the specification of the service of the clients
+type ClientServiceSpec struct {
+	// Type is ClusterIP, NodePort or LoadBalancer.
+	//
+	// If type is not set, default is ClusterIP.
+	Type v1.ServiceType `json:"type,omitempty"`
+	// Annotations are the annotations of the service, as the ones configuring the load
+	// balancer of a cloud provider
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Thrift starts the Thrift server of the nodes and exposes its port
+	Thrift bool `json:"thrift,omitempty"`
+	// MetricsPort exposes the port of the metrics of the nodes, as the one of an
+	// exporter in a sidecar.
+	//
+	// If metrics port is not set, the metrics are not exposed.
+	MetricsPort int32 `json:"metricsPort,omitempty"`
+}
+
+// Validate returns an error if the client service specification is not valid
+func (ss *ClientServiceSpec) Validate() error {
+	switch ss.Type {
+	case v1.ServiceTypeClusterIP, v1.ServiceTypeNodePort, v1.ServiceTypeLoadBalancer:
+	default:
+		return fmt.Errorf("invalid client service type %q", ss.Type)
+	}
+	if ss.MetricsPort < 0 || ss.MetricsPort > 65535 {
+		return fmt.Errorf("invalid client service metrics port %v", ss.MetricsPort)
+	}
+	return nil
+}
+
+// PodTemplateSpec contains the customization of the pods of the cluster
+type PodTemplateSpec struct {
+	// Labels are added to the labels of the pods, the ones selecting the pods of the
+	// cluster cannot be overridden
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are added to the annotations of the pods, as the ones of a service
+	// mesh or of the sysctls allowed on the kubernetes nodes. The ones set by the
+	// cassandra-operator cannot be overridden
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// ServiceAccountName is the service account the pods run as.
+	//
+	// If service account name is not set, default is the default service account of
+	// the namespace.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// ImagePullSecrets are the secrets with the credentials of the registries of the
+	// images of the pods
+	ImagePullSecrets []v1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// SecurityContext is the security context of the pods, as the user they run as
+	// and the group owning their volumes
+	SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty"`
+}
+
+// ExternalAccessSpec contains the specification of the services exposing every node
+// outside the kubernetes cluster
+type ExternalAccessSpec struct {
+	// Type is NodePort or LoadBalancer. With NodePort the broadcast_rpc_address of a
+	// node is the address of the kubernetes node running its pod, and the drivers have
+	// to translate the port 9042 to the node port of the service of the node. With
+	// LoadBalancer a node starts once the load balancer of its service is allocated,
+	// and it is restarted when its address changes.
+	//
+	// If type is not set, default is LoadBalancer.
+	Type v1.ServiceType `json:"type,omitempty"`
+	// Annotations are the annotations of the services, as the ones configuring the
+	// load balancers of a cloud provider
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Validate returns an error if the external access specification is not valid
+func (es *ExternalAccessSpec) Validate() error {
+	switch es.Type {
+	case v1.ServiceTypeNodePort, v1.ServiceTypeLoadBalancer:
+	default:
+		return fmt.Errorf("invalid external access type %q", es.Type)
+	}
+	return nil
+}
+
+// NetworkPolicySpec contains the specification of the network policy of the cluster
+type NetworkPolicySpec struct {
+	// ClientsFrom are the namespaces and pods allowed to connect to CQL, and to the
+	// Thrift and metrics ports of the client service when exposed. When the cluster is
+	// exposed outside of kubernetes, with a NodePort or LoadBalancer client service or
+	// the external access, it requires the ipBlock of the source addresses of the
+	// external clients as seen by the pods, usually the addresses of the nodes.
+	//
+	// If clients from is not set, the client ports are allowed from anywhere.
+	ClientsFrom []networkingv1.NetworkPolicyPeer `json:"clientsFrom,omitempty"`
+}
+
+// DisruptionBudgetSpec contains the specification of the pod disruption budget of
+// the cluster
+type DisruptionBudgetSpec struct {
+	// MaxUnavailable is the number of nodes that may be unavailable at once, it should
+	// be lower than the replicas of a quorum of the keyspaces. It is tightened to 0
+	// while a rolling restart, an update or a decommission stops a node.
+	//
+	// If max unavailable is not set, default is 1.
+	MaxUnavailable int32 `json:"maxUnavailable,omitempty"`
+}
+
+// Validate returns an error if the disruption budget specification is not valid
+func (ds *DisruptionBudgetSpec) Validate() error {
+	if ds.MaxUnavailable < 1 {
+		return fmt.Errorf("invalid disruption budget max unavailable %v", ds.MaxUnavailable)
+	}
+	return nil
+}
+
+// JMXSpec contains the specification of the authentication of JMX
+type JMXSpec struct {
+	// CredentialsSecret is the name of the secret with the username and password of
+	// the JMX user. nodetool is run with it by the cassandra-operator and the preStop
+	// hook, and the secret is generated when it does not exist.
+	//
+	// If credentials secret is not set, default is "<cluster name>-jmx".
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	// LocalOnly binds JMX to localhost, so it is only reachable from the containers of
+	// the pods
+	LocalOnly bool `json:"localOnly,omitempty"`
+}
+
+// TLSSpec contains the specification of the encryption of the connections
+type TLSSpec struct {
+	// Client encrypts the connections of the clients, on the CQL port
+	Client *ClientTLSSpec `json:"client,omitempty"`
+	// Internode encrypts and authenticates the gossip and streaming between the nodes,
+	// on the intra-node port
+	Internode *InternodeTLSSpec `json:"internode,omitempty"`
+}
+
+// InternodeTLSSpec contains the specification of the encryption between the nodes.
+// Every node has a certificate for its DNS names in the headless service, issued by
+// the CA of the cluster
+type InternodeTLSSpec struct {
+	// CASecret is the name of the kubernetes.io/tls secret with the certificate and key
+	// of the CA issuing the certificates of the nodes. The CA is generated when the
+	// secret does not exist.
+	//
+	// If CASecret is not set, default is <name>-ca.
+	CASecret string `json:"caSecret,omitempty"`
+	// ValidityDays is the validity of the certificates of the nodes.
+	//
+	// If ValidityDays is not set, default is 365.
+	ValidityDays int32 `json:"validityDays,omitempty"`
+	// RenewBeforeDays is how long before their expiry the certificates of the nodes are
+	// renewed.
+	//
+	// If RenewBeforeDays is not set, default is 30.
+	RenewBeforeDays int32 `json:"renewBeforeDays,omitempty"`
+}
+
+// ClientTLSSpec contains the specification of the encryption of the client
+// connections. The certificates are converted to the keystore and truststore of
+// the nodes
+type ClientTLSSpec struct {
+	// Secret is the name of the kubernetes.io/tls secret with the certificate of the
+	// nodes in tls.crt, its key in tls.key and in ca.crt the CA of the certificate of
+	// the nodes, validated by cqlsh, and of the client certificates
+	Secret string `json:"secret"`
+	// Issuer requests the certificate of the secret from a cert-manager issuer.
+	//
+	// If issuer is not set, the secret is managed out of the cassandra-operator.
+	Issuer *IssuerReference `json:"issuer,omitempty"`
+	// RequireClientAuth requires the clients to present a certificate signed by the
+	// CA of the secret
+	RequireClientAuth bool `json:"requireClientAuth,omitempty"`
+}
+
+// IssuerReference is a reference to a cert-manager issuer
+type IssuerReference struct {
+	// Name is the name of the issuer
+	Name string `json:"name"`
+	// Kind is Issuer, in the namespace of the cluster, or ClusterIssuer.
+	//
+	// If kind is not set, default is Issuer.
+	Kind string `json:"kind,omitempty"`
+}
+
+// Validate returns an error if the TLS specification is not valid
+func (ts *TLSSpec) Validate() error {
+	if ts.Client != nil {
+		if len(ts.Client.Secret) == 0 {
+			return fmt.Errorf("client TLS secret is required")
+		}
+		if ts.Client.Issuer != nil && len(ts.Client.Issuer.Name) == 0 {
+			return fmt.Errorf("client TLS issuer name is required")
+		}
+	}
+	if in := ts.Internode; in != nil {
+		if in.ValidityDays <= 0 || in.RenewBeforeDays <= 0 {
+			return fmt.Errorf("internode TLS validityDays and renewBeforeDays should be positive")
+		}
+		if in.RenewBeforeDays >= in.ValidityDays {
+			return fmt.Errorf("internode TLS renewBeforeDays should be less than validityDays")
+		}
+	}
+	return nil
+}
+
+// keyspaceNameRegexp matches valid keyspace names
+var keyspaceNameRegexp = regexp.MustCompile(`^\w{1,48}$`)
+
+// Validate returns an error if the repair specification is not valid
+func (rs *RepairSpec) Validate() error {
+	if len(rs.Schedule) == 0 {
+		return fmt.Errorf("repair schedule is required")
+	}
+	if len(rs.Keyspaces) == 0 {
+		return fmt.Errorf("repair keyspaces are required")
+	}
+	for _, k := range rs.Keyspaces {
+		if !keyspaceNameRegexp.MatchString(k) {
+			return fmt.Errorf("invalid keyspace name %q", k)
+		}
+	}
+	if rs.Incremental && rs.Subrange {
+		return fmt.Errorf("subrange repairs cannot be incremental")
+	}
+	return nil
 }
 
 func (c *Cassandra) addEnvVar(name string, value string) {
@@ -101,9 +613,194 @@ func (c *Cassandra) SetDefaults() bool {
 		changed = true
 	}
 
+	if cs.TerminationGracePeriodSeconds == 0 {
+		cs.TerminationGracePeriodSeconds = DefaultTerminationGracePeriodSeconds
+		changed = true
+	}
+
+	if cs.Repair != nil && cs.Repair.OverdueAfterSeconds == 0 {
+		cs.Repair.OverdueAfterSeconds = DefaultRepairOverdueAfterSeconds
+		changed = true
+	}
+
+	if cs.ContinuousBackup != nil && len(cs.ContinuousBackup.Image) == 0 {
+		cs.ContinuousBackup.Image = DefaultBackupAgentImage
+		changed = true
+	}
+
+	if cs.ContinuousBackup != nil && cs.ContinuousBackup.IntervalSeconds == 0 {
+		cs.ContinuousBackup.IntervalSeconds = DefaultContinuousBackupIntervalSeconds
+		changed = true
+	}
+
+	if len(cs.DeletionPolicy) == 0 {
+		cs.DeletionPolicy = DeletionPolicyRetain
+		changed = true
+	}
+
+	if cs.Auth != nil && len(cs.Auth.Authenticator) == 0 {
+		cs.Auth.Authenticator = PasswordAuthenticator
+		changed = true
+	}
+
+	if cs.Auth != nil && len(cs.Auth.Authorizer) == 0 {
+		cs.Auth.Authorizer = CassandraAuthorizer
+		changed = true
+	}
+
+	if cs.Auth != nil && len(cs.Auth.CredentialsSecret) == 0 {
+		cs.Auth.CredentialsSecret = c.Name + "-admin"
+		changed = true
+	}
+
+	if cs.TLS != nil && cs.TLS.Client != nil && cs.TLS.Client.Issuer != nil && len(cs.TLS.Client.Issuer.Kind) == 0 {
+		cs.TLS.Client.Issuer.Kind = "Issuer"
+		changed = true
+	}
+
+	if cs.ClientService != nil && len(cs.ClientService.Type) == 0 {
+		cs.ClientService.Type = v1.ServiceTypeClusterIP
+		changed = true
+	}
+
+	if cs.ExternalAccess != nil && len(cs.ExternalAccess.Type) == 0 {
+		cs.ExternalAccess.Type = v1.ServiceTypeLoadBalancer
+		changed = true
+	}
+
+	if cs.DisruptionBudget != nil && cs.DisruptionBudget.MaxUnavailable == 0 {
+		cs.DisruptionBudget.MaxUnavailable = DefaultMaxUnavailable
+		changed = true
+	}
+
+	if cs.JMX != nil && len(cs.JMX.CredentialsSecret) == 0 {
+		cs.JMX.CredentialsSecret = c.Name + "-jmx"
+		changed = true
+	}
+
+	if in := cs.internodeTLS(); in != nil && len(in.CASecret) == 0 {
+		in.CASecret = c.Name + "-ca"
+		changed = true
+	}
+
+	if in := cs.internodeTLS(); in != nil && in.ValidityDays == 0 {
+		in.ValidityDays = DefaultCertificateValidityDays
+		changed = true
+	}
+
+	if in := cs.internodeTLS(); in != nil && in.RenewBeforeDays == 0 {
+		in.RenewBeforeDays = DefaultCertificateRenewBeforeDays
+		changed = true
+	}
+
 	c.addEnvVar("CASSANDRA_SEEDS", c.Name+"-0."+c.Name+"-unready."+c.Namespace+".svc.cluster.local")
 	c.addEnvVar("MAX_HEAP_SIZE", "512M")
 	c.addEnvVar("MAX_NEWSIZE", "100M")
 
 	return changed
 }
+
+// PasswordAuthentication returns if the clients are authenticated with a password
+func (c *Cassandra) PasswordAuthentication() bool {
+	return c.Spec.Auth != nil && c.Spec.Auth.Authenticator == PasswordAuthenticator
+}
+
+// Authorization returns if the permissions of the clients are checked
+func (c *Cassandra) Authorization() bool {
+	return c.Spec.Auth != nil && c.Spec.Auth.Authorizer == CassandraAuthorizer
+}
+
+// JMXAuthentication returns if JMX authenticates its users with a password
+func (c *Cassandra) JMXAuthentication() bool {
+	return c.Spec.JMX != nil
+}
+
+// ClientTLS returns if the connections of the clients are encrypted
+func (c *Cassandra) ClientTLS() bool {
+	return c.Spec.TLS != nil && c.Spec.TLS.Client != nil
+}
+
+// RestartRequestedAt returns the latest rolling restart requested, in the spec by the
+// user or in the status by the operator. A value of the spec that is not an RFC3339
+// time is older than the requests of the operator
+func (c *Cassandra) RestartRequestedAt() string {
+	spec, status := c.Spec.RestartRequestedAt, c.Status.RestartRequestedAt
+	if len(status) == 0 {
+		return spec
+	}
+	specTime, err := time.Parse(time.RFC3339, spec)
+	if err != nil {
+		return status
+	}
+	statusTime, err := time.Parse(time.RFC3339, status)
+	if err != nil || !statusTime.After(specTime) {
+		return spec
+	}
+	return status
+}
+
+// InternodeTLS returns if the connections between the nodes are encrypted
+func (c *Cassandra) InternodeTLS() bool {
+	return c.Spec.internodeTLS() != nil
+}
+
+func (cs *CassandraSpec) internodeTLS() *InternodeTLSSpec {
+	if cs.TLS == nil {
+		return nil
+	}
+	return cs.TLS.Internode
+}
+
+// HasFinalizer returns if the cassandra finalizer is set
+func (c *Cassandra) HasFinalizer() bool {
+	return hasFinalizer(&c.ObjectMeta)
+}
+
+// AddFinalizer adds the cassandra finalizer, returns false if it was already set
+func (c *Cassandra) AddFinalizer() bool {
+	return addFinalizer(&c.ObjectMeta)
+}
+
+// RemoveFinalizer removes the cassandra finalizer, returns false if it was not set
+func (c *Cassandra) RemoveFinalizer() bool {
+	return removeFinalizer(&c.ObjectMeta)
+}
+
+// hasFinalizer returns if the cassandra finalizer is set on the object
+func hasFinalizer(meta *metav1.ObjectMeta) bool {
+	for _, f := range meta.Finalizers {
+		if f == CassandraFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// addFinalizer adds the cassandra finalizer to the object, returns false if it was
+// already set
+func addFinalizer(meta *metav1.ObjectMeta) bool {
+	if hasFinalizer(meta) {
+		return false
+	}
+	meta.Finalizers = append(meta.Finalizers, CassandraFinalizer)
+	return true
+}
+
+// removeFinalizer removes the cassandra finalizer from the object, returns false if
+// it was not set
+func removeFinalizer(meta *metav1.ObjectMeta) bool {
+	var finalizers []string
+	for _, f := range meta.Finalizers {
+		if f != CassandraFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	changed := len(finalizers) != len(meta.Finalizers)
+	meta.Finalizers = finalizers
+	return changed
+}
+
+// IsBeingDeleted returns if the Cassandra object has been marked for deletion
+func (c *Cassandra) IsBeingDeleted() bool {
+	return c.DeletionTimestamp != nil
+}
@@ -0,0 +1,163 @@
+// Package backupagent ships the archived commitlog segments of a cassandra node to
+// an object storage. It runs as a sidecar of the
+// cassandra container, sharing its data volume
+package backupagent
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Environment variables configuring the backup agent
+const (
+	// EnvEndpoint is the URL of the object storage
+	EnvEndpoint = "STORAGE_ENDPOINT"
+	// EnvRegion is the region of the bucket
+	EnvRegion = "STORAGE_REGION"
+	// EnvBucket is the bucket the files are shipped to
+	EnvBucket = "STORAGE_BUCKET"
+	// EnvPrefix is prepended to the key of every file of the node
+	EnvPrefix = "STORAGE_PREFIX"
+	// EnvAccessKeyID is the access key ID of the object storage
+	EnvAccessKeyID = "STORAGE_ACCESS_KEY_ID"
+	// EnvSecretAccessKey is the secret access key of the object storage
+	EnvSecretAccessKey = "STORAGE_SECRET_ACCESS_KEY"
+	// EnvIntervalSeconds is the time between two shipments
+	EnvIntervalSeconds = "SHIP_INTERVAL_SECONDS"
+	// EnvCommitlogArchiveDirectory is where cassandra archives the commitlog segments
+	EnvCommitlogArchiveDirectory = "COMMITLOG_ARCHIVE_DIRECTORY"
+	// EnvRecoveryPointFile is where the recovery point of the node is written
+	EnvRecoveryPointFile = "RECOVERY_POINT_FILE"
+)
+
+// CommitlogPrefix is the prefix of the keys of the archived commitlog segments
+const CommitlogPrefix = "commitlog"
+
+// Uploader stores objects in an object storage
+type Uploader interface {
+	PutObject(bucket, key string, body io.Reader, size int64) error
+}
+
+// Agent ships the files of a node. A file is removed once it is shipped, so every
+// file found is pending
+type Agent struct {
+	Uploader Uploader
+	Bucket   string
+	// Prefix is prepended to the key of every file
+	Prefix string
+
+	CommitlogArchiveDirectory string
+	// RecoveryPointFile receives the start time of the last shipment of every
+	// pending file, in RFC3339
+	RecoveryPointFile string
+}
+
+// pendingFile is a file to ship and its key
+type pendingFile struct {
+	path string
+	key  string
+	size int64
+}
+
+// Run ships the pending files every interval until stop is closed, and once more
+// then. Failed shipments are logged and retried on the next interval
+func (a *Agent) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		a.ship()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			a.ship()
+			return
+		}
+	}
+}
+
+func (a *Agent) ship() {
+	n, err := a.Ship(time.Now())
+	if err != nil {
+		logrus.Errorf("Shipment failed: %v", err)
+		return
+	}
+	if n > 0 {
+		logrus.Infof("Shipped %v files", n)
+	}
+}
+
+// Ship uploads every pending file and removes it. The start time of the shipment is
+// recorded as the recovery point once every file has been shipped
+func (a *Agent) Ship(now time.Time) (int, error) {
+	files, err := a.pendingFiles()
+	if err != nil {
+		return 0, err
+	}
+	for i, f := range files {
+		err = a.upload(f)
+		if err != nil {
+			return i, err
+		}
+		err = os.Remove(f.path)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(files), a.writeRecoveryPoint(now)
+}
+
+// pendingFiles returns the archived commitlog segments
+func (a *Agent) pendingFiles() ([]pendingFile, error) {
+	var files []pendingFile
+	if len(a.CommitlogArchiveDirectory) > 0 {
+		infos, err := ioutil.ReadDir(a.CommitlogArchiveDirectory)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, info := range infos {
+			if !info.Mode().IsRegular() {
+				continue
+			}
+			path := filepath.Join(a.CommitlogArchiveDirectory, info.Name())
+			files = append(files, pendingFile{path: path, key: a.key(CommitlogPrefix, info.Name()), size: info.Size()})
+		}
+	}
+	return files, nil
+}
+
+// upload stores the file under its key
+func (a *Agent) upload(f pendingFile) error {
+	r, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return a.Uploader.PutObject(a.Bucket, f.key, r, f.size)
+}
+
+// writeRecoveryPoint replaces the recovery point file with the given time
+func (a *Agent) writeRecoveryPoint(t time.Time) error {
+	if len(a.RecoveryPointFile) == 0 {
+		return nil
+	}
+	tmp := a.RecoveryPointFile + ".tmp"
+	err := ioutil.WriteFile(tmp, []byte(t.UTC().Format(time.RFC3339)), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.RecoveryPointFile)
+}
+
+// key returns the key of an object of the node
+func (a *Agent) key(elem ...string) string {
+	if len(a.Prefix) > 0 {
+		elem = append([]string{strings.Trim(a.Prefix, "/")}, elem...)
+	}
+	return strings.Join(elem, "/")
+}
@@ -0,0 +1,94 @@
+package backupagent
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeUploader struct {
+	objects map[string]string
+	err     error
+}
+
+func (f *fakeUploader) PutObject(bucket, key string, body io.Reader, size int64) error {
+	if f.err != nil {
+		return f.err
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	f.objects[bucket+"/"+key] = string(data)
+	return nil
+}
+
+func writeFile(t *testing.T, path, content string) {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func TestShip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backupagent")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "commitlog_archive")
+	writeFile(t, filepath.Join(archive, "CommitLog-6-1525140000000.log"), "segment")
+	writeFile(t, filepath.Join(archive, "CommitLog-6-1525140000001.log"), "next segment")
+
+	uploader := &fakeUploader{objects: map[string]string{}}
+	a := &Agent{
+		Uploader:                  uploader,
+		Bucket:                    "backups",
+		Prefix:                    "/prod/cluster/continuous/cluster-0/",
+		CommitlogArchiveDirectory: archive,
+		RecoveryPointFile:         filepath.Join(dir, "recovery-point"),
+	}
+
+	now := time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+	n, err := a.Ship(now)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, map[string]string{
+		"backups/prod/cluster/continuous/cluster-0/commitlog/CommitLog-6-1525140000000.log": "segment",
+		"backups/prod/cluster/continuous/cluster-0/commitlog/CommitLog-6-1525140000001.log": "next segment",
+	}, uploader.objects)
+
+	// shipped files are removed
+	_, err = os.Stat(filepath.Join(archive, "CommitLog-6-1525140000000.log"))
+	assert.True(t, os.IsNotExist(err))
+
+	recoveryPoint, err := ioutil.ReadFile(a.RecoveryPointFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "2018-06-01T10:00:00Z", string(recoveryPoint))
+
+	// the recovery point is not moved when a file cannot be shipped
+	writeFile(t, filepath.Join(archive, "CommitLog-6-1525140000002.log"), "segment")
+	uploader.err = fmt.Errorf("unavailable")
+	_, err = a.Ship(now.Add(time.Minute))
+	assert.Error(t, err)
+	recoveryPoint, err = ioutil.ReadFile(a.RecoveryPointFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "2018-06-01T10:00:00Z", string(recoveryPoint))
+}
+
+func TestShipWithoutArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backupagent")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	a := &Agent{
+		Uploader:                  &fakeUploader{objects: map[string]string{}},
+		CommitlogArchiveDirectory: filepath.Join(dir, "commitlog_archive"),
+	}
+	n, err := a.Ship(time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,230 @@
+package cassandra
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"time"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/cql"
+	"github.com/camilocot/cassandra-operator/pkg/nodetool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/operator-framework/operator-sdk/pkg/sdk"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// systemAuthKeyspace is the keyspace of the roles and permissions
+const systemAuthKeyspace = "system_auth"
+
+// ReconcileAuth bootstraps the password authentication once every node runs with
+// it: the admin role of the credentials secret is created logged in as the default
+// superuser, system_auth is replicated to every node and repaired, and the default
+// superuser is disabled. Every step is checked against the cluster, so the bootstrap
+// is resumed where it was left when the operator restarts
+func (c Cluster) ReconcileAuth() (err error) {
+	r := c.Resource
+	status := r.Status.DeepCopy()
+	if !r.PasswordAuthentication() {
+		r.Status.Auth = nil
+	} else {
+		if r.Status.Auth == nil {
+			r.Status.Auth = &v1alpha1.AuthStatus{}
+		}
+		err = bootstrapAuth(r)
+	}
+
+	if !reflect.DeepEqual(status, &r.Status) {
+		updateErr := sdk.Update(r)
+		if updateErr != nil {
+			return updateErr
+		}
+	}
+	return err
+}
+
+// bootstrapAuth runs the next step of the authentication bootstrap
+func bootstrapAuth(r *v1alpha1.Cassandra) error {
+	pods, err := podsForCassandra(r)
+	if err != nil {
+		return err
+	}
+	if !allPodsReady(r, pods) || !podsHaveCredentials(pods) {
+		logrus.Infof("Waiting for every node of %v to run with the %v", r.Name, v1alpha1.PasswordAuthenticator)
+		return nil
+	}
+	podName := pods[0].Name
+	auth := r.Status.Auth
+
+	username, password, err := credentialsForCassandra(r)
+	if err != nil {
+		return err
+	}
+	if !auth.AdminRoleCreated {
+		err = createAdminRole(r, podName, username, password)
+		if err != nil {
+			return err
+		}
+		auth.AdminRoleCreated = true
+	}
+
+	if auth.Repair != nil {
+		auth.Repair, err = stepKeyspaceRepair(r, r, systemAuthKeyspace, auth.Repair)
+		return err
+	}
+	ring, err := ringForCassandra(r, podName)
+	if err != nil {
+		return err
+	}
+	live, _, _, err := describeKeyspace(r, podName, systemAuthKeyspace)
+	if err != nil {
+		return err
+	}
+	desired := systemAuthReplication(ring)
+	if !live.equal(desired) {
+		_, err = runCqlsh(r, podName, fmt.Sprintf("ALTER KEYSPACE %s WITH replication = %s", systemAuthKeyspace, replicationLiteral(desired)))
+		if err != nil {
+			return err
+		}
+		recordEvent(r, v1.EventTypeNormal, "SystemAuthReplicated", "Changed replication of %v from %v to %v", systemAuthKeyspace, replicationLiteral(live), replicationLiteral(desired))
+		auth.SystemAuthReplication = desired.factors
+		auth.Repair = &v1alpha1.KeyspaceRepairRun{StartedAt: time.Now().Format(time.RFC3339), Cleanup: replicasRemoved(live, desired)}
+		return nil
+	}
+	auth.SystemAuthReplication = live.factors
+
+	if username == v1alpha1.DefaultSuperuser || r.Spec.Auth.KeepDefaultSuperuser || auth.DefaultSuperuserDisabled {
+		return nil
+	}
+	found, superuser, login, err := describeRole(r, podName, v1alpha1.DefaultSuperuser)
+	if err != nil {
+		return err
+	}
+	if found && (superuser || login) {
+		err = runCqlshInput(r, podName, roleStatement("ALTER ROLE", v1alpha1.DefaultSuperuser, []string{"SUPERUSER = false", "LOGIN = false"}))
+		if err != nil {
+			return err
+		}
+		recordEvent(r, v1.EventTypeNormal, "DefaultSuperuserDisabled", "Disabled the default %v superuser", v1alpha1.DefaultSuperuser)
+	}
+	auth.DefaultSuperuserDisabled = true
+	return nil
+}
+
+// createAdminRole creates the admin role logged in as the default superuser, unless
+// the cassandra-operator already logs in with it
+func createAdminRole(r *v1alpha1.Cassandra, podName, username, password string) error {
+	found, _, _, err := describeRole(r, podName, username)
+	if err == nil && found {
+		return nil
+	}
+	if username == v1alpha1.DefaultSuperuser {
+		return fmt.Errorf("could not log in as %v: %v", username, err)
+	}
+	options := []string{"SUPERUSER = true", "LOGIN = true", "PASSWORD = " + cql.QuoteString(password)}
+	err = runCqlshAsDefaultSuperuser(r, podName, roleStatement("CREATE ROLE IF NOT EXISTS", username, options))
+	if err != nil {
+		return fmt.Errorf("could not create the admin role %v as the default superuser: %v", username, err)
+	}
+	recordEvent(r, v1.EventTypeNormal, "AdminRoleCreated", "Created the admin role %v", username)
+	return nil
+}
+
+// systemAuthReplication returns the replication of system_auth, with a replica on
+// every node of every datacenter
+func systemAuthReplication(ring nodetool.Ring) replication {
+	rep := replication{strategy: v1alpha1.NetworkTopologyStrategy, factors: map[string]int32{}}
+	for _, node := range ring {
+		rep.factors[node.Datacenter]++
+	}
+	return rep
+}
+
+// replicasRemoved returns if a datacenter has less replicas in the desired replication
+func replicasRemoved(live, desired replication) bool {
+	if live.strategy != desired.strategy {
+		return false
+	}
+	for key, rf := range live.factors {
+		if desired.factors[key] < rf {
+			return true
+		}
+	}
+	return false
+}
+
+// podsHaveCredentials returns if the cassandra container of every pod has the
+// credentials of the cassandra-operator, so it runs with the password authenticator
+func podsHaveCredentials(pods []v1.Pod) bool {
+	for _, p := range pods {
+		found := false
+		for _, c := range p.Spec.Containers {
+			if c.Name != cassandraContainerName {
+				continue
+			}
+			for _, e := range c.Env {
+				found = found || e.Name == passwordEnv
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// credentialsForCassandra returns the username and password of the credentials secret
+func credentialsForCassandra(api *v1alpha1.Cassandra) (string, string, error) {
+	name := api.Spec.Auth.CredentialsSecret
+	s := secret(name, api.Namespace)
+	err := sdk.Get(s)
+	if err != nil {
+		return "", "", fmt.Errorf("could not get credentials secret %v: %v", name, err)
+	}
+	username, password := string(s.Data[v1alpha1.UsernameKey]), string(s.Data[v1alpha1.PasswordKey])
+	if len(username) == 0 || len(password) == 0 {
+		return "", "", fmt.Errorf("credentials secret %v requires the %v and %v keys", name, v1alpha1.UsernameKey, v1alpha1.PasswordKey)
+	}
+	return username, password, nil
+}
+
+// reconcileCredentialsSecret generates the credentials secret with the username and a
+// random password when it does not exist. It is not owned by the cluster, so the
+// credentials of the retained data are not lost with it
+func reconcileCredentialsSecret(api *v1alpha1.Cassandra, name, username string) error {
+	s := secret(name, api.Namespace)
+	err := sdk.Get(s)
+	if err == nil || !apierrors.IsNotFound(err) {
+		return err
+	}
+	password, err := randomPassword()
+	if err != nil {
+		return err
+	}
+	s.Labels = labelsForCassandra(api.Name)
+	s.Type = v1.SecretTypeOpaque
+	s.Data = map[string][]byte{
+		v1alpha1.UsernameKey: []byte(username),
+		v1alpha1.PasswordKey: []byte(password),
+	}
+	err = sdk.Create(s)
+	if err != nil {
+		return err
+	}
+	recordEvent(api, v1.EventTypeNormal, "CredentialsGenerated", "Generated the credentials secret %v", s.Name)
+	return nil
+}
+
+// randomPassword returns a random password, with only hexadecimal characters so it
+// is safe in a shell and in CQL
+func randomPassword() (string, error) {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,345 @@
+package cassandra
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/exec"
+	"github.com/camilocot/cassandra-operator/pkg/nodetool"
+	"github.com/camilocot/cassandra-operator/pkg/util/s3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/operator-framework/operator-sdk/pkg/sdk"
+	"k8s.io/api/core/v1"
+)
+
+const (
+	// schemaObject is the name of the object with the schema of the cluster
+	schemaObject = "schema.cql"
+	// manifestObject is the name of the manifests of the backup and of every node
+	manifestObject = "manifest.json"
+)
+
+// BackupController manages reconciliation of a Cassandra backup
+type BackupController interface {
+	ReconcileBackup() error
+}
+
+// Backup represents a backup of a Cassandra cluster
+type Backup struct {
+	Resource *v1alpha1.CassandraBackup
+
+	BackupController
+}
+
+// NewCassandraBackup creates a new Cassandra Backup object
+func NewCassandraBackup(b *v1alpha1.CassandraBackup) *Backup {
+	return &Backup{Resource: b}
+}
+
+// backupManifest describes a completed backup, it is stored with the backup
+type backupManifest struct {
+	Cluster     string   `json:"cluster"`
+	Name        string   `json:"name"`
+	StartedAt   string   `json:"startedAt"`
+	CompletedAt string   `json:"completedAt"`
+	Keyspaces   []string `json:"keyspaces,omitempty"`
+	Schema      string   `json:"schema"`
+	// Nodes are the keys of the manifests of the nodes
+	Nodes []string `json:"nodes"`
+}
+
+// nodeManifest lists the files and tokens of the backup of a node
+type nodeManifest struct {
+	Node       string       `json:"node"`
+	HostID     string       `json:"hostID"`
+	Address    string       `json:"address"`
+	Datacenter string       `json:"datacenter"`
+	Rack       string       `json:"rack"`
+	Tokens     []string     `json:"tokens"`
+	Files      []backupFile `json:"files"`
+}
+
+// backupFile is a file of a snapshot
+type backupFile struct {
+	Keyspace string `json:"keyspace"`
+	// Table is the name of the table directory, the table name and its ID
+	Table string `json:"table"`
+	// Name is the path of the file in the snapshot directory
+	Name string `json:"name"`
+	Key  string `json:"key,omitempty"`
+	Size int64  `json:"size"`
+}
+
+// ReconcileBackup takes the backup one step at a time: the schema first and then
+// every node in order. A node is backed up by taking a snapshot, uploading its
+// files and manifest and clearing the snapshot. The backup fails on any error
+func (b Backup) ReconcileBackup() (err error) {
+	r := b.Resource
+	if r.Status.IsFinished() {
+		return nil
+	}
+
+	if len(r.Status.Phase) == 0 {
+		err = r.Spec.Validate()
+		if err != nil {
+			return failBackup(r, err)
+		}
+		r.Status.Phase = v1alpha1.BackupPhaseRunning
+		r.Status.Name = r.Name
+		r.Status.StartedAt = time.Now().Format(time.RFC3339)
+		r.Status.Location = fmt.Sprintf("s3://%s/%s", r.Spec.Storage.Bucket, r.Spec.Storage.Key(r.Spec.Cluster, r.Status.Name))
+		recordEvent(r, v1.EventTypeNormal, "BackupStarted", "Backing up cluster %v to %v", r.Spec.Cluster, r.Status.Location)
+		return sdk.Update(r)
+	}
+
+	cluster := cassandra(r.Spec.Cluster, r.Namespace)
+	err = sdk.Get(cluster)
+	if err != nil {
+		return failBackup(r, fmt.Errorf("could not get cluster %v: %v", r.Spec.Cluster, err))
+	}
+	if cluster.Spec.Paused {
+		logrus.Infof("Reconciliation of %v paused, waiting to back it up", cluster.Name)
+		return nil
+	}
+
+	err = backupStep(r, cluster)
+	if err != nil {
+		clearSnapshots(cluster, r.Status.Name)
+		return failBackup(r, err)
+	}
+	return sdk.Update(r)
+}
+
+// backupStep uploads the schema, takes the snapshot of every node, or uploads the
+// next node or the manifest of the backup
+func backupStep(r *v1alpha1.CassandraBackup, cluster *v1alpha1.Cassandra) error {
+	storage := r.Spec.Storage
+	client, err := storageClient(r.Namespace, storage)
+	if err != nil {
+		return err
+	}
+
+	if len(r.Status.Schema) == 0 {
+		var pods []v1.Pod
+		pods, err = podsForCassandra(cluster)
+		if err != nil {
+			return err
+		}
+		podName := readyPodName(pods)
+		if len(podName) == 0 {
+			return fmt.Errorf("no ready pods to get the schema from")
+		}
+		var schema string
+		schema, err = runCqlsh(cluster, podName, "DESCRIBE SCHEMA")
+		if err != nil {
+			return err
+		}
+		key := storage.Key(cluster.Name, r.Status.Name, schemaObject)
+		err = client.PutObject(storage.Bucket, key, strings.NewReader(schema), int64(len(schema)))
+		if err != nil {
+			return err
+		}
+		r.Status.Schema = key
+		return nil
+	}
+
+	if !r.Status.Snapshotted {
+		err = snapshotNodes(r, cluster)
+		if err != nil {
+			return err
+		}
+		r.Status.Snapshotted = true
+		return nil
+	}
+
+	ordinal := int32(len(r.Status.Nodes))
+	if ordinal < cluster.Spec.Size {
+		var node *v1alpha1.BackupNode
+		node, err = backupNode(r, cluster, client, podNameForCassandra(cluster, ordinal))
+		if err != nil {
+			return err
+		}
+		r.Status.Nodes = append(r.Status.Nodes, *node)
+		r.Status.Size += node.Size
+		return nil
+	}
+
+	manifest := backupManifest{
+		Cluster:     cluster.Name,
+		Name:        r.Status.Name,
+		StartedAt:   r.Status.StartedAt,
+		CompletedAt: time.Now().Format(time.RFC3339),
+		Keyspaces:   r.Spec.Keyspaces,
+		Schema:      r.Status.Schema,
+	}
+	for _, n := range r.Status.Nodes {
+		manifest.Nodes = append(manifest.Nodes, n.Manifest)
+	}
+	err = putJSON(client, storage.Bucket, storage.Key(cluster.Name, r.Status.Name, manifestObject), manifest)
+	if err != nil {
+		return err
+	}
+
+	r.Status.Phase = v1alpha1.BackupPhaseCompleted
+	r.Status.CompletedAt = manifest.CompletedAt
+	recordEvent(r, v1.EventTypeNormal, "BackupCompleted", "Backed up %v bytes of %v nodes to %v", r.Status.Size, len(r.Status.Nodes), r.Status.Location)
+	return nil
+}
+
+// snapshotNodes takes the snapshot of the backup on every node first, so the nodes
+// are backed up at the same time and not as long apart as their uploads take
+func snapshotNodes(r *v1alpha1.CassandraBackup, cluster *v1alpha1.Cassandra) error {
+	for ordinal := int32(0); ordinal < cluster.Spec.Size; ordinal++ {
+		podName := podNameForCassandra(cluster, ordinal)
+		p := pod(podName, cluster.Namespace)
+		err := sdk.Get(p)
+		if err != nil {
+			return err
+		}
+		if !isPodReady(p) {
+			return fmt.Errorf("%v is not ready", podName)
+		}
+		logrus.Infof("Taking snapshot %v of %v", r.Status.Name, podName)
+		_, err = runNodetool(cluster, podName, append([]string{"snapshot", "-t", r.Status.Name}, r.Spec.Keyspaces...)...)
+		// the snapshot was taken in a previous attempt
+		if err != nil && !strings.Contains(err.Error(), "already exists") {
+			return fmt.Errorf("%v: %v", podName, err)
+		}
+	}
+	return nil
+}
+
+// backupNode uploads the files of the snapshot of the node and its manifest
+func backupNode(r *v1alpha1.CassandraBackup, cluster *v1alpha1.Cassandra, client *s3.Client, podName string) (*v1alpha1.BackupNode, error) {
+	storage := r.Spec.Storage
+	tag := r.Status.Name
+
+	p := pod(podName, cluster.Namespace)
+	err := sdk.Get(p)
+	if err != nil {
+		return nil, err
+	}
+	if !isPodReady(p) {
+		return nil, fmt.Errorf("%v is not ready", podName)
+	}
+
+	out, err := runNodetool(cluster, podName, "info", "-T")
+	if err != nil {
+		return nil, err
+	}
+	info, err := nodetool.ParseInfo(out)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err = exec.ContainerCommand(podName, cassandraContainerName, cluster.Namespace,
+		"find", cassandraDataDirectory, "-type", "f", "-path", "*/snapshots/"+tag+"/*", "-printf", "%s %P\\n") // #nosec
+	if err != nil {
+		return nil, err
+	}
+	files, err := parseSnapshotFiles(out)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := nodeManifest{
+		Node:       podName,
+		HostID:     info.HostID,
+		Address:    p.Status.PodIP,
+		Datacenter: info.Datacenter,
+		Rack:       info.Rack,
+		Tokens:     info.Tokens,
+	}
+	node := &v1alpha1.BackupNode{Node: podName, HostID: info.HostID}
+	for _, f := range files {
+		if len(r.Spec.Keyspaces) == 0 && isSystemKeyspace(f.Keyspace) {
+			continue
+		}
+		f.Key = storage.Key(cluster.Name, tag, podName, f.Keyspace, f.Table, f.Name)
+		path := strings.Join([]string{cassandraDataDirectory, f.Keyspace, f.Table, "snapshots", tag, f.Name}, "/")
+		err = uploadFile(cluster, client, storage.Bucket, podName, path, f.Key, f.Size)
+		if err != nil {
+			return nil, fmt.Errorf("could not upload %v of %v: %v", path, podName, err)
+		}
+		manifest.Files = append(manifest.Files, f)
+		node.Files++
+		node.Size += f.Size
+	}
+
+	node.Manifest = storage.Key(cluster.Name, tag, podName, manifestObject)
+	err = putJSON(client, storage.Bucket, node.Manifest, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = runNodetool(cluster, podName, "clearsnapshot", "-t", tag)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("Backed up %v files of %v", node.Files, podName)
+	return node, nil
+}
+
+// failBackup records the failure of the backup
+func failBackup(r *v1alpha1.CassandraBackup, err error) error {
+	r.Status.Phase = v1alpha1.BackupPhaseFailed
+	r.Status.Reason = err.Error()
+	r.Status.CompletedAt = time.Now().Format(time.RFC3339)
+	recordEvent(r, v1.EventTypeWarning, "BackupFailed", "Backup failed: %v", err)
+
+	updateErr := sdk.Update(r)
+	if updateErr != nil {
+		return updateErr
+	}
+	return err
+}
+
+// clearSnapshots removes the snapshot with the tag from every node, failures are only logged
+func clearSnapshots(api *v1alpha1.Cassandra, tag string) {
+	nodes, err := nodesForCassandra(api)
+	if err != nil {
+		logrus.Warnf("Could not clear snapshot %v: %v", tag, err)
+		return
+	}
+	for _, podName := range nodes {
+		_, err = runNodetool(api, podName, "clearsnapshot", "-t", tag)
+		if err != nil {
+			logrus.Warnf("Could not clear snapshot %v of %v: %v", tag, podName, err)
+		}
+	}
+}
+
+// parseSnapshotFiles parses the size and path, relative to the data directory, of
+// the files of a snapshot
+func parseSnapshotFiles(out string) ([]backupFile, error) {
+	var files []backupFile
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid snapshot file %q", line)
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid snapshot file %q: %v", line, err)
+		}
+		// keyspace/table/snapshots/tag/name
+		path := strings.SplitN(fields[1], "/", 5)
+		if len(path) != 5 || path[2] != "snapshots" {
+			return nil, fmt.Errorf("invalid snapshot file %q", line)
+		}
+		files = append(files, backupFile{Keyspace: path[0], Table: path[1], Name: path[4], Size: size})
+	}
+	return files, nil
+}
+
+// isSystemKeyspace returns if the keyspace is managed by cassandra
+func isSystemKeyspace(keyspace string) bool {
+	return strings.HasPrefix(keyspace, "system")
+}
@@ -0,0 +1,272 @@
+package cassandra
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/util/cron"
+	"github.com/sirupsen/logrus"
+
+	"github.com/operator-framework/operator-sdk/pkg/sdk"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// BackupScheduleController manages reconciliation of a Cassandra backup schedule
+type BackupScheduleController interface {
+	ReconcileBackupSchedule() error
+}
+
+// BackupSchedule represents backups of a Cassandra cluster taken on a schedule
+type BackupSchedule struct {
+	Resource *v1alpha1.CassandraBackupSchedule
+
+	BackupScheduleController
+}
+
+// NewCassandraBackupSchedule creates a new Cassandra Backup Schedule object
+func NewCassandraBackupSchedule(s *v1alpha1.CassandraBackupSchedule) *BackupSchedule {
+	return &BackupSchedule{Resource: s}
+}
+
+// ReconcileBackupSchedule creates a backup when one is due and none is running, and
+// prunes the backups out of the retention, from the cluster and the object storage
+func (s BackupSchedule) ReconcileBackupSchedule() (err error) {
+	r := s.Resource
+	err = r.Spec.Validate()
+	if err != nil {
+		return err
+	}
+	schedule, err := cron.Parse(r.Spec.Schedule)
+	if err != nil {
+		return err
+	}
+
+	backups, err := backupsForSchedule(r)
+	if err != nil {
+		return err
+	}
+
+	status := r.Status.DeepCopy()
+	updateBackupScheduleStatus(r, backups)
+	setBackupScheduleMetrics(r)
+
+	if len(r.Status.Active) == 0 {
+		due := dueSchedule(schedule, r.CreationTimestamp, r.Status.LastScheduleTime, time.Now())
+		if !due.IsZero() {
+			b := backupForSchedule(r, due)
+			err = sdk.Create(b)
+			if err != nil && !apierrors.IsAlreadyExists(err) {
+				return err
+			}
+			r.Status.LastScheduleTime = due.Format(time.RFC3339)
+			r.Status.Active = b.Name
+			recordEvent(r, v1.EventTypeNormal, "BackupCreated", "Created backup %v scheduled at %v", b.Name, r.Status.LastScheduleTime)
+		}
+	}
+
+	pruned := backupsToPrune(r, backups)
+	for _, b := range pruned {
+		err = deleteBackup(&b)
+		if err != nil {
+			return fmt.Errorf("could not delete backup %v: %v", b.Name, err)
+		}
+		recordEvent(r, v1.EventTypeNormal, "BackupDeleted", "Deleted backup %v out of the retention", b.Name)
+	}
+	if len(pruned) > 0 {
+		err = pruneContinuousBackup(r, oldestRetainedBackup(backups, pruned))
+		if err != nil {
+			return fmt.Errorf("could not prune the continuous backup of %v: %v", r.Spec.Cluster, err)
+		}
+	}
+
+	if !reflect.DeepEqual(status, &r.Status) {
+		err = sdk.Update(r)
+	}
+	return err
+}
+
+// backupsForSchedule returns the backups created by the schedule, the oldest first
+func backupsForSchedule(api *v1alpha1.CassandraBackupSchedule) ([]v1alpha1.CassandraBackup, error) {
+	backupList := &v1alpha1.CassandraBackupList{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "CassandraBackup",
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+		},
+	}
+	labelSelector := labels.SelectorFromSet(map[string]string{v1alpha1.BackupScheduleLabel: api.Name}).String()
+	listOps := &metav1.ListOptions{LabelSelector: labelSelector}
+	err := sdk.List(api.Namespace, backupList, sdk.WithListOptions(listOps))
+	if err != nil {
+		return nil, err
+	}
+
+	backups := backupList.Items
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreationTimestamp.Before(&backups[j].CreationTimestamp)
+	})
+	return backups, nil
+}
+
+// backupForSchedule returns the backup created by the schedule at the schedule time
+func backupForSchedule(api *v1alpha1.CassandraBackupSchedule, scheduleTime time.Time) *v1alpha1.CassandraBackup {
+	b := cassandraBackup(fmt.Sprintf("%s-%d", api.Name, scheduleTime.Unix()), api.Namespace)
+	b.Labels = map[string]string{v1alpha1.BackupScheduleLabel: api.Name}
+	api.Spec.CassandraBackupSpec.DeepCopyInto(&b.Spec)
+	return b
+}
+
+// updateBackupScheduleStatus records the running backup and the last completed and
+// failed ones. The last backups are kept in the status once they are pruned
+func updateBackupScheduleStatus(api *v1alpha1.CassandraBackupSchedule, backups []v1alpha1.CassandraBackup) {
+	api.Status.Active = ""
+	for _, b := range backups {
+		scheduled := &v1alpha1.ScheduledBackup{
+			Name:        b.Name,
+			StartedAt:   b.Status.StartedAt,
+			CompletedAt: b.Status.CompletedAt,
+			Reason:      b.Status.Reason,
+		}
+		switch b.Status.Phase {
+		case v1alpha1.BackupPhaseCompleted:
+			if isNewerBackup(scheduled, api.Status.LastSuccessfulBackup) {
+				api.Status.LastSuccessfulBackup = scheduled
+			}
+		case v1alpha1.BackupPhaseFailed:
+			if isNewerBackup(scheduled, api.Status.LastFailedBackup) {
+				api.Status.LastFailedBackup = scheduled
+			}
+		default:
+			api.Status.Active = b.Name
+		}
+	}
+}
+
+// isNewerBackup returns if the backup completed after the last one
+func isNewerBackup(b, last *v1alpha1.ScheduledBackup) bool {
+	if last == nil || b.Name == last.Name {
+		return true
+	}
+	completed, err := time.Parse(time.RFC3339, b.CompletedAt)
+	if err != nil {
+		return false
+	}
+	lastCompleted, err := time.Parse(time.RFC3339, last.CompletedAt)
+	return err != nil || !completed.Before(lastCompleted)
+}
+
+// backupsToPrune returns the completed backups out of the retention, but the last
+// one, and the failed backups older than the last completed one
+func backupsToPrune(api *v1alpha1.CassandraBackupSchedule, backups []v1alpha1.CassandraBackup) []v1alpha1.CassandraBackup {
+	var completed []v1alpha1.CassandraBackup
+	for _, b := range backups {
+		if b.Status.Phase == v1alpha1.BackupPhaseCompleted {
+			completed = append(completed, b)
+		}
+	}
+	if len(completed) == 0 {
+		return nil
+	}
+
+	retention := api.Spec.Retention
+	maxAge := time.Duration(retention.MaxAgeSeconds) * time.Second
+	last := completed[len(completed)-1]
+
+	var prune []v1alpha1.CassandraBackup
+	for i, b := range completed[:len(completed)-1] {
+		outOfCount := retention.Count > 0 && len(completed)-i > int(retention.Count)
+		tooOld := retention.MaxAgeSeconds > 0 && since(b.Status.CompletedAt) > maxAge
+		if outOfCount || tooOld {
+			prune = append(prune, b)
+		}
+	}
+	for _, b := range backups {
+		if b.Status.Phase == v1alpha1.BackupPhaseFailed && b.CreationTimestamp.Before(&last.CreationTimestamp) {
+			prune = append(prune, b)
+		}
+	}
+	return prune
+}
+
+// oldestRetainedBackup returns the start time of the oldest completed backup that is
+// not pruned, empty if there is none
+func oldestRetainedBackup(backups, pruned []v1alpha1.CassandraBackup) string {
+	isPruned := map[string]bool{}
+	for _, b := range pruned {
+		isPruned[b.Name] = true
+	}
+	for _, b := range backups {
+		if b.Status.Phase == v1alpha1.BackupPhaseCompleted && !isPruned[b.Name] {
+			return b.Status.StartedAt
+		}
+	}
+	return ""
+}
+
+// pruneContinuousBackup deletes the objects of the continuous backup of the cluster
+// shipped before the start of the oldest retained backup. A restore only replays the
+// commitlog segments shipped since the start of its backup
+func pruneContinuousBackup(api *v1alpha1.CassandraBackupSchedule, startedAt string) error {
+	before, err := time.Parse(time.RFC3339, startedAt)
+	if err != nil {
+		return nil
+	}
+	storage := api.Spec.Storage
+	client, err := storageClient(api.Namespace, storage)
+	if err != nil {
+		return err
+	}
+	objects, err := client.ListObjects(storage.Bucket, storage.Key(api.Spec.Cluster, "continuous")+"/")
+	if err != nil {
+		return err
+	}
+	var deleted int
+	for _, o := range objects {
+		if !o.LastModified.Before(before) {
+			continue
+		}
+		err = client.DeleteObject(storage.Bucket, o.Key)
+		if err != nil {
+			return err
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		logrus.Infof("Deleted %v objects of the continuous backup of %v shipped before %v", deleted, api.Spec.Cluster, startedAt)
+	}
+	return nil
+}
+
+// deleteBackup deletes the objects of the backup from the object storage and then
+// the backup from the cluster
+func deleteBackup(b *v1alpha1.CassandraBackup) error {
+	if len(b.Status.Name) > 0 {
+		storage := b.Spec.Storage
+		client, err := storageClient(b.Namespace, storage)
+		if err != nil {
+			return err
+		}
+		objects, err := client.ListObjects(storage.Bucket, storage.Key(b.Spec.Cluster, b.Status.Name)+"/")
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Deleting %v objects of backup %v", len(objects), b.Name)
+		for _, o := range objects {
+			err = client.DeleteObject(storage.Bucket, o.Key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err := sdk.Delete(cassandraBackup(b.Name, b.Namespace))
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
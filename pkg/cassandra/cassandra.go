@@ -1,11 +1,22 @@
 package cassandra
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
 	"github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/backupagent"
+	"github.com/camilocot/cassandra-operator/pkg/exec"
 	"github.com/operator-framework/operator-sdk/pkg/sdk"
 
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -14,12 +25,43 @@ import (
 	"k8s.io/kubernetes/pkg/util/pointer"
 )
 
+const (
+	// cassandraContainerName is the name of the container running cassandra
+	cassandraContainerName = "cassandra"
+	// backupAgentContainerName is the name of the sidecar shipping the continuous backup
+	backupAgentContainerName = "backup-agent"
+
+	// RestartedAtAnnotation is the pod template annotation changed to restart the pods
+	RestartedAtAnnotation = "database.camilocot/restartedAt"
+	// templateChecksumAnnotation is the statefulset annotation with the checksum of
+	// its pod template as built by the operator, before the defaults of the API server
+	templateChecksumAnnotation = "database.camilocot/templateChecksum"
+
+	// cassandraUID is the uid of the cassandra user of the default image, owning the
+	// files of the cassandra volume
+	cassandraUID = 999
+
+	// statefulSetPodNameLabel is the label with its name the statefulset controller
+	// sets on every pod
+	statefulSetPodNameLabel = "statefulset.kubernetes.io/pod-name"
+	// hostnameTopologyKey is the label of the kubernetes nodes with their hostname
+	hostnameTopologyKey = "kubernetes.io/hostname"
+	// tolerateUnreadyEndpointsAnnotation makes a service return the addresses of the
+	// unready pods too
+	tolerateUnreadyEndpointsAnnotation = "service.alpha.kubernetes.io/tolerate-unready-endpoints"
+	// managedAnnotationsAnnotation is the service annotation with the comma separated
+	// keys of the annotations set by the operator, the other ones are left untouched
+	managedAnnotationsAnnotation = "database.camilocot/managedAnnotations"
+)
+
 // StatefulSet returns a cassandra StatefulSet object
 func StatefulSet(api *v1alpha1.Cassandra) *appsv1.StatefulSet {
 	labels := labelsForCassandra(api.Name)
 	replicas := api.Spec.Size
-	partition := api.Spec.Partition
+	partition := partitionForCassandra(api)
+	gracePeriod := api.Spec.TerminationGracePeriodSeconds
 	storageClass := api.Spec.StorageClassName
+	trueVar := true
 	env := append(api.Spec.CassandraEnv, v1.EnvVar{
 		Name: "POD_IP",
 		ValueFrom: &v1.EnvVarSource{
@@ -71,14 +113,45 @@ func StatefulSet(api *v1alpha1.Cassandra) *appsv1.StatefulSet {
 
 			Template: v1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
+					Labels:      labels,
+					Annotations: podAnnotationsForCassandra(api),
 				},
 				Spec: v1.PodSpec{
+					TerminationGracePeriodSeconds: &gracePeriod,
+					NodeSelector:                  api.Spec.NodeSelector,
+					Tolerations:                   api.Spec.Tolerations,
+					Affinity:                      affinityForCassandra(api),
+					PriorityClassName:             api.Spec.PriorityClassName,
+					Volumes: []v1.Volume{
+						{
+							Name: "hosts",
+							VolumeSource: v1.VolumeSource{
+								ConfigMap: &v1.ConfigMapVolumeSource{
+									LocalObjectReference: v1.LocalObjectReference{
+										Name: hostsConfigMapName(api),
+									},
+									Optional: &trueVar,
+								},
+							},
+						},
+						{
+							Name: "tokens",
+							VolumeSource: v1.VolumeSource{
+								ConfigMap: &v1.ConfigMapVolumeSource{
+									LocalObjectReference: v1.LocalObjectReference{
+										Name: tokensConfigMapName(api),
+									},
+									Optional: &trueVar,
+								},
+							},
+						},
+					},
 					Containers: []v1.Container{
 						{
-							Name:  "cassandra",
-							Image: api.Spec.Repository + ":" + api.Spec.Version,
-							Env:   env,
+							Name:    cassandraContainerName,
+							Image:   api.Spec.Repository + ":" + api.Spec.Version,
+							Command: []string{"/sbin/dumb-init", "/bin/bash", "-c", runScript},
+							Env:     env,
 							Ports: []v1.ContainerPort{
 								{
 									Name:          "cql",
@@ -93,6 +166,22 @@ func StatefulSet(api *v1alpha1.Cassandra) *appsv1.StatefulSet {
 									ContainerPort: 7099,
 								},
 							},
+							VolumeMounts: []v1.VolumeMount{
+								{
+									Name:      "cassandra",
+									MountPath: cassandraDataPath,
+								},
+								{
+									Name:      "hosts",
+									MountPath: hostsPath,
+									ReadOnly:  true,
+								},
+								{
+									Name:      "tokens",
+									MountPath: tokensPath,
+									ReadOnly:  true,
+								},
+							},
 							SecurityContext: &v1.SecurityContext{
 								Capabilities: &v1.Capabilities{
 									Add: []v1.Capability{"IPC_LOCK"},
@@ -108,9 +197,14 @@ func StatefulSet(api *v1alpha1.Cassandra) *appsv1.StatefulSet {
 								TimeoutSeconds:      5,
 							},
 							Lifecycle: &v1.Lifecycle{
+								PostStart: &v1.Handler{
+									Exec: &v1.ExecAction{
+										Command: []string{"/bin/sh", "-c", startScript},
+									},
+								},
 								PreStop: &v1.Handler{
 									Exec: &v1.ExecAction{
-										Command: []string{"/bin/sh", "-c", "nodetool", "drain"},
+										Command: []string{"/bin/sh", "-c", drainScript},
 									},
 								},
 							},
@@ -120,10 +214,326 @@ func StatefulSet(api *v1alpha1.Cassandra) *appsv1.StatefulSet {
 			},
 		},
 	}
+	if cb := api.Spec.ContinuousBackup; cb != nil {
+		c := &stateful.Spec.Template.Spec.Containers[0]
+		c.Env = append(c.Env,
+			v1.EnvVar{Name: commitlogArchivingEnv, Value: strconv.FormatBool(cb.CommitlogArchiving)},
+		)
+		stateful.Spec.Template.Spec.Containers = append(stateful.Spec.Template.Spec.Containers, backupAgentContainer(api))
+	}
+	if auth := api.Spec.Auth; auth != nil {
+		c := &stateful.Spec.Template.Spec.Containers[0]
+		c.Env = append(c.Env,
+			v1.EnvVar{Name: authenticatorEnv, Value: auth.Authenticator},
+			v1.EnvVar{Name: authorizerEnv, Value: auth.Authorizer},
+		)
+		if api.PasswordAuthentication() {
+			c.Env = append(c.Env, credentialsEnvVars(auth.CredentialsSecret, usernameEnv, passwordEnv)...)
+		}
+	}
+	if cs := api.Spec.ClientService; cs != nil && cs.Thrift {
+		c := &stateful.Spec.Template.Spec.Containers[0]
+		c.Env = append(c.Env, v1.EnvVar{Name: startRPCEnv, Value: "true"})
+		c.Ports = append(c.Ports, v1.ContainerPort{Name: "thrift", ContainerPort: 9160})
+	}
+	if jmx := api.Spec.JMX; jmx != nil {
+		c := &stateful.Spec.Template.Spec.Containers[0]
+		c.Env = append(c.Env, credentialsEnvVars(jmx.CredentialsSecret, jmxUsernameEnv, jmxPasswordEnv)...)
+		c.Env = append(c.Env, v1.EnvVar{Name: jmxLocalOnlyEnv, Value: strconv.FormatBool(jmx.LocalOnly)})
+		c.ReadinessProbe.Exec.Command = []string{"/bin/sh", "-c", readyScript}
+		if jmx.LocalOnly {
+			var ports []v1.ContainerPort
+			for _, p := range c.Ports {
+				if p.Name != "jmx" {
+					ports = append(ports, p)
+				}
+			}
+			c.Ports = ports
+		}
+	}
+	if ea := api.Spec.ExternalAccess; ea != nil {
+		addExternalAccess(api, &stateful.Spec.Template.Spec)
+	}
+	if api.ClientTLS() {
+		addClientTLS(api, &stateful.Spec.Template.Spec)
+	}
+	if api.InternodeTLS() {
+		addKeystore(&stateful.Spec.Template.Spec, "internode-tls", internodeKeystoreSecretName(api), internodeTLSPath,
+			v1.EnvVar{Name: internodeEncryptionEnv, Value: "true"}, internodeStorePasswordEnv)
+	}
+	addExtraContainers(api, &stateful.Spec.Template.Spec)
+	if pt := api.Spec.PodTemplate; pt != nil {
+		mergePodTemplate(pt, &stateful.Spec.Template)
+	}
+	stateful.Annotations = map[string]string{templateChecksumAnnotation: templateChecksum(&stateful.Spec.Template)}
 	addOwnerRefToObject(stateful, asOwner(api))
 	return stateful
 }
 
+// templateChecksum returns the checksum of the pod template of a statefulset, it
+// changes when the pods are updated
+func templateChecksum(template *v1.PodTemplateSpec) string {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// addExtraContainers appends the sidecars, init containers, volumes and volume mounts
+// of the cassandra container of the spec to the generated pod spec
+func addExtraContainers(api *v1alpha1.Cassandra, spec *v1.PodSpec) {
+	spec.Containers = append(spec.Containers, api.Spec.Sidecars...)
+	spec.InitContainers = append(spec.InitContainers, api.Spec.InitContainers...)
+	spec.Volumes = append(spec.Volumes, api.Spec.Volumes...)
+	c := &spec.Containers[0]
+	c.VolumeMounts = append(c.VolumeMounts, api.Spec.VolumeMounts...)
+}
+
+// validatePodSpec returns an error if the names of the containers or volumes of the
+// pod spec are not unique, when the ones of the spec collide with the generated ones
+func validatePodSpec(spec *v1.PodSpec) error {
+	containers := map[string]bool{}
+	for _, c := range append(append([]v1.Container{}, spec.InitContainers...), spec.Containers...) {
+		if containers[c.Name] {
+			return fmt.Errorf("duplicate container name %q", c.Name)
+		}
+		containers[c.Name] = true
+	}
+	volumes := map[string]bool{}
+	for _, v := range spec.Volumes {
+		if volumes[v.Name] {
+			return fmt.Errorf("duplicate volume name %q", v.Name)
+		}
+		volumes[v.Name] = true
+	}
+	return nil
+}
+
+// mergePodTemplate merges the customization of the pods onto the generated pod
+// template. The labels and annotations of the cassandra-operator are kept, the other
+// fields are set when given
+func mergePodTemplate(pt *v1alpha1.PodTemplateSpec, template *v1.PodTemplateSpec) {
+	merge := func(generated, custom map[string]string) map[string]string {
+		merged := map[string]string{}
+		for k, v := range custom {
+			merged[k] = v
+		}
+		for k, v := range generated {
+			merged[k] = v
+		}
+		return merged
+	}
+	template.Labels = merge(template.Labels, pt.Labels)
+	template.Annotations = merge(template.Annotations, pt.Annotations)
+
+	spec := &template.Spec
+	if len(pt.ServiceAccountName) > 0 {
+		spec.ServiceAccountName = pt.ServiceAccountName
+	}
+	if len(pt.ImagePullSecrets) > 0 {
+		spec.ImagePullSecrets = pt.ImagePullSecrets
+	}
+	if pt.SecurityContext != nil {
+		spec.SecurityContext = pt.SecurityContext
+	}
+}
+
+// affinityForCassandra returns the affinity of the cassandra pods. Unless given, the
+// pod anti-affinity spreads the pods across the kubernetes nodes, so losing one only
+// stops a node of the cluster
+func affinityForCassandra(api *v1alpha1.Cassandra) *v1.Affinity {
+	affinity := &v1.Affinity{}
+	if api.Spec.Affinity != nil {
+		affinity = api.Spec.Affinity.DeepCopy()
+	}
+	if affinity.PodAntiAffinity != nil {
+		return affinity
+	}
+
+	term := v1.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: labelsForCassandra(api.Name),
+		},
+		TopologyKey: hostnameTopologyKey,
+	}
+	if api.Spec.StrictAntiAffinity {
+		affinity.PodAntiAffinity = &v1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{term},
+		}
+	} else {
+		affinity.PodAntiAffinity = &v1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{
+				{Weight: 100, PodAffinityTerm: term},
+			},
+		}
+	}
+	return affinity
+}
+
+// addExternalAccess sets the broadcast_rpc_address of the node to its external
+// address: the address of the kubernetes node of the pod for node ports, or the one of
+// the load balancer read from the mounted external addresses config map
+func addExternalAccess(api *v1alpha1.Cassandra, spec *v1.PodSpec) {
+	c := &spec.Containers[0]
+	if api.Spec.ExternalAccess.Type == v1.ServiceTypeNodePort {
+		c.Env = append(c.Env, v1.EnvVar{
+			Name: broadcastRPCAddressEnv,
+			ValueFrom: &v1.EnvVarSource{
+				FieldRef: &v1.ObjectFieldSelector{
+					FieldPath: "status.hostIP",
+				},
+			},
+		})
+		return
+	}
+	trueVar := true
+	spec.Volumes = append(spec.Volumes, v1.Volume{
+		Name: "external-addresses",
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{
+					Name: externalAddressesConfigMapName(api),
+				},
+				Optional: &trueVar,
+			},
+		},
+	})
+	c.VolumeMounts = append(c.VolumeMounts, v1.VolumeMount{
+		Name:      "external-addresses",
+		MountPath: externalAddressesPath,
+		ReadOnly:  true,
+	})
+	c.Env = append(c.Env, v1.EnvVar{Name: externalAccessEnv, Value: string(v1.ServiceTypeLoadBalancer)})
+}
+
+// addClientTLS mounts the keystore secret of the client TLS in the cassandra container,
+// with the environment enabling the client_encryption_options
+func addClientTLS(api *v1alpha1.Cassandra, spec *v1.PodSpec) {
+	addKeystore(spec, "client-tls", clientKeystoreSecretName(api), clientTLSPath,
+		v1.EnvVar{Name: clientEncryptionEnv, Value: "true"}, clientStorePasswordEnv)
+	c := &spec.Containers[0]
+	c.Env = append(c.Env, v1.EnvVar{Name: clientRequireAuthEnv, Value: strconv.FormatBool(api.Spec.TLS.Client.RequireClientAuth)})
+}
+
+// addKeystore mounts a keystore secret in the cassandra container, with the variable
+// enabling it and the one with the password of its keystores
+func addKeystore(spec *v1.PodSpec, volumeName, secretName, mountPath string, enable v1.EnvVar, passwordEnvName string) {
+	spec.Volumes = append(spec.Volumes, v1.Volume{
+		Name: volumeName,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: secretName},
+		},
+	})
+	c := &spec.Containers[0]
+	c.VolumeMounts = append(c.VolumeMounts, v1.VolumeMount{
+		Name:      volumeName,
+		MountPath: mountPath,
+		ReadOnly:  true,
+	})
+	c.Env = append(c.Env,
+		enable,
+		v1.EnvVar{
+			Name: passwordEnvName,
+			ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: secretName},
+					Key:                  storePasswordKey,
+				},
+			},
+		},
+	)
+}
+
+// backupAgentContainer returns the sidecar shipping the continuous backup of the node,
+// it shares the cassandra volume
+func backupAgentContainer(api *v1alpha1.Cassandra) v1.Container {
+	cb := api.Spec.ContinuousBackup
+	uid := cassandraUserForCassandra(api)
+	credential := func(name, key string) v1.EnvVar {
+		return v1.EnvVar{
+			Name: name,
+			ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: cb.Storage.CredentialsSecret},
+					Key:                  key,
+				},
+			},
+		}
+	}
+	return v1.Container{
+		Name:    backupAgentContainerName,
+		Image:   cb.Image,
+		Command: []string{"cassandra-backup-agent"},
+		Env: []v1.EnvVar{
+			{
+				Name: "POD_NAME",
+				ValueFrom: &v1.EnvVarSource{
+					FieldRef: &v1.ObjectFieldSelector{
+						FieldPath: "metadata.name",
+					},
+				},
+			},
+			{Name: backupagent.EnvEndpoint, Value: cb.Storage.Endpoint},
+			{Name: backupagent.EnvRegion, Value: cb.Storage.Region},
+			{Name: backupagent.EnvBucket, Value: cb.Storage.Bucket},
+			{Name: backupagent.EnvPrefix, Value: continuousBackupKey(cb.Storage, api.Name, "$(POD_NAME)")},
+			credential(backupagent.EnvAccessKeyID, v1alpha1.AccessKeyIDKey),
+			credential(backupagent.EnvSecretAccessKey, v1alpha1.SecretAccessKeyKey),
+			{Name: backupagent.EnvIntervalSeconds, Value: strconv.FormatInt(cb.IntervalSeconds, 10)},
+			{Name: backupagent.EnvCommitlogArchiveDirectory, Value: commitlogArchivePath},
+			{Name: backupagent.EnvRecoveryPointFile, Value: recoveryPointMarker},
+		},
+		VolumeMounts: []v1.VolumeMount{
+			{
+				Name:      "cassandra",
+				MountPath: cassandraDataPath,
+			},
+		},
+		// the agent removes the shipped files and writes the recovery point
+		SecurityContext: &v1.SecurityContext{
+			RunAsUser: &uid,
+		},
+	}
+}
+
+// cassandraUserForCassandra returns the uid cassandra runs as, the one of the security
+// context of the pod template when set
+func cassandraUserForCassandra(api *v1alpha1.Cassandra) int64 {
+	if pt := api.Spec.PodTemplate; pt != nil && pt.SecurityContext != nil && pt.SecurityContext.RunAsUser != nil {
+		return *pt.SecurityContext.RunAsUser
+	}
+	return cassandraUID
+}
+
+// credentialsEnvVars returns the environment variables with the username and password
+// of a credentials secret, so they are expanded in the container rather than being
+// part of the commands the cassandra-operator runs
+func credentialsEnvVars(secretName, usernameEnvName, passwordEnvName string) []v1.EnvVar {
+	credential := func(envName, key string) v1.EnvVar {
+		return v1.EnvVar{
+			Name: envName,
+			ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: secretName},
+					Key:                  key,
+				},
+			},
+		}
+	}
+	return []v1.EnvVar{
+		credential(usernameEnvName, v1alpha1.UsernameKey),
+		credential(passwordEnvName, v1alpha1.PasswordKey),
+	}
+}
+
+// continuousBackupKey returns the key of an object of the continuous backup of a
+// node of the cluster
+func continuousBackupKey(storage v1alpha1.BackupStorage, cluster, node string, elem ...string) string {
+	return storage.Key(append([]string{cluster, "continuous", node}, elem...)...)
+}
+
 // Service returns a cassandra Service object
 func Service(api *v1alpha1.Cassandra) *v1.Service {
 
@@ -140,7 +550,7 @@ func Service(api *v1alpha1.Cassandra) *v1.Service {
 			Namespace: api.Namespace,
 			// it will return IPs even of the unready pods. Bootstraping a new cluster need it
 			Annotations: map[string]string{
-				"service.alpha.kubernetes.io/tolerate-unready-endpoints": "true",
+				tolerateUnreadyEndpointsAnnotation: "true",
 			},
 		},
 		Spec: v1.ServiceSpec{
@@ -152,15 +562,287 @@ func Service(api *v1alpha1.Cassandra) *v1.Service {
 					Protocol:   v1.ProtocolTCP,
 				},
 			},
-			Selector:  labels,
-			ClusterIP: "None",
-			Type:      v1.ServiceTypeClusterIP,
+			Selector:        labels,
+			ClusterIP:       "None",
+			Type:            v1.ServiceTypeClusterIP,
+			SessionAffinity: v1.ServiceAffinityNone,
 		},
 	}
 	addOwnerRefToObject(svc, asOwner(api))
 	return svc
 }
 
+// ClientService returns the service of the clients, selecting only the ready pods
+func ClientService(api *v1alpha1.Cassandra) *v1.Service {
+	spec := api.Spec.ClientService
+	if spec == nil {
+		spec = &v1alpha1.ClientServiceSpec{Type: v1.ServiceTypeClusterIP}
+	}
+	labels := labelsForCassandra(api.Name)
+	port := func(name string, port int32) v1.ServicePort {
+		return v1.ServicePort{
+			Name:       name,
+			Port:       port,
+			TargetPort: intstr.FromInt(int(port)),
+			Protocol:   v1.ProtocolTCP,
+		}
+	}
+
+	svc := &v1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        api.Name,
+			Labels:      labels,
+			Namespace:   api.Namespace,
+			Annotations: spec.Annotations,
+		},
+		Spec: v1.ServiceSpec{
+			Ports:           []v1.ServicePort{port("cql", 9042)},
+			Selector:        labels,
+			Type:            spec.Type,
+			SessionAffinity: v1.ServiceAffinityNone,
+		},
+	}
+	if spec.Thrift {
+		svc.Spec.Ports = append(svc.Spec.Ports, port("thrift", 9160))
+	}
+	if spec.MetricsPort > 0 {
+		svc.Spec.Ports = append(svc.Spec.Ports, port("metrics", spec.MetricsPort))
+	}
+	if spec.Type != v1.ServiceTypeClusterIP {
+		svc.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeCluster
+	}
+	addOwnerRefToObject(svc, asOwner(api))
+	return svc
+}
+
+// NetworkPolicy returns the network policy of the pods of the cluster. The intra-node
+// ports are only allowed from the nodes, the client ports from the allowed clients
+// and the nodes, as sstableloader connects to the peers. JMX is not allowed, nodetool
+// runs inside the pods
+func NetworkPolicy(api *v1alpha1.Cassandra) *networkingv1.NetworkPolicy {
+	spec := api.Spec.NetworkPolicy
+	labels := labelsForCassandra(api.Name)
+	ports := func(ports ...int) []networkingv1.NetworkPolicyPort {
+		var nps []networkingv1.NetworkPolicyPort
+		for _, p := range ports {
+			protocol := v1.ProtocolTCP
+			port := intstr.FromInt(p)
+			nps = append(nps, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &port})
+		}
+		return nps
+	}
+	nodes := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: labels}}
+
+	clientPorts := []int{9042}
+	if cs := api.Spec.ClientService; cs != nil {
+		if cs.Thrift {
+			clientPorts = append(clientPorts, 9160)
+		}
+		if cs.MetricsPort > 0 {
+			clientPorts = append(clientPorts, int(cs.MetricsPort))
+		}
+	}
+	var clients []networkingv1.NetworkPolicyPeer
+	if len(spec.ClientsFrom) > 0 {
+		clients = append([]networkingv1.NetworkPolicyPeer{nodes}, spec.ClientsFrom...)
+	}
+
+	np := &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      api.Name,
+			Labels:    labels,
+			Namespace: api.Namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: labels},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					Ports: ports(7000, 7001),
+					From:  []networkingv1.NetworkPolicyPeer{nodes},
+				},
+				{
+					Ports: ports(clientPorts...),
+					From:  clients,
+				},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+	addOwnerRefToObject(np, asOwner(api))
+	return np
+}
+
+// PodDisruptionBudget returns the pod disruption budget of the cluster, allowing
+// maxUnavailable pods to be unavailable at once
+func PodDisruptionBudget(api *v1alpha1.Cassandra, maxUnavailable int32) *policyv1beta1.PodDisruptionBudget {
+	labels := labelsForCassandra(api.Name)
+	max := intstr.FromInt(int(maxUnavailable))
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "policy/v1beta1",
+			Kind:       "PodDisruptionBudget",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      api.Name,
+			Labels:    labels,
+			Namespace: api.Namespace,
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &max,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+		},
+	}
+	addOwnerRefToObject(pdb, asOwner(api))
+	return pdb
+}
+
+// ExternalService returns the service exposing the node of a pod outside the
+// kubernetes cluster. It selects the pod even when it is not ready, as a node behind a
+// load balancer only starts once its address is allocated
+func ExternalService(api *v1alpha1.Cassandra, ordinal int32) *v1.Service {
+	spec := api.Spec.ExternalAccess
+	podName := podNameForCassandra(api, ordinal)
+	selector := labelsForCassandra(api.Name)
+	selector[statefulSetPodNameLabel] = podName
+	annotations := map[string]string{tolerateUnreadyEndpointsAnnotation: "true"}
+	for k, v := range spec.Annotations {
+		annotations[k] = v
+	}
+
+	svc := &v1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        externalServiceName(podName),
+			Labels:      labelsForCassandra(api.Name),
+			Namespace:   api.Namespace,
+			Annotations: annotations,
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{
+				{
+					Name:       "cql",
+					Port:       9042,
+					TargetPort: intstr.FromInt(9042),
+					Protocol:   v1.ProtocolTCP,
+				},
+			},
+			Selector:              selector,
+			Type:                  spec.Type,
+			SessionAffinity:       v1.ServiceAffinityNone,
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeCluster,
+		},
+	}
+	addOwnerRefToObject(svc, asOwner(api))
+	return svc
+}
+
+// externalServiceName returns the name of the external service of a pod
+func externalServiceName(podName string) string {
+	return podName + "-external"
+}
+
+// ExternalAddressesConfigMap returns the config map with the address of the load
+// balancer of every pod, read by the node on start
+func ExternalAddressesConfigMap(api *v1alpha1.Cassandra) *v1.ConfigMap {
+	cm := &v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      externalAddressesConfigMapName(api),
+			Labels:    labelsForCassandra(api.Name),
+			Namespace: api.Namespace,
+		},
+	}
+	addOwnerRefToObject(cm, asOwner(api))
+	return cm
+}
+
+// externalAddressesConfigMapName returns the name of the external addresses config map
+func externalAddressesConfigMapName(api *v1alpha1.Cassandra) string {
+	return api.Name + "-external-addresses"
+}
+
+// HostsConfigMap returns the config map with the last known address of the node of
+// every pod, used to replace the node when the pod has lost its volume
+func HostsConfigMap(api *v1alpha1.Cassandra) *v1.ConfigMap {
+	cm := &v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      hostsConfigMapName(api),
+			Labels:    labelsForCassandra(api.Name),
+			Namespace: api.Namespace,
+		},
+	}
+	addOwnerRefToObject(cm, asOwner(api))
+	return cm
+}
+
+// hostsConfigMapName returns the name of the hosts config map
+func hostsConfigMapName(api *v1alpha1.Cassandra) string {
+	return api.Name + "-hosts"
+}
+
+// TokensConfigMap returns the config map with the tokens of the node of every pod of
+// a cluster created by a restore. It has no owner until the cluster is created
+func TokensConfigMap(api *v1alpha1.Cassandra) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      tokensConfigMapName(api),
+			Labels:    labelsForCassandra(api.Name),
+			Namespace: api.Namespace,
+		},
+	}
+}
+
+// tokensConfigMapName returns the name of the tokens config map
+func tokensConfigMapName(api *v1alpha1.Cassandra) string {
+	return api.Name + "-tokens"
+}
+
+// podAnnotationsForCassandra returns the annotations of the cassandra pods
+func podAnnotationsForCassandra(api *v1alpha1.Cassandra) map[string]string {
+	annotations := map[string]string{
+		// sidecars can be added, commands are run in the cassandra container
+		exec.DefaultContainerAnnotation: cassandraContainerName,
+	}
+	if len(api.RestartRequestedAt()) > 0 {
+		annotations[RestartedAtAnnotation] = api.RestartRequestedAt()
+	}
+	return annotations
+}
+
+// partitionForCassandra returns the statefulset partition. During a rolling restart
+// it is the lowest ordinal allowed to be restarted
+func partitionForCassandra(api *v1alpha1.Cassandra) int32 {
+	rs := api.Status.Restart
+	if rs.IsInProgress(api.RestartRequestedAt()) && rs.Ordinal > api.Spec.Partition {
+		return rs.Ordinal
+	}
+	return api.Spec.Partition
+}
+
 // labelsForCassadnra returns the labels for selecting the resources
 // belonging to the given casandra CR name.
 func labelsForCassandra(name string) map[string]string {
@@ -197,6 +879,174 @@ func podList() *v1.PodList {
 	}
 }
 
+// pod returns a v1.Pod object
+func pod(name, namespace string) *v1.Pod {
+	return &v1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Pod",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+// secret returns a v1.Secret object
+func secret(name, namespace string) *v1.Secret {
+	return &v1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+// service returns a v1.Service object
+func service(name, namespace string) *v1.Service {
+	return &v1.Service{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+// networkPolicy returns a networkingv1.NetworkPolicy object
+func networkPolicy(name, namespace string) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "NetworkPolicy",
+			APIVersion: "networking.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+// configMap returns a v1.ConfigMap object
+func configMap(name, namespace string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+// cassandra returns a v1alpha1.Cassandra object
+func cassandra(name, namespace string) *v1alpha1.Cassandra {
+	return &v1alpha1.Cassandra{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Cassandra",
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+// cassandraBackup returns a v1alpha1.CassandraBackup object
+func cassandraBackup(name, namespace string) *v1alpha1.CassandraBackup {
+	return &v1alpha1.CassandraBackup{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "CassandraBackup",
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
+// volumeNameForPod returns the name of the persistent volume claim of the pod
+func volumeNameForPod(podName string) string {
+	return "cassandra-" + podName
+}
+
+// podNameForCassandra returns the name of the pod with the given ordinal
+func podNameForCassandra(api *v1alpha1.Cassandra, ordinal int32) string {
+	return fmt.Sprintf("%s-%d", api.Name, ordinal)
+}
+
+// isPodReady returns if the cassandra container of the pod is ready, the readiness of
+// the sidecars does not prevent managing the node
+func isPodReady(pod *v1.Pod) bool {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == cassandraContainerName {
+			return cs.Ready
+		}
+	}
+	return false
+}
+
+// containerStartedAt returns the start time of the named container, empty if it is not running
+func containerStartedAt(pod *v1.Pod, containerName string) string {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == containerName && cs.State.Running != nil {
+			return cs.State.Running.StartedAt.Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+// readyPodForCluster returns the cluster and the name of its first ready pod, empty
+// if none is ready
+func readyPodForCluster(name, namespace string) (*v1alpha1.Cassandra, string, error) {
+	cluster := cassandra(name, namespace)
+	err := sdk.Get(cluster)
+	if err != nil {
+		return nil, "", err
+	}
+	pods, err := podsForCassandra(cluster)
+	if err != nil {
+		return cluster, "", err
+	}
+	return cluster, readyPodName(pods), nil
+}
+
+// pvcList returns a v1.PersistentVolumeClaimList object
+func pvcList() *v1.PersistentVolumeClaimList {
+	return &v1.PersistentVolumeClaimList{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+		},
+	}
+}
+
+// pvc returns a v1.PersistentVolumeClaim object
+func pvc(name, namespace string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
 // getPodNames returns the pod names of the array of pods passed in
 func getPodNames(pods []v1.Pod) []string {
 	var podNames []string
@@ -206,7 +1056,8 @@ func getPodNames(pods []v1.Pod) []string {
 	return podNames
 }
 
-func nodesForCassandra(api *v1alpha1.Cassandra) ([]string, error) {
+// podsForCassandra returns the pods of the cassandra cluster
+func podsForCassandra(api *v1alpha1.Cassandra) ([]v1.Pod, error) {
 	podList := podList()
 	labelSelector := labels.SelectorFromSet(labelsForCassandra(api.Name)).String()
 	listOps := &metav1.ListOptions{LabelSelector: labelSelector}
@@ -214,6 +1065,31 @@ func nodesForCassandra(api *v1alpha1.Cassandra) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	podNames := getPodNames(podList.Items)
+	return podList.Items, nil
+}
+
+func nodesForCassandra(api *v1alpha1.Cassandra) ([]string, error) {
+	pods, err := podsForCassandra(api)
+	if err != nil {
+		return nil, err
+	}
+	podNames := getPodNames(pods)
 	return podNames, nil
 }
+
+// volumesForCassandra returns the names of the persistent volume claims created
+// by the statefulset, which are labeled with its selector
+func volumesForCassandra(api *v1alpha1.Cassandra) ([]string, error) {
+	pvcList := pvcList()
+	labelSelector := labels.SelectorFromSet(labelsForCassandra(api.Name)).String()
+	listOps := &metav1.ListOptions{LabelSelector: labelSelector}
+	err := sdk.List(api.Namespace, pvcList, sdk.WithListOptions(listOps))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, pvc := range pvcList.Items {
+		names = append(names, pvc.Name)
+	}
+	return names, nil
+}
@@ -1,12 +1,28 @@
 package cassandra
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
 	"testing"
+	"time"
 
 	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/cql"
+	"github.com/camilocot/cassandra-operator/pkg/exec"
+	"github.com/camilocot/cassandra-operator/pkg/nodetool"
+	"github.com/camilocot/cassandra-operator/pkg/util/cron"
+	"github.com/camilocot/cassandra-operator/pkg/util/pki"
+	"github.com/camilocot/cassandra-operator/pkg/util/s3"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -23,11 +39,12 @@ func NewCassandra() *v1alpha1.Cassandra {
 			Namespace: "default",
 		},
 		Spec: v1alpha1.CassandraSpec{
-			Size:             2,
-			Repository:       "repository",
-			Version:          "version",
-			Partition:        1,
-			StorageClassName: "storageClassName",
+			Size:                          2,
+			Repository:                    "repository",
+			Version:                       "version",
+			Partition:                     1,
+			StorageClassName:              "storageClassName",
+			TerminationGracePeriodSeconds: 900,
 			CassandraEnv: []v1.EnvVar{
 				{
 					Name:  "Env1",
@@ -59,6 +76,7 @@ func TestStatefulSet(t *testing.T) {
 	assert.Equal(t, cs.Spec.Partition, *st.Spec.UpdateStrategy.RollingUpdate.Partition)
 
 	assert.Equal(t, 1, len(pod.Containers))
+	assert.Equal(t, map[string]string{exec.DefaultContainerAnnotation: "cassandra"}, st.Spec.Template.Annotations)
 
 	c := pod.Containers[0]
 	assert.Equal(t, cs.Spec.Repository+":"+cs.Spec.Version, c.Image)
@@ -83,7 +101,28 @@ func TestStatefulSet(t *testing.T) {
 	assert.Equal(t, []string{"/bin/bash", "-c", "/ready-probe.sh"}, c.ReadinessProbe.Handler.Exec.Command)
 	assert.Equal(t, int32(15), c.ReadinessProbe.InitialDelaySeconds)
 	assert.Equal(t, int32(5), c.ReadinessProbe.TimeoutSeconds)
-	assert.Equal(t, []string{"/bin/sh", "-c", "nodetool", "drain"}, c.Lifecycle.PreStop.Exec.Command)
+	assert.Equal(t, []string{"/bin/sh", "-c", drainScript}, c.Lifecycle.PreStop.Exec.Command)
+	assert.Equal(t, []string{"/bin/sh", "-c", startScript}, c.Lifecycle.PostStart.Exec.Command)
+	assert.Equal(t, cs.Spec.TerminationGracePeriodSeconds, *pod.TerminationGracePeriodSeconds)
+	assert.Equal(t, []string{"/sbin/dumb-init", "/bin/bash", "-c", runScript}, c.Command)
+	assert.Equal(t, []v1.VolumeMount{
+		{
+			Name:      "cassandra",
+			MountPath: "/cassandra_data",
+		},
+		{
+			Name:      "hosts",
+			MountPath: "/etc/cassandra-hosts",
+			ReadOnly:  true,
+		},
+		{
+			Name:      "tokens",
+			MountPath: "/etc/cassandra-tokens",
+			ReadOnly:  true,
+		}}, c.VolumeMounts)
+	assert.Equal(t, 2, len(pod.Volumes))
+	assert.Equal(t, cs.Name+"-hosts", pod.Volumes[0].ConfigMap.Name)
+	assert.Equal(t, cs.Name+"-tokens", pod.Volumes[1].ConfigMap.Name)
 	assert.Equal(t, append(cs.Spec.CassandraEnv, v1.EnvVar{
 		Name: "POD_IP",
 		ValueFrom: &v1.EnvVarSource{
@@ -111,6 +150,558 @@ func TestStatefulSet(t *testing.T) {
 	}, st.OwnerReferences[0])
 }
 
+func TestStatefulSetRestart(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.Size = 3
+	cs.Spec.RestartRequestedAt = "2018-07-01T10:00:00Z"
+	cs.Status.Restart = &v1alpha1.RestartStatus{
+		RequestedAt: cs.Spec.RestartRequestedAt,
+		Ordinal:     2,
+	}
+
+	st := StatefulSet(cs)
+	assert.Equal(t, cs.Spec.RestartRequestedAt, st.Spec.Template.Annotations[RestartedAtAnnotation])
+	assert.Equal(t, int32(2), *st.Spec.UpdateStrategy.RollingUpdate.Partition)
+
+	cs.Status.Restart.Completed = true
+	st = StatefulSet(cs)
+	assert.Equal(t, cs.Spec.RestartRequestedAt, st.Spec.Template.Annotations[RestartedAtAnnotation])
+	assert.Equal(t, cs.Spec.Partition, *st.Spec.UpdateStrategy.RollingUpdate.Partition)
+
+	cs.Status.Restart = &v1alpha1.RestartStatus{RequestedAt: "2018-06-01T10:00:00Z", Ordinal: 3}
+	st = StatefulSet(cs)
+	assert.Equal(t, cs.Spec.Partition, *st.Spec.UpdateStrategy.RollingUpdate.Partition)
+}
+
+func TestRestartRequestedAt(t *testing.T) {
+	cs := NewCassandra()
+	assert.Empty(t, cs.RestartRequestedAt())
+
+	cs.Spec.RestartRequestedAt = "2018-07-01T10:00:00Z"
+	assert.Equal(t, "2018-07-01T10:00:00Z", cs.RestartRequestedAt())
+
+	// requested by the operator after the user
+	cs.Status.RestartRequestedAt = "2018-07-02T10:00:00Z"
+	assert.Equal(t, "2018-07-02T10:00:00Z", cs.RestartRequestedAt())
+	st := StatefulSet(cs)
+	assert.Equal(t, "2018-07-02T10:00:00Z", st.Spec.Template.Annotations[RestartedAtAnnotation])
+
+	cs.Spec.RestartRequestedAt = "2018-07-03T10:00:00Z"
+	assert.Equal(t, "2018-07-03T10:00:00Z", cs.RestartRequestedAt())
+
+	cs.Spec.RestartRequestedAt = "now"
+	assert.Equal(t, "2018-07-02T10:00:00Z", cs.RestartRequestedAt())
+}
+
+func TestStatefulSetScheduling(t *testing.T) {
+	cs := NewCassandra()
+	term := v1.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{MatchLabels: labelsForCassandra(cs.Name)},
+		TopologyKey:   "kubernetes.io/hostname",
+	}
+	spec := StatefulSet(cs).Spec.Template.Spec
+	assert.Equal(t, &v1.Affinity{PodAntiAffinity: &v1.PodAntiAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{{Weight: 100, PodAffinityTerm: term}},
+	}}, spec.Affinity)
+	assert.Nil(t, spec.NodeSelector)
+	assert.Nil(t, spec.Tolerations)
+
+	cs.Spec.StrictAntiAffinity = true
+	cs.Spec.NodeSelector = map[string]string{"pool": "cassandra"}
+	cs.Spec.Tolerations = []v1.Toleration{{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "cassandra", Effect: v1.TaintEffectNoSchedule}}
+	cs.Spec.PriorityClassName = "database"
+	nodeAffinity := &v1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+		NodeSelectorTerms: []v1.NodeSelectorTerm{{MatchExpressions: []v1.NodeSelectorRequirement{
+			{Key: "failure-domain.beta.kubernetes.io/zone", Operator: v1.NodeSelectorOpIn, Values: []string{"eu-west-1a"}},
+		}}},
+	}}
+	cs.Spec.Affinity = &v1.Affinity{NodeAffinity: nodeAffinity}
+	spec = StatefulSet(cs).Spec.Template.Spec
+	assert.Equal(t, &v1.Affinity{NodeAffinity: nodeAffinity, PodAntiAffinity: &v1.PodAntiAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{term},
+	}}, spec.Affinity)
+	assert.Nil(t, cs.Spec.Affinity.PodAntiAffinity)
+	assert.Equal(t, cs.Spec.NodeSelector, spec.NodeSelector)
+	assert.Equal(t, cs.Spec.Tolerations, spec.Tolerations)
+	assert.Equal(t, "database", spec.PriorityClassName)
+
+	antiAffinity := &v1.PodAntiAffinity{RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
+		{LabelSelector: term.LabelSelector, TopologyKey: "failure-domain.beta.kubernetes.io/zone"},
+	}}
+	cs.Spec.Affinity.PodAntiAffinity = antiAffinity
+	assert.Equal(t, antiAffinity, StatefulSet(cs).Spec.Template.Spec.Affinity.PodAntiAffinity)
+}
+
+func TestStatefulSetPodTemplate(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.RestartRequestedAt = "2018-07-01T10:00:00Z"
+	fsGroup := int64(999)
+	cs.Spec.PodTemplate = &v1alpha1.PodTemplateSpec{
+		Labels:             map[string]string{"cost-center": "data", "app": "other"},
+		Annotations:        map[string]string{"sidecar.istio.io/inject": "false", RestartedAtAnnotation: "never"},
+		ServiceAccountName: "cassandra",
+		ImagePullSecrets:   []v1.LocalObjectReference{{Name: "registry"}},
+		SecurityContext:    &v1.PodSecurityContext{FSGroup: &fsGroup},
+	}
+
+	st := StatefulSet(cs)
+	template := st.Spec.Template
+	assert.Equal(t, map[string]string{"app": "cassandra", "cassandra_cr": cs.Name, "cost-center": "data"}, template.Labels)
+	assert.Equal(t, labelsForCassandra(cs.Name), st.Spec.Selector.MatchLabels)
+	assert.Equal(t, "false", template.Annotations["sidecar.istio.io/inject"])
+	assert.Equal(t, cs.Spec.RestartRequestedAt, template.Annotations[RestartedAtAnnotation])
+	assert.Equal(t, cassandraContainerName, template.Annotations[exec.DefaultContainerAnnotation])
+	assert.Equal(t, "cassandra", template.Spec.ServiceAccountName)
+	assert.Equal(t, []v1.LocalObjectReference{{Name: "registry"}}, template.Spec.ImagePullSecrets)
+	assert.Equal(t, &fsGroup, template.Spec.SecurityContext.FSGroup)
+	assert.Equal(t, []v1.Capability{"IPC_LOCK"}, template.Spec.Containers[0].SecurityContext.Capabilities.Add)
+}
+
+func TestStatefulSetSidecars(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.Sidecars = []v1.Container{{Name: "fluent-bit", Image: "fluent/fluent-bit"}}
+	cs.Spec.InitContainers = []v1.Container{{Name: "sysctl", Image: "busybox", Command: []string{"sysctl", "-w", "vm.max_map_count=1048575"}}}
+	cs.Spec.Volumes = []v1.Volume{{Name: "logs", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}}}
+	cs.Spec.VolumeMounts = []v1.VolumeMount{{Name: "logs", MountPath: "/var/log/cassandra"}}
+
+	spec := StatefulSet(cs).Spec.Template.Spec
+	assert.Equal(t, []string{cassandraContainerName, "fluent-bit"}, []string{spec.Containers[0].Name, spec.Containers[1].Name})
+	assert.Equal(t, cs.Spec.InitContainers, spec.InitContainers)
+	assert.Equal(t, cs.Spec.Volumes[0], spec.Volumes[len(spec.Volumes)-1])
+	c := spec.Containers[0]
+	assert.Equal(t, cs.Spec.VolumeMounts[0], c.VolumeMounts[len(c.VolumeMounts)-1])
+	assert.Nil(t, validatePodSpec(&spec))
+
+	cs.Spec.Sidecars = append(cs.Spec.Sidecars, v1.Container{Name: "sysctl"})
+	spec = StatefulSet(cs).Spec.Template.Spec
+	assert.EqualError(t, validatePodSpec(&spec), `duplicate container name "sysctl"`)
+	cs.Spec.Sidecars = nil
+	cs.Spec.Volumes = append(cs.Spec.Volumes, v1.Volume{Name: "hosts"})
+	spec = StatefulSet(cs).Spec.Template.Spec
+	assert.EqualError(t, validatePodSpec(&spec), `duplicate volume name "hosts"`)
+}
+
+func TestIsPodReady(t *testing.T) {
+	p := &v1.Pod{Status: v1.PodStatus{
+		Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionFalse}},
+		ContainerStatuses: []v1.ContainerStatus{
+			{Name: "fluent-bit", Ready: false},
+			{Name: cassandraContainerName, Ready: true},
+		},
+	}}
+	assert.True(t, isPodReady(p))
+	p.Status.ContainerStatuses[1].Ready = false
+	assert.False(t, isPodReady(p))
+	assert.False(t, isPodReady(&v1.Pod{}))
+}
+
+func TestHostsConfigMap(t *testing.T) {
+	cs := NewCassandra()
+	cm := HostsConfigMap(cs)
+
+	assert.Equal(t, cs.Name+"-hosts", cm.Name)
+	assert.Equal(t, cs.Namespace, cm.Namespace)
+	assert.Equal(t, labelsForCassandra(cs.Name), cm.Labels)
+	assert.Equal(t, 1, len(cm.OwnerReferences))
+}
+
+func TestStatefulSetContinuousBackup(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.ContinuousBackup = &v1alpha1.ContinuousBackupSpec{
+		Storage: v1alpha1.BackupStorage{
+			Endpoint:          "http://minio:9000",
+			Bucket:            "backups",
+			Prefix:            "prod",
+			CredentialsSecret: "backup-credentials",
+		},
+		CommitlogArchiving: true,
+		Image:              "camilocot/operator:v0.0.1",
+		IntervalSeconds:    30,
+	}
+	pod := StatefulSet(cs).Spec.Template.Spec
+
+	assert.Equal(t, 2, len(pod.Containers))
+	env := pod.Containers[0].Env
+	assert.Equal(t, v1.EnvVar{Name: "CASSANDRA_COMMITLOG_ARCHIVING", Value: "true"}, env[len(env)-1])
+
+	agent := pod.Containers[1]
+	assert.Equal(t, "backup-agent", agent.Name)
+	assert.Equal(t, "camilocot/operator:v0.0.1", agent.Image)
+	assert.Equal(t, []v1.VolumeMount{{Name: "cassandra", MountPath: "/cassandra_data"}}, agent.VolumeMounts)
+	vars := map[string]string{}
+	for _, e := range agent.Env {
+		vars[e.Name] = e.Value
+	}
+	assert.Equal(t, "prod/"+cs.Name+"/continuous/$(POD_NAME)", vars["STORAGE_PREFIX"])
+	assert.Equal(t, "30", vars["SHIP_INTERVAL_SECONDS"])
+	assert.Equal(t, "/cassandra_data/commitlog_archive", vars["COMMITLOG_ARCHIVE_DIRECTORY"])
+	// the agent removes the files written by cassandra
+	assert.Equal(t, int64(999), *agent.SecurityContext.RunAsUser)
+
+	uid := int64(1000)
+	cs.Spec.PodTemplate = &v1alpha1.PodTemplateSpec{SecurityContext: &v1.PodSecurityContext{RunAsUser: &uid}}
+	agent = StatefulSet(cs).Spec.Template.Spec.Containers[1]
+	assert.Equal(t, uid, *agent.SecurityContext.RunAsUser)
+}
+
+func TestStatefulSetAuth(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.Auth = &v1alpha1.AuthSpec{
+		Authenticator:     v1alpha1.PasswordAuthenticator,
+		Authorizer:        v1alpha1.CassandraAuthorizer,
+		CredentialsSecret: "cassandra-admin",
+	}
+	env := StatefulSet(cs).Spec.Template.Spec.Containers[0].Env
+
+	assert.Equal(t, v1.EnvVar{Name: "CASSANDRA_AUTHENTICATOR", Value: "PasswordAuthenticator"}, env[len(env)-4])
+	assert.Equal(t, v1.EnvVar{Name: "CASSANDRA_AUTHORIZER", Value: "CassandraAuthorizer"}, env[len(env)-3])
+	assert.Equal(t, "CASSANDRA_OPERATOR_PASSWORD", env[len(env)-1].Name)
+	assert.Equal(t, "cassandra-admin", env[len(env)-1].ValueFrom.SecretKeyRef.Name)
+	assert.Equal(t, "password", env[len(env)-1].ValueFrom.SecretKeyRef.Key)
+
+	assert.Equal(t, []string{"sh", "-c", `exec cqlsh -u "$CASSANDRA_OPERATOR_USERNAME" -p "$CASSANDRA_OPERATOR_PASSWORD" "$@"`, "cqlsh", "-e", "DESCRIBE SCHEMA"},
+		cqlshCommand(cs, "-e", "DESCRIBE SCHEMA"))
+
+	cs.Spec.Auth = nil
+	assert.Equal(t, []string{"cqlsh", "-e", "DESCRIBE SCHEMA"}, cqlshCommand(cs, "-e", "DESCRIBE SCHEMA"))
+}
+
+func TestStatefulSetJMX(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.JMX = &v1alpha1.JMXSpec{}
+	cs.SetDefaults()
+	assert.Equal(t, "example-jmx", cs.Spec.JMX.CredentialsSecret)
+
+	c := StatefulSet(cs).Spec.Template.Spec.Containers[0]
+	env := c.Env
+	assert.Equal(t, "CASSANDRA_JMX_USERNAME", env[len(env)-3].Name)
+	assert.Equal(t, "example-jmx", env[len(env)-3].ValueFrom.SecretKeyRef.Name)
+	assert.Equal(t, "CASSANDRA_JMX_PASSWORD", env[len(env)-2].Name)
+	assert.Equal(t, v1.EnvVar{Name: "CASSANDRA_JMX_LOCAL_ONLY", Value: "false"}, env[len(env)-1])
+	assert.Equal(t, []string{"/bin/sh", "-c", `nodetool ${CASSANDRA_JMX_USERNAME:+-u "$CASSANDRA_JMX_USERNAME" -pwf /cassandra_data/jmxremote.password} status | grep -E "^UN +$POD_IP "`},
+		c.ReadinessProbe.Exec.Command)
+	assert.Contains(t, c.Lifecycle.PreStop.Exec.Command[2], `nodetool ${CASSANDRA_JMX_USERNAME:+-u "$CASSANDRA_JMX_USERNAME" -pwf /cassandra_data/jmxremote.password} drain`)
+	assert.Len(t, c.Ports, 3)
+
+	assert.Equal(t, []string{"nodetool", "-u", `"$CASSANDRA_JMX_USERNAME"`, "-pwf", "/cassandra_data/jmxremote.password", "cleanup", "ks"},
+		nodetoolCommand(cs, "cleanup", "ks"))
+
+	cs.Spec.JMX.LocalOnly = true
+	c = StatefulSet(cs).Spec.Template.Spec.Containers[0]
+	assert.Len(t, c.Ports, 2)
+	assert.Equal(t, v1.EnvVar{Name: "CASSANDRA_JMX_LOCAL_ONLY", Value: "true"}, c.Env[len(c.Env)-1])
+
+	cs.Spec.JMX = nil
+	assert.Equal(t, []string{"nodetool", "cleanup", "ks"}, nodetoolCommand(cs, "cleanup", "ks"))
+}
+
+func TestSystemAuthReplication(t *testing.T) {
+	ring := nodetool.Ring{{Datacenter: "dc1"}, {Datacenter: "dc1"}, {Datacenter: "dc2"}}
+	desired := systemAuthReplication(ring)
+	assert.Equal(t, replication{strategy: v1alpha1.NetworkTopologyStrategy, factors: map[string]int32{"dc1": 2, "dc2": 1}}, desired)
+
+	assert.False(t, replicasRemoved(replication{strategy: v1alpha1.SimpleStrategy, factors: map[string]int32{"replication_factor": 1}}, desired))
+	assert.True(t, replicasRemoved(replication{strategy: v1alpha1.NetworkTopologyStrategy, factors: map[string]int32{"dc1": 3}}, desired))
+
+	password, err := randomPassword()
+	assert.Nil(t, err)
+	assert.Regexp(t, "^[0-9a-f]{48}$", password)
+}
+
+func TestPodsHaveCredentials(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.Auth = &v1alpha1.AuthSpec{}
+	cs.SetDefaults()
+	assert.Equal(t, cs.Name+"-admin", cs.Spec.Auth.CredentialsSecret)
+
+	withCredentials := v1.Pod{Spec: StatefulSet(cs).Spec.Template.Spec}
+	cs.Spec.Auth = nil
+	withoutCredentials := v1.Pod{Spec: StatefulSet(cs).Spec.Template.Spec}
+
+	assert.True(t, podsHaveCredentials([]v1.Pod{withCredentials, withCredentials}))
+	assert.False(t, podsHaveCredentials([]v1.Pod{withCredentials, withoutCredentials}))
+}
+
+func TestStatefulSetClientTLS(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.TLS = &v1alpha1.TLSSpec{Client: &v1alpha1.ClientTLSSpec{Secret: "example-tls", RequireClientAuth: true}}
+	spec := StatefulSet(cs).Spec.Template.Spec
+	c := spec.Containers[0]
+
+	assert.Equal(t, "example-client-keystore", spec.Volumes[len(spec.Volumes)-1].Secret.SecretName)
+	assert.Equal(t, v1.VolumeMount{Name: "client-tls", MountPath: "/etc/cassandra-client-tls", ReadOnly: true}, c.VolumeMounts[len(c.VolumeMounts)-1])
+	assert.Equal(t, v1.EnvVar{Name: "CASSANDRA_CLIENT_ENCRYPTION", Value: "true"}, c.Env[len(c.Env)-3])
+	assert.Equal(t, "example-client-keystore", c.Env[len(c.Env)-2].ValueFrom.SecretKeyRef.Name)
+	assert.Equal(t, "storePassword", c.Env[len(c.Env)-2].ValueFrom.SecretKeyRef.Key)
+	assert.Equal(t, v1.EnvVar{Name: "CASSANDRA_CLIENT_REQUIRE_AUTH", Value: "true"}, c.Env[len(c.Env)-1])
+
+	assert.Equal(t, []string{"cqlsh", "--ssl", "--cqlshrc=/cassandra_data/cqlshrc", "-e", "DESCRIBE SCHEMA"}, cqlshCommand(cs, "-e", "DESCRIBE SCHEMA"))
+	assert.Equal(t, []string{"sstableloader",
+		"-ts", "/etc/cassandra-client-tls/truststore.jks", "-tspw", `"$CASSANDRA_CLIENT_STORE_PASSWORD"`,
+		"-ks", "/etc/cassandra-client-tls/keystore.jks", "-kspw", `"$CASSANDRA_CLIENT_STORE_PASSWORD"`,
+		"-d", "10.0.0.1", "/restore"}, sstableloaderCommand(cs, "-d", "10.0.0.1", "/restore"))
+
+	assert.Equal(t, "example.default.svc.cluster.local", clientDNSNames(cs)[0])
+	assert.Contains(t, clientDNSNames(cs), "example-unready.default.svc.cluster.local")
+	assert.Contains(t, clientDNSNames(cs), "*.example-unready.default.svc.cluster.local")
+}
+
+// newTLSSecret returns a kubernetes.io/tls secret with a self-signed certificate
+func newTLSSecret(t *testing.T, name string) *v1.Secret {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	s := secret(name, "default")
+	s.Data = map[string][]byte{
+		"tls.crt": cert,
+		"tls.key": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		"ca.crt":  cert,
+	}
+	return s
+}
+
+func TestKeystoreData(t *testing.T) {
+	s := newTLSSecret(t, "example")
+	data, err := keystoreData(s, "changeit", time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, "changeit", string(data["storePassword"]))
+	assert.Equal(t, s.Data["tls.key"], data["tls.key"])
+	assert.NotEmpty(t, data["keystore.jks"])
+	assert.NotEmpty(t, data["truststore.jks"])
+
+	status, err := certificateStatus(s, secretChecksum(s))
+	assert.Nil(t, err)
+	assert.Equal(t, "CN=example", status.Subject)
+	assert.Equal(t, "2019-06-01T00:00:00Z", status.NotAfter)
+	assert.Len(t, status.Checksum, 64)
+
+	other := newTLSSecret(t, "other")
+	assert.NotEqual(t, secretChecksum(s), secretChecksum(other))
+	s.Data["tls.key"] = other.Data["tls.key"]
+	_, err = keystoreData(s, "changeit", time.Now())
+	assert.EqualError(t, err, "private key does not match the certificate CN=example")
+}
+
+func TestStatefulSetInternodeTLS(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.TLS = &v1alpha1.TLSSpec{Internode: &v1alpha1.InternodeTLSSpec{}}
+	assert.True(t, cs.SetDefaults())
+	assert.Equal(t, v1alpha1.InternodeTLSSpec{CASecret: "example-ca", ValidityDays: 365, RenewBeforeDays: 30}, *cs.Spec.TLS.Internode)
+	assert.Nil(t, cs.Spec.TLS.Validate())
+
+	spec := StatefulSet(cs).Spec.Template.Spec
+	c := spec.Containers[0]
+	assert.Equal(t, "example-internode-keystore", spec.Volumes[len(spec.Volumes)-1].Secret.SecretName)
+	assert.Equal(t, "/etc/cassandra-internode-tls", c.VolumeMounts[len(c.VolumeMounts)-1].MountPath)
+	assert.Equal(t, v1.EnvVar{Name: "CASSANDRA_INTERNODE_ENCRYPTION", Value: "true"}, c.Env[len(c.Env)-2])
+	assert.Equal(t, "CASSANDRA_INTERNODE_STORE_PASSWORD", c.Env[len(c.Env)-1].Name)
+
+	assert.Equal(t, []string{
+		"example-1.example-unready.default.svc.cluster.local",
+		"example-1.example-unready",
+		"example-1.example-unready.default",
+		"example-1.example-unready.default.svc",
+	}, internodeDNSNames(cs, "example-1"))
+
+	cs.Spec.TLS.Internode.RenewBeforeDays = 365
+	assert.EqualError(t, cs.Spec.TLS.Validate(), "internode TLS renewBeforeDays should be less than validityDays")
+}
+
+func TestInternodeKeystoreData(t *testing.T) {
+	now := time.Now()
+	ca, err := pki.NewCA("example-ca", time.Hour, now)
+	assert.Nil(t, err)
+	var sources []*v1.Secret
+	for _, node := range []string{"example-0", "example-1"} {
+		certPEM, keyPEM, err := ca.Issue(node, []string{node}, time.Hour, now)
+		assert.Nil(t, err)
+		s := secret(nodeCertificateSecretName(node), "default")
+		s.Data = map[string][]byte{"tls.crt": certPEM, "tls.key": keyPEM}
+		sources = append(sources, s)
+	}
+
+	data, err := internodeKeystoreData([]string{"example-0", "example-1"}, sources, ca.CertificatePEM(), "changeit", now)
+	assert.Nil(t, err)
+	assert.Len(t, data, 4)
+	assert.NotEmpty(t, data["example-0.keystore.jks"])
+	assert.NotEmpty(t, data["example-1.keystore.jks"])
+	assert.NotEmpty(t, data["truststore.jks"])
+	assert.Equal(t, "changeit", string(data["storePassword"]))
+
+	sources[1].Data["tls.key"] = sources[0].Data["tls.key"]
+	_, err = internodeKeystoreData([]string{"example-0", "example-1"}, sources, ca.CertificatePEM(), "changeit", now)
+	assert.EqualError(t, err, "example-1-internode-tls: private key does not match the certificate CN=example-1")
+}
+
+func TestCARotation(t *testing.T) {
+	now := time.Date(2018, 7, 1, 10, 0, 0, 0, time.UTC)
+	previous, err := pki.NewCA("example-ca", time.Hour, now)
+	assert.Nil(t, err)
+	ca, err := pki.NewCA("example-ca-2", time.Hour, now)
+	assert.Nil(t, err)
+	cs := NewCassandra()
+	cs.Status.TLS = &v1alpha1.TLSStatus{}
+	assert.Equal(t, "", advanceCARotation(cs, true, now))
+
+	// the nodes keep their certificates until they trust both CAs
+	cs.Status.TLS.CARotation = &v1alpha1.CARotationStatus{PreviousCA: string(previous.CertificatePEM())}
+	trusted, keep := internodeTrust(cs)
+	assert.Equal(t, previous.CertificatePEM(), trusted)
+	assert.True(t, keep)
+	assert.NotEmpty(t, advanceCARotation(cs, false, now))
+	assert.Equal(t, "2018-07-01T10:00:00Z", cs.Status.TLS.CARotation.TrustedAt)
+	assert.Equal(t, "2018-07-01T10:00:00Z", cs.RestartRequestedAt())
+	assert.Equal(t, "", advanceCARotation(cs, false, now.Add(time.Minute)))
+	_, keep = internodeTrust(cs)
+	assert.True(t, keep)
+
+	certPEM, keyPEM, err := previous.Issue("example-0", []string{"example-0"}, time.Hour, now)
+	assert.Nil(t, err)
+	s := secret(nodeCertificateSecretName("example-0"), "default")
+	s.Data = map[string][]byte{"tls.crt": certPEM, "tls.key": keyPEM}
+	data, err := internodeKeystoreData([]string{"example-0"}, []*v1.Secret{s}, append(ca.CertificatePEM(), trusted...), "changeit", now)
+	assert.Nil(t, err)
+	// the count of entries follows the magic number and the version
+	assert.Equal(t, uint32(2), binary.BigEndian.Uint32(data["truststore.jks"][8:12]))
+
+	// then their certificates are issued by the new CA
+	cs.Status.Restart = &v1alpha1.RestartStatus{RequestedAt: "2018-07-01T10:00:00Z", Completed: true}
+	_, keep = internodeTrust(cs)
+	assert.False(t, keep)
+	assert.NotEmpty(t, advanceCARotation(cs, true, now.Add(time.Hour)))
+	assert.Equal(t, "2018-07-01T11:00:00Z", cs.Status.TLS.CARotation.IssuedAt)
+	assert.Equal(t, "", advanceCARotation(cs, false, now.Add(time.Hour)))
+	assert.NotNil(t, cs.Status.TLS.CARotation)
+
+	// and the previous CA is dropped once they restarted with them
+	cs.Status.Restart = &v1alpha1.RestartStatus{RequestedAt: "2018-07-01T11:00:00Z", Completed: true}
+	assert.Equal(t, "", advanceCARotation(cs, false, now.Add(2*time.Hour)))
+	assert.Nil(t, cs.Status.TLS.CARotation)
+	trusted, keep = internodeTrust(cs)
+	assert.Nil(t, trusted)
+	assert.False(t, keep)
+}
+
+func TestRecoveryPoint(t *testing.T) {
+	now := time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, v1alpha1.NodeRecoveryPoint{Node: "example-0", RecoveryPoint: "2018-06-01T09:58:00Z", LagSeconds: 120},
+		recoveryPoint("example-0", "2018-06-01T09:58:00Z\n", now))
+	assert.Equal(t, v1alpha1.NodeRecoveryPoint{Node: "example-0"}, recoveryPoint("example-0", "", now))
+}
+
+func TestTokensConfigMap(t *testing.T) {
+	cs := NewCassandra()
+	cm := TokensConfigMap(cs)
+
+	assert.Equal(t, cs.Name+"-tokens", cm.Name)
+	assert.Equal(t, cs.Namespace, cm.Namespace)
+	assert.Equal(t, labelsForCassandra(cs.Name), cm.Labels)
+	assert.Equal(t, 0, len(cm.OwnerReferences))
+}
+
+func TestIsLost(t *testing.T) {
+	cs := NewCassandra()
+	cs.Status.Members.Hosts = []v1alpha1.MemberHost{
+		{Node: "example-0", HostID: "host-0", Address: "10.0.0.1"},
+		{Node: "example-1", HostID: "host-1", Address: "10.0.0.2"},
+	}
+	pods := []v1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "example-0"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "example-1"}, Status: v1.PodStatus{PodIP: "10.0.0.3"}},
+	}
+	volumes := []string{"cassandra-example-1"}
+
+	// the pod has got a new address
+	assert.False(t, isLost(cs, nodetool.Node{HostID: "host-1", Address: "10.0.0.3"}, pods, volumes))
+	// the pod is not running and its volume is gone
+	assert.True(t, isLost(cs, nodetool.Node{HostID: "host-0", Address: "10.0.0.1"}, pods, volumes))
+	volumes = append(volumes, "cassandra-example-0")
+	assert.False(t, isLost(cs, nodetool.Node{HostID: "host-0", Address: "10.0.0.1"}, pods, volumes))
+	// the pod is gone
+	assert.True(t, isLost(cs, nodetool.Node{HostID: "host-0", Address: "10.0.0.1"}, pods[1:], volumes))
+	// no pod had this host
+	assert.False(t, isLost(cs, nodetool.Node{HostID: "host-2", Address: "10.0.0.4"}, pods, volumes))
+	assert.False(t, isLost(cs, nodetool.Node{HostID: "host-2", Address: "10.0.0.4"}, nil, nil))
+}
+
+func TestDueRepair(t *testing.T) {
+	cs := NewCassandra()
+	cs.CreationTimestamp = metav1.NewTime(time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC))
+	schedule, err := cron.Parse("0 2 * * *")
+	assert.NoError(t, err)
+
+	// not due before the first schedule time
+	assert.True(t, dueRepair(cs, schedule, time.Date(2018, 5, 2, 1, 0, 0, 0, time.UTC)).IsZero())
+	// missed schedule times are run once
+	due := dueRepair(cs, schedule, time.Date(2018, 5, 4, 3, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2018, 5, 4, 2, 0, 0, 0, time.UTC), due)
+
+	cs.Status.Repair = &v1alpha1.RepairStatus{LastScheduleTime: due.Format(time.RFC3339)}
+	assert.True(t, dueRepair(cs, schedule, time.Date(2018, 5, 4, 23, 0, 0, 0, time.UTC)).IsZero())
+}
+
+func TestNextRepair(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.Repair = &v1alpha1.RepairSpec{Keyspaces: []string{"ks1", "ks2"}}
+	cs.Status.Repair = &v1alpha1.RepairStatus{Run: &v1alpha1.RepairRun{Keyspace: "ks1", Job: "repair-ks1"}}
+
+	nextRepair(cs)
+	assert.Equal(t, v1alpha1.RepairRun{Ordinal: 0, Keyspace: "ks2"}, *cs.Status.Repair.Run)
+	nextRepair(cs)
+	assert.Equal(t, v1alpha1.RepairRun{Ordinal: 1, Keyspace: "ks1"}, *cs.Status.Repair.Run)
+}
+
+func TestRepairCommands(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.Repair = &v1alpha1.RepairSpec{Keyspaces: []string{"ks1"}, PrimaryRange: true}
+
+	cmds, err := repairCommands(cs, "example-0", "10.0.0.1", "ks1")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"nodetool", "repair", "-full", "-pr", "ks1"}}, cmds)
+
+	cs.Spec.Repair.Incremental = true
+	cmds, err = repairCommands(cs, "example-0", "10.0.0.1", "ks1")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"nodetool", "repair", "-pr", "ks1"}}, cmds)
+}
+
+func TestOverdueKeyspaces(t *testing.T) {
+	cs := NewCassandra()
+	cs.CreationTimestamp = metav1.NewTime(time.Now().Add(-48 * time.Hour))
+	cs.Spec.Repair = &v1alpha1.RepairSpec{Keyspaces: []string{"ks1", "ks2"}, OverdueAfterSeconds: 86400}
+	cs.Status.Repair = &v1alpha1.RepairStatus{}
+	cs.Status.Repair.SetLastSuccessfulRepair("ks1", time.Now().Add(-time.Hour).Format(time.RFC3339))
+
+	assert.Equal(t, []string{"ks2"}, overdueKeyspaces(cs))
+
+	cs.CreationTimestamp = metav1.NewTime(time.Now())
+	assert.Nil(t, overdueKeyspaces(cs))
+}
+
+func TestEvent(t *testing.T) {
+	cs := NewCassandra()
+	ev := Event(cs, v1.EventTypeWarning, "DeadNode", "message")
+
+	assert.Equal(t, cs.Namespace, ev.Namespace)
+	assert.Equal(t, cs.Name, ev.InvolvedObject.Name)
+	assert.Equal(t, cs.Kind, ev.InvolvedObject.Kind)
+	assert.Equal(t, v1.EventTypeWarning, ev.Type)
+	assert.Equal(t, "DeadNode", ev.Reason)
+	assert.Equal(t, "message", ev.Message)
+	assert.Equal(t, int32(1), ev.Count)
+}
+
 func TestService(t *testing.T) {
 	cs := NewCassandra()
 	svc := Service(cs)
@@ -144,3 +735,497 @@ func TestService(t *testing.T) {
 		Controller: &trueVar,
 	}, svc.OwnerReferences[0])
 }
+
+func TestClientService(t *testing.T) {
+	cs := NewCassandra()
+	svc := ClientService(cs)
+	assert.Equal(t, cs.Name, svc.Name)
+	assert.Equal(t, v1.ServiceTypeClusterIP, svc.Spec.Type)
+	assert.Equal(t, "", svc.Spec.ClusterIP)
+	assert.Equal(t, labelsForCassandra(cs.Name), svc.Spec.Selector)
+	assert.Equal(t, []v1.ServicePort{{Name: "cql", Port: 9042, TargetPort: intstr.FromInt(9042), Protocol: v1.ProtocolTCP}}, svc.Spec.Ports)
+	assert.Empty(t, svc.Spec.ExternalTrafficPolicy)
+
+	cs.Spec.ClientService = &v1alpha1.ClientServiceSpec{
+		Type:        v1.ServiceTypeLoadBalancer,
+		Annotations: map[string]string{"service.beta.kubernetes.io/aws-load-balancer-internal": "0.0.0.0/0"},
+		Thrift:      true,
+		MetricsPort: 9500,
+	}
+	assert.Nil(t, cs.Spec.ClientService.Validate())
+	svc = ClientService(cs)
+	assert.Equal(t, v1.ServiceTypeLoadBalancer, svc.Spec.Type)
+	assert.Equal(t, cs.Spec.ClientService.Annotations, svc.Annotations)
+	assert.Equal(t, v1.ServiceExternalTrafficPolicyTypeCluster, svc.Spec.ExternalTrafficPolicy)
+	assert.Equal(t, []string{"cql", "thrift", "metrics"}, []string{svc.Spec.Ports[0].Name, svc.Spec.Ports[1].Name, svc.Spec.Ports[2].Name})
+	assert.Equal(t, intstr.FromInt(9500), svc.Spec.Ports[2].TargetPort)
+
+	c := StatefulSet(cs).Spec.Template.Spec.Containers[0]
+	assert.Equal(t, v1.ContainerPort{Name: "thrift", ContainerPort: 9160}, c.Ports[len(c.Ports)-1])
+	assert.Equal(t, v1.EnvVar{Name: "CASSANDRA_START_RPC", Value: "true"}, c.Env[len(c.Env)-1])
+
+	cs.Spec.ClientService.Type = v1.ServiceTypeExternalName
+	assert.EqualError(t, cs.Spec.ClientService.Validate(), `invalid client service type "ExternalName"`)
+}
+
+func TestMergeServiceAnnotations(t *testing.T) {
+	managed := map[string]string{
+		"service.alpha.kubernetes.io/tolerate-unready-endpoints": "true",
+		"service.beta.kubernetes.io/aws-load-balancer-type":      "nlb",
+	}
+	merged := mergeServiceAnnotations(nil, managed)
+	assert.Equal(t, "service.alpha.kubernetes.io/tolerate-unready-endpoints,service.beta.kubernetes.io/aws-load-balancer-type", merged["database.camilocot/managedAnnotations"])
+	assert.Len(t, merged, 3)
+	assert.Equal(t, merged, mergeServiceAnnotations(merged, managed))
+
+	existing := mergeServiceAnnotations(merged, managed)
+	existing["field.cattle.io/publicEndpoints"] = "[]"
+	delete(managed, "service.beta.kubernetes.io/aws-load-balancer-type")
+	merged = mergeServiceAnnotations(existing, managed)
+	assert.Equal(t, map[string]string{
+		"database.camilocot/managedAnnotations":                  "service.alpha.kubernetes.io/tolerate-unready-endpoints",
+		"service.alpha.kubernetes.io/tolerate-unready-endpoints": "true",
+		"field.cattle.io/publicEndpoints":                        "[]",
+	}, merged)
+	assert.Equal(t, map[string]string{"field.cattle.io/publicEndpoints": "[]"}, mergeServiceAnnotations(merged, nil))
+}
+
+func TestExternalService(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.ExternalAccess = &v1alpha1.ExternalAccessSpec{
+		Type:        v1.ServiceTypeLoadBalancer,
+		Annotations: map[string]string{"service.beta.kubernetes.io/aws-load-balancer-type": "nlb"},
+	}
+	assert.Nil(t, cs.Spec.ExternalAccess.Validate())
+	svc := ExternalService(cs, 1)
+	assert.Equal(t, cs.Name+"-1-external", svc.Name)
+	assert.Equal(t, v1.ServiceTypeLoadBalancer, svc.Spec.Type)
+	assert.Equal(t, "true", svc.Annotations["service.alpha.kubernetes.io/tolerate-unready-endpoints"])
+	assert.Equal(t, "nlb", svc.Annotations["service.beta.kubernetes.io/aws-load-balancer-type"])
+	assert.Len(t, cs.Spec.ExternalAccess.Annotations, 1)
+	assert.Equal(t, cs.Name+"-1", svc.Spec.Selector["statefulset.kubernetes.io/pod-name"])
+	assert.Equal(t, "cassandra", svc.Spec.Selector["app"])
+
+	spec := StatefulSet(cs).Spec.Template.Spec
+	c := spec.Containers[0]
+	assert.Equal(t, v1.EnvVar{Name: "CASSANDRA_EXTERNAL_ACCESS", Value: "LoadBalancer"}, c.Env[len(c.Env)-1])
+	assert.Equal(t, "/etc/cassandra-external-addresses", c.VolumeMounts[len(c.VolumeMounts)-1].MountPath)
+	assert.Equal(t, cs.Name+"-external-addresses", spec.Volumes[len(spec.Volumes)-1].ConfigMap.Name)
+
+	svc.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{Hostname: "example.elb.amazonaws.com"}}
+	assert.Equal(t, v1alpha1.ExternalAddress{Node: "example-1", Address: "example.elb.amazonaws.com", Port: 9042}, externalAddress("example-1", svc, nil))
+
+	cs.Spec.ExternalAccess.Type = v1.ServiceTypeNodePort
+	c = StatefulSet(cs).Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "CASSANDRA_BROADCAST_RPC_ADDRESS", c.Env[len(c.Env)-1].Name)
+	assert.Equal(t, "status.hostIP", c.Env[len(c.Env)-1].ValueFrom.FieldRef.FieldPath)
+
+	svc = ExternalService(cs, 1)
+	svc.Spec.Ports[0].NodePort = 30042
+	pods := []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "example-1"}, Status: v1.PodStatus{HostIP: "10.0.0.5"}}}
+	assert.Equal(t, v1alpha1.ExternalAddress{Node: "example-1", Address: "10.0.0.5", Port: 30042}, externalAddress("example-1", svc, pods))
+	assert.Equal(t, v1alpha1.ExternalAddress{Node: "example-1", Port: 30042}, externalAddress("example-1", svc, nil))
+
+	cs.Spec.ExternalAccess.Type = v1.ServiceTypeClusterIP
+	assert.EqualError(t, cs.Spec.ExternalAccess.Validate(), `invalid external access type "ClusterIP"`)
+}
+
+func TestNetworkPolicy(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.NetworkPolicy = &v1alpha1.NetworkPolicySpec{}
+
+	portsOf := func(rule networkingv1.NetworkPolicyIngressRule) []int {
+		var ports []int
+		for _, p := range rule.Ports {
+			ports = append(ports, p.Port.IntValue())
+		}
+		return ports
+	}
+	nodes := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: labelsForCassandra(cs.Name)}}
+
+	np := NetworkPolicy(cs)
+	assert.Equal(t, cs.Name, np.Name)
+	assert.Equal(t, labelsForCassandra(cs.Name), np.Spec.PodSelector.MatchLabels)
+	assert.Equal(t, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}, np.Spec.PolicyTypes)
+	// JMX is only reached from inside the pods, nodetool runs through exec
+	assert.Len(t, np.Spec.Ingress, 2)
+	assert.Equal(t, []int{7000, 7001}, portsOf(np.Spec.Ingress[0]))
+	assert.Equal(t, []networkingv1.NetworkPolicyPeer{nodes}, np.Spec.Ingress[0].From)
+	assert.Equal(t, []int{9042}, portsOf(np.Spec.Ingress[1]))
+	assert.Nil(t, np.Spec.Ingress[1].From)
+
+	clients := networkingv1.NetworkPolicyPeer{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "orders"}}}
+	cs.Spec.NetworkPolicy.ClientsFrom = []networkingv1.NetworkPolicyPeer{clients}
+	cs.Spec.ClientService = &v1alpha1.ClientServiceSpec{Thrift: true, MetricsPort: 9500}
+	np = NetworkPolicy(cs)
+	assert.Len(t, np.Spec.Ingress, 2)
+	assert.Equal(t, []int{9042, 9160, 9500}, portsOf(np.Spec.Ingress[1]))
+	assert.Equal(t, []networkingv1.NetworkPolicyPeer{nodes, clients}, np.Spec.Ingress[1].From)
+}
+
+func TestValidateNetworkPolicy(t *testing.T) {
+	cs := NewCassandra()
+	assert.Nil(t, validateNetworkPolicy(cs))
+	cs.Spec.NetworkPolicy = &v1alpha1.NetworkPolicySpec{}
+	cs.Spec.ClientService = &v1alpha1.ClientServiceSpec{Type: v1.ServiceTypeLoadBalancer}
+	assert.Nil(t, validateNetworkPolicy(cs))
+
+	clients := networkingv1.NetworkPolicyPeer{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "orders"}}}
+	cs.Spec.NetworkPolicy.ClientsFrom = []networkingv1.NetworkPolicyPeer{clients}
+	assert.EqualError(t, validateNetworkPolicy(cs), "network policy clientsFrom drops the clients of the LoadBalancer client service, add the ipBlock of their source addresses to it")
+	cs.Spec.ClientService.Type = v1.ServiceTypeClusterIP
+	assert.Nil(t, validateNetworkPolicy(cs))
+	cs.Spec.ExternalAccess = &v1alpha1.ExternalAccessSpec{Type: v1.ServiceTypeNodePort}
+	assert.Error(t, validateNetworkPolicy(cs))
+
+	cs.Spec.NetworkPolicy.ClientsFrom = append(cs.Spec.NetworkPolicy.ClientsFrom, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/16"}})
+	assert.Nil(t, validateNetworkPolicy(cs))
+}
+
+func TestPodDisruptionBudget(t *testing.T) {
+	cs := NewCassandra()
+	pdb := PodDisruptionBudget(cs, 1)
+	assert.Equal(t, cs.Name, pdb.Name)
+	assert.Equal(t, labelsForCassandra(cs.Name), pdb.Spec.Selector.MatchLabels)
+	assert.Equal(t, intstr.FromInt(1), *pdb.Spec.MaxUnavailable)
+
+	cs.Spec.DisruptionBudget = &v1alpha1.DisruptionBudgetSpec{}
+	assert.True(t, cs.SetDefaults())
+	assert.Equal(t, int32(1), cs.Spec.DisruptionBudget.MaxUnavailable)
+	assert.Nil(t, cs.Spec.DisruptionBudget.Validate())
+	cs.Spec.DisruptionBudget.MaxUnavailable = -1
+	assert.EqualError(t, cs.Spec.DisruptionBudget.Validate(), "invalid disruption budget max unavailable -1")
+}
+
+func TestStoppingOperation(t *testing.T) {
+	cs := NewCassandra()
+	cs.Spec.Size = 3
+	cs.Spec.Partition = 1
+	ss := StatefulSet(cs)
+	ss.Status = appsv1.StatefulSetStatus{Replicas: 3, UpdatedReplicas: 3, CurrentRevision: "a", UpdateRevision: "a"}
+	assert.Equal(t, "", stoppingOperation(cs, ss))
+
+	// tightened before the statefulset is updated
+	cs.Spec.Version = "v14"
+	assert.Equal(t, "update", stoppingOperation(cs, ss))
+	ss.Annotations = StatefulSet(cs).Annotations
+	assert.Equal(t, "", stoppingOperation(cs, ss))
+
+	ss.Status.UpdateRevision = "b"
+	ss.Status.UpdatedReplicas = 1
+	assert.Equal(t, "update", stoppingOperation(cs, ss))
+	// the pod below the partition keeps the current revision
+	ss.Status.UpdatedReplicas = 2
+	assert.Equal(t, "", stoppingOperation(cs, ss))
+
+	cs.Spec.Size = 2
+	assert.Equal(t, "decommission", stoppingOperation(cs, ss))
+
+	// tightened before the restart starts
+	cs.Spec.RestartRequestedAt = "2018-06-01T10:00:00Z"
+	assert.Equal(t, "rolling restart", stoppingOperation(cs, ss))
+	cs.Status.Restart = &v1alpha1.RestartStatus{RequestedAt: cs.Spec.RestartRequestedAt, Ordinal: 2}
+	assert.Equal(t, "rolling restart", stoppingOperation(cs, ss))
+	cs.Status.Restart.Completed = true
+	assert.Equal(t, "decommission", stoppingOperation(cs, ss))
+
+	// no node is stopped before the statefulset is created
+	assert.Equal(t, "", stoppingOperation(cs, nil))
+}
+
+func TestParseSnapshotFiles(t *testing.T) {
+	out := `1024 ks1/users-1b2c/snapshots/backup/mc-1-big-Data.db
+12 ks1/users-1b2c/snapshots/backup/.users_email_idx/mc-1-big-Data.db
+`
+	files, err := parseSnapshotFiles(out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []backupFile{
+		{Keyspace: "ks1", Table: "users-1b2c", Name: "mc-1-big-Data.db", Size: 1024},
+		{Keyspace: "ks1", Table: "users-1b2c", Name: ".users_email_idx/mc-1-big-Data.db", Size: 12},
+	}, files)
+
+	files, err = parseSnapshotFiles("")
+	assert.NoError(t, err)
+	assert.Nil(t, files)
+
+	_, err = parseSnapshotFiles("12 ks1/mc-1-big-Data.db")
+	assert.Error(t, err)
+}
+
+func newBackup(name string, phase v1alpha1.BackupPhase, completedAt time.Time) v1alpha1.CassandraBackup {
+	return v1alpha1.CassandraBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(completedAt.Add(-time.Hour))},
+		Status:     v1alpha1.CassandraBackupStatus{Phase: phase, CompletedAt: completedAt.Format(time.RFC3339)},
+	}
+}
+
+func TestBackupSchedule(t *testing.T) {
+	now := time.Now()
+	schedule := &v1alpha1.CassandraBackupSchedule{ObjectMeta: metav1.ObjectMeta{Name: "daily"}}
+	backups := []v1alpha1.CassandraBackup{
+		newBackup("daily-1", v1alpha1.BackupPhaseCompleted, now.Add(-72*time.Hour)),
+		newBackup("daily-2", v1alpha1.BackupPhaseFailed, now.Add(-48*time.Hour)),
+		newBackup("daily-3", v1alpha1.BackupPhaseCompleted, now.Add(-24*time.Hour)),
+		newBackup("daily-4", v1alpha1.BackupPhaseFailed, now.Add(-2*time.Hour)),
+		newBackup("daily-5", v1alpha1.BackupPhaseRunning, now),
+	}
+
+	updateBackupScheduleStatus(schedule, backups)
+	assert.Equal(t, "daily-5", schedule.Status.Active)
+	assert.Equal(t, "daily-3", schedule.Status.LastSuccessfulBackup.Name)
+	assert.Equal(t, "daily-4", schedule.Status.LastFailedBackup.Name)
+
+	// the last backups are kept once pruned
+	updateBackupScheduleStatus(schedule, backups[2:3])
+	assert.Equal(t, "", schedule.Status.Active)
+	assert.Equal(t, "daily-4", schedule.Status.LastFailedBackup.Name)
+
+	// failed backups older than the last completed one are pruned
+	assert.Equal(t, []string{"daily-2"}, backupNames(backupsToPrune(schedule, backups)))
+	schedule.Spec.Retention.Count = 1
+	assert.Equal(t, []string{"daily-1", "daily-2"}, backupNames(backupsToPrune(schedule, backups)))
+	schedule.Spec.Retention = v1alpha1.BackupRetention{MaxAgeSeconds: 3600}
+	// the last completed backup is always kept
+	assert.Equal(t, []string{"daily-1", "daily-2"}, backupNames(backupsToPrune(schedule, backups)))
+
+	// the continuous backup is kept from the start of the oldest retained backup
+	backups[2].Status.StartedAt = "2018-06-01T10:00:00Z"
+	assert.Equal(t, "2018-06-01T10:00:00Z", oldestRetainedBackup(backups, backupsToPrune(schedule, backups)))
+	assert.Equal(t, "", oldestRetainedBackup(backups, backups))
+}
+
+func TestBackupForSchedule(t *testing.T) {
+	schedule := &v1alpha1.CassandraBackupSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "daily", Namespace: "default"},
+		Spec: v1alpha1.CassandraBackupScheduleSpec{
+			CassandraBackupSpec: v1alpha1.CassandraBackupSpec{Cluster: "example", Keyspaces: []string{"ks1"}},
+		},
+	}
+	b := backupForSchedule(schedule, time.Unix(1525140000, 0))
+
+	assert.Equal(t, "daily-1525140000", b.Name)
+	assert.Equal(t, "default", b.Namespace)
+	assert.Equal(t, map[string]string{v1alpha1.BackupScheduleLabel: "daily"}, b.Labels)
+	assert.Equal(t, schedule.Spec.CassandraBackupSpec, b.Spec)
+}
+
+func TestFinalBackupForCassandra(t *testing.T) {
+	deletedAt := metav1.Unix(1525140000, 0)
+	storage := v1alpha1.BackupStorage{Endpoint: "http://minio:9000", Bucket: "backups", CredentialsSecret: "minio"}
+	api := &v1alpha1.Cassandra{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default", DeletionTimestamp: &deletedAt},
+		Spec:       v1alpha1.CassandraSpec{DeletionPolicy: v1alpha1.DeletionPolicySnapshotThenDelete, FinalBackup: &storage},
+	}
+	b := finalBackupForCassandra(api)
+
+	assert.Equal(t, "example-final-1525140000", b.Name)
+	assert.Equal(t, "default", b.Namespace)
+	assert.Empty(t, b.OwnerReferences)
+	assert.Equal(t, v1alpha1.CassandraBackupSpec{Cluster: "example", Storage: storage}, b.Spec)
+
+	api.Spec.FinalBackup = nil
+	_, err := finalBackup(api)
+	assert.Error(t, err)
+}
+
+func TestRestoreSchema(t *testing.T) {
+	schema := `CREATE KEYSPACE ks1 WITH replication = {'class': 'SimpleStrategy', 'replication_factor': '3'}  AND durable_writes = true;
+
+CREATE TABLE ks1.users (
+    id uuid PRIMARY KEY,
+    email text
+) WITH comment = '';
+CREATE INDEX users_email_idx ON ks1.users (email);
+
+CREATE KEYSPACE IF NOT EXISTS "Ks2" WITH replication = {'class': 'SimpleStrategy', 'replication_factor': '1'};
+
+CREATE TYPE "Ks2".address (
+    street text
+);
+
+CREATE KEYSPACE system_auth WITH replication = {'class': 'SimpleStrategy', 'replication_factor': '1'};
+`
+	statements := restoreSchema(schema, nil, nil)
+	assert.Contains(t, statements, "CREATE KEYSPACE IF NOT EXISTS ks1 WITH")
+	assert.Contains(t, statements, "CREATE TABLE IF NOT EXISTS ks1.users (")
+	assert.Contains(t, statements, "CREATE INDEX IF NOT EXISTS users_email_idx ON ks1.users (email);")
+	assert.Contains(t, statements, `CREATE KEYSPACE IF NOT EXISTS "Ks2" WITH`)
+	assert.Contains(t, statements, `CREATE TYPE IF NOT EXISTS "Ks2".address (`)
+	assert.NotContains(t, statements, "system_auth")
+
+	statements = restoreSchema(schema, []string{"Ks2"}, nil)
+	assert.NotContains(t, statements, "ks1")
+	assert.Contains(t, statements, `CREATE TYPE IF NOT EXISTS "Ks2".address (`)
+
+	ids := tableIDs([]backupFile{{Keyspace: "ks1", Table: "users-5bc52802de2535edaeab188eecebb090"}})
+	assert.Equal(t, map[string]string{"ks1.users": "5bc52802-de25-35ed-aeab-188eecebb090"}, ids)
+	statements = restoreSchema(schema, nil, ids)
+	assert.Contains(t, statements, ") WITH ID = 5bc52802-de25-35ed-aeab-188eecebb090\n    AND comment = '';")
+}
+
+func TestConflictingTables(t *testing.T) {
+	ids := map[string]string{
+		"ks1.users":  "5bc52802-de25-35ed-aeab-188eecebb090",
+		"ks1.events": "0a1b2c3d-de25-35ed-aeab-188eecebb090",
+	}
+	rows := []cql.Row{
+		{"keyspace_name": "ks1", "table_name": "users", "id": "5bc52802-de25-35ed-aeab-188eecebb090"},
+		{"keyspace_name": "ks1", "table_name": "events", "id": "9f8e7d6c-de25-35ed-aeab-188eecebb090"},
+		{"keyspace_name": "ks2", "table_name": "users", "id": "9f8e7d6c-de25-35ed-aeab-188eecebb090"},
+	}
+	assert.Equal(t, []string{"ks1.events"}, conflictingTables(ids, rows))
+	assert.Empty(t, conflictingTables(ids, rows[:1]))
+}
+
+func TestSegmentsToReplay(t *testing.T) {
+	startedAt := time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+	objects := []s3.Object{
+		{Key: "CommitLog-6-1.log", LastModified: startedAt.Add(-time.Minute)},
+		{Key: "CommitLog-6-2.log", LastModified: startedAt},
+		{Key: "CommitLog-6-3.log", LastModified: startedAt.Add(time.Hour)},
+		{Key: "CommitLog-6-4.log", LastModified: startedAt.Add(2 * time.Hour)},
+		{Key: "CommitLog-6-5.log", LastModified: startedAt.Add(30 * time.Minute)},
+	}
+	pointInTime := startedAt.Add(20 * time.Minute)
+	assert.Equal(t, []s3.Object{objects[1], objects[4]}, segmentsToReplay(objects, startedAt.Format(time.RFC3339), pointInTime))
+	assert.Equal(t, []s3.Object{objects[1], objects[4], objects[2], objects[3]}, segmentsToReplay(objects, startedAt.Format(time.RFC3339), startedAt.Add(3*time.Hour)))
+	assert.Len(t, segmentsToReplay(objects, "", pointInTime), 3)
+}
+
+func TestRestoreTargets(t *testing.T) {
+	pods := []string{"example-0", "example-1"}
+	podTokens := map[string][]string{
+		"example-0": {"-10", "20"},
+		"example-1": {"-20", "10"},
+	}
+
+	method, targets := restoreTargets([][]string{{"10", "-20"}, {"20", "-10"}}, pods, podTokens)
+	assert.Equal(t, v1alpha1.RestoreMethodRefresh, method)
+	assert.Equal(t, []string{"example-1", "example-0"}, targets)
+
+	method, targets = restoreTargets([][]string{{"10"}, {"20"}, {"30"}}, pods, podTokens)
+	assert.Equal(t, v1alpha1.RestoreMethodSSTableLoader, method)
+	assert.Equal(t, []string{"example-0", "example-1", "example-0"}, targets)
+}
+
+func TestRestoreCommands(t *testing.T) {
+	cs := NewCassandra()
+	tables := [][2]string{{"ks1", "users"}}
+
+	cmds := restoreCommands(cs, v1alpha1.RestoreMethodRefresh, "10.0.0.1", tables)
+	assert.Equal(t, [][]string{
+		{"mv", "/cassandra_data/restore/ks1/users/*", `"$(ls -td /cassandra_data/data/ks1/users-* | head -1)"/`},
+		{"nodetool", "refresh", "ks1", "users"},
+		{"rm", "-rf", "/cassandra_data/restore"},
+	}, cmds)
+
+	cmds = restoreCommands(cs, v1alpha1.RestoreMethodSSTableLoader, "10.0.0.1", tables)
+	assert.Equal(t, [][]string{
+		{"sstableloader", "-d", "10.0.0.1", "/cassandra_data/restore/ks1/users"},
+		{"rm", "-rf", "/cassandra_data/restore"},
+	}, cmds)
+}
+
+func TestIsRestoredFile(t *testing.T) {
+	assert.Equal(t, "users", tableName("users-5bc52802de2535edaeab188eecebb090"))
+	assert.Equal(t, "users-old", tableName("users-old"))
+
+	f := backupFile{Keyspace: "ks1", Table: "users-5bc52802de2535edaeab188eecebb090", Name: "mc-1-big-Data.db"}
+	assert.True(t, isRestoredFile(f, nil))
+	assert.True(t, isRestoredFile(f, []string{"ks1"}))
+	assert.False(t, isRestoredFile(f, []string{"ks2"}))
+	assert.False(t, isRestoredFile(backupFile{Keyspace: "system_auth", Name: "mc-1-big-Data.db"}, nil))
+	assert.False(t, isRestoredFile(backupFile{Keyspace: "ks1", Name: manifestObject}, nil))
+	assert.False(t, isRestoredFile(backupFile{Keyspace: "ks1", Name: ".users_email_idx/mc-1-big-Data.db"}, nil))
+}
+
+func TestParseReplication(t *testing.T) {
+	rep, err := parseReplication(map[string]string{"class": "org.apache.cassandra.locator.NetworkTopologyStrategy", "dc1": "3", "dc2": "2"})
+	assert.Nil(t, err)
+	assert.Equal(t, replication{strategy: v1alpha1.NetworkTopologyStrategy, factors: map[string]int32{"dc1": 3, "dc2": 2}}, rep)
+
+	_, err = parseReplication(map[string]string{"class": "org.apache.cassandra.locator.SimpleStrategy", "replication_factor": "x"})
+	assert.Error(t, err)
+}
+
+func TestNextReplication(t *testing.T) {
+	nts := v1alpha1.NetworkTopologyStrategy
+	simple := v1alpha1.SimpleStrategy
+
+	next, increased, decreased := nextReplication(
+		replication{strategy: nts, factors: map[string]int32{"dc1": 1}},
+		replication{strategy: nts, factors: map[string]int32{"dc1": 3, "dc2": 2}})
+	assert.Equal(t, replication{strategy: nts, factors: map[string]int32{"dc1": 2, "dc2": 1}}, next)
+	assert.True(t, increased)
+	assert.False(t, decreased)
+
+	next, increased, decreased = nextReplication(
+		replication{strategy: nts, factors: map[string]int32{"dc1": 3, "dc2": 1}},
+		replication{strategy: nts, factors: map[string]int32{"dc1": 2}})
+	assert.Equal(t, replication{strategy: nts, factors: map[string]int32{"dc1": 2}}, next)
+	assert.False(t, increased)
+	assert.True(t, decreased)
+
+	// the strategy is changed first, keeping the replicas
+	next, increased, decreased = nextReplication(
+		replication{strategy: simple, factors: map[string]int32{"replication_factor": 3}},
+		replication{strategy: nts, factors: map[string]int32{"dc1": 3}})
+	assert.Equal(t, replication{strategy: nts, factors: map[string]int32{"dc1": 3}}, next)
+	assert.False(t, increased)
+	assert.False(t, decreased)
+}
+
+func TestKeyspaceStatements(t *testing.T) {
+	rep := replication{strategy: v1alpha1.NetworkTopologyStrategy, factors: map[string]int32{"dc2": 2, "dc1": 3}}
+	assert.Equal(t, "{'class': 'NetworkTopologyStrategy', 'dc1': 3, 'dc2': 2}", replicationLiteral(rep))
+	assert.Equal(t,
+		`CREATE KEYSPACE IF NOT EXISTS "Users" WITH replication = {'class': 'NetworkTopologyStrategy', 'dc1': 3, 'dc2': 2} AND durable_writes = true`,
+		createKeyspaceStatement("Users", rep, true))
+}
+
+func TestKeyspaceDataCommand(t *testing.T) {
+	assert.Equal(t,
+		"if [ -d '"+cassandraDataDirectory+"/Users' ]; then find '"+cassandraDataDirectory+"/Users' -name '*-Data.db' -not -path '*/snapshots/*' -not -path '*/backups/*'; fi",
+		keyspaceDataCommand("Users"))
+}
+
+func TestGrantStatements(t *testing.T) {
+	g := &v1alpha1.CassandraGrant{Spec: v1alpha1.CassandraGrantSpec{
+		Cluster:     "example",
+		Role:        "app",
+		Keyspace:    "users",
+		Table:       "emails",
+		Permissions: []v1alpha1.Permission{v1alpha1.PermissionAll},
+	}}
+	assert.Nil(t, g.Validate())
+	assert.Equal(t, "data/users/emails", g.Resource())
+	assert.Equal(t, []v1alpha1.Permission{"ALTER", "AUTHORIZE", "DROP", "MODIFY", "SELECT"}, g.DesiredPermissions())
+
+	g.Spec.Permissions = []v1alpha1.Permission{v1alpha1.PermissionCreate}
+	assert.Error(t, g.Validate())
+
+	missing, extra := diffPermissions([]v1alpha1.Permission{"MODIFY", "SELECT"}, []v1alpha1.Permission{"ALTER", "SELECT"})
+	assert.Equal(t, []v1alpha1.Permission{"ALTER"}, missing)
+	assert.Equal(t, []v1alpha1.Permission{"MODIFY"}, extra)
+
+	assert.Equal(t, []string{
+		`GRANT ALTER ON TABLE "users"."emails" TO 'app'`,
+		`REVOKE MODIFY ON TABLE "users"."emails" FROM 'app'`,
+	}, grantStatements("app", g.Resource(), missing, extra))
+	assert.Equal(t, `KEYSPACE "users"`, resourceClause("data/users"))
+	assert.Equal(t, "ALL KEYSPACES", resourceClause("data"))
+}
+
+func TestRoleStatement(t *testing.T) {
+	assert.Equal(t, "ALTER ROLE 'app' WITH LOGIN = true AND PASSWORD = 'it''s';\n",
+		roleStatement("ALTER ROLE", "app", []string{"LOGIN = true", "PASSWORD = 'it''s'"}))
+}
+
+func backupNames(backups []v1alpha1.CassandraBackup) []string {
+	var names []string
+	for _, b := range backups {
+		names = append(names, b.Name)
+	}
+	return names
+}
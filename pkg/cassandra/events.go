@@ -0,0 +1,63 @@
+package cassandra
+
+import (
+	"fmt"
+
+	"github.com/operator-framework/operator-sdk/pkg/sdk"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// eventComponent is the source component of the events recorded by the operator
+const eventComponent = "cassandra-operator"
+
+// eventObject is a CR events are recorded about
+type eventObject interface {
+	metav1.Object
+	runtime.Object
+}
+
+// Event returns a v1.Event object about the CR
+func Event(obj eventObject, eventType, reason, message string) *v1.Event {
+	now := metav1.Now()
+	apiVersion, kind := obj.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+	return &v1.Event{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Event",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", obj.GetName(), now.UnixNano()),
+			Namespace: obj.GetNamespace(),
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion:      apiVersion,
+			Kind:            kind,
+			Name:            obj.GetName(),
+			Namespace:       obj.GetNamespace(),
+			UID:             obj.GetUID(),
+			ResourceVersion: obj.GetResourceVersion(),
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: eventComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+}
+
+// recordEvent records an event about the CR, failures are only logged
+func recordEvent(obj eventObject, eventType, reason, messageFmt string, args ...interface{}) {
+	message := fmt.Sprintf(messageFmt, args...)
+	logrus.Infof("[%s] %s: %s", obj.GetName(), reason, message)
+
+	err := sdk.Create(Event(obj, eventType, reason, message))
+	if err != nil {
+		logrus.Errorf("Failed to record event %v: %v", reason, err)
+	}
+}
@@ -0,0 +1,182 @@
+package cassandra
+
+import (
+	"reflect"
+	"time"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/sirupsen/logrus"
+
+	"github.com/operator-framework/operator-sdk/pkg/sdk"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ReconcileExternalAccess reconciles the external service of every pod and records
+// the external address of its node. The addresses of the load balancers are written
+// to the external addresses config map the nodes wait for, and a rolling restart is
+// requested when the address of a started node changes, so it broadcasts the new one
+func (c Cluster) ReconcileExternalAccess() (err error) {
+	r := c.Resource
+	status := r.Status.DeepCopy()
+	if r.Spec.ExternalAccess == nil {
+		r.Status.ExternalAddresses = nil
+		err = deleteExternalAccess(r, 0)
+	} else {
+		err = reconcileExternalAccess(r)
+	}
+
+	if !reflect.DeepEqual(status, &r.Status) {
+		updateErr := sdk.Update(r)
+		if updateErr != nil {
+			return updateErr
+		}
+	}
+	return err
+}
+
+// reconcileExternalAccess reconciles the external services of the pods of the cluster
+// size, deleting the ones removed by a scale down
+func reconcileExternalAccess(r *v1alpha1.Cassandra) error {
+	err := r.Spec.ExternalAccess.Validate()
+	if err != nil {
+		return err
+	}
+	pods, err := podsForCassandra(r)
+	if err != nil {
+		return err
+	}
+
+	var addresses []v1alpha1.ExternalAddress
+	for ordinal := int32(0); ordinal < r.Spec.Size; ordinal++ {
+		err = reconcileService(ExternalService(r, ordinal))
+		if err != nil {
+			return err
+		}
+		podName := podNameForCassandra(r, ordinal)
+		// the allocated node port and load balancer are only in the existing service
+		svc := service(externalServiceName(podName), r.Namespace)
+		err = sdk.Get(svc)
+		if err != nil {
+			return err
+		}
+		addresses = append(addresses, externalAddress(podName, svc, pods))
+	}
+	r.Status.ExternalAddresses = addresses
+
+	if r.Spec.ExternalAccess.Type == v1.ServiceTypeLoadBalancer {
+		err = reconcileExternalAddresses(r, addresses)
+	} else {
+		err = deleteExternalAddresses(r)
+	}
+	if err != nil {
+		return err
+	}
+	return deleteExternalAccess(r, r.Spec.Size)
+}
+
+// externalAddress returns the external address of the node of a pod: the address of
+// the kubernetes node of the pod and the node port, or the load balancer ingress and
+// the CQL port. The address is empty until it is allocated
+func externalAddress(podName string, svc *v1.Service, pods []v1.Pod) v1alpha1.ExternalAddress {
+	address := v1alpha1.ExternalAddress{Node: podName}
+	if svc.Spec.Type == v1.ServiceTypeNodePort {
+		for _, p := range svc.Spec.Ports {
+			if p.Name == "cql" {
+				address.Port = p.NodePort
+			}
+		}
+		for _, p := range pods {
+			if p.Name == podName {
+				address.Address = p.Status.HostIP
+			}
+		}
+		return address
+	}
+
+	address.Port = 9042
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if len(ingress.IP) > 0 {
+			address.Address = ingress.IP
+		} else {
+			address.Address = ingress.Hostname
+		}
+		break
+	}
+	return address
+}
+
+// reconcileExternalAddresses writes the allocated load balancer addresses to the
+// external addresses config map. The previous address of a node is kept while its
+// load balancer is pending, and a rolling restart is requested when it changes
+func reconcileExternalAddresses(r *v1alpha1.Cassandra, addresses []v1alpha1.ExternalAddress) error {
+	cm := ExternalAddressesConfigMap(r)
+	err := sdk.Get(cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	data := map[string]string{}
+	for _, a := range addresses {
+		previous := cm.Data[a.Node]
+		if len(a.Address) == 0 {
+			if len(previous) > 0 {
+				data[a.Node] = previous
+			}
+			continue
+		}
+		data[a.Node] = a.Address
+		if len(previous) > 0 && previous != a.Address {
+			externalAddressChanged(r, a.Node, previous, a.Address)
+		}
+	}
+
+	if !exists {
+		cm = ExternalAddressesConfigMap(r)
+		cm.Data = data
+		return sdk.Create(cm)
+	}
+	if reflect.DeepEqual(data, cm.Data) || len(data)+len(cm.Data) == 0 {
+		return nil
+	}
+	cm.Data = data
+	return sdk.Update(cm)
+}
+
+// externalAddressChanged requests the rolling restart broadcasting the new external
+// address of a node
+func externalAddressChanged(r *v1alpha1.Cassandra, podName, previous, address string) {
+	r.Status.RestartRequestedAt = time.Now().UTC().Format(time.RFC3339)
+	recordEvent(r, v1.EventTypeNormal, "ExternalAddressChanged", "External address of %v changed from %v to %v, rolling restart requested at %v", podName, previous, address, r.Status.RestartRequestedAt)
+}
+
+// deleteExternalAddresses deletes the external addresses config map, only used behind
+// load balancers
+func deleteExternalAddresses(r *v1alpha1.Cassandra) error {
+	err := sdk.Delete(configMap(externalAddressesConfigMapName(r), r.Namespace))
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// deleteExternalAccess deletes the external services of the pods from the given
+// ordinal, and the external addresses config map when external access is disabled
+func deleteExternalAccess(r *v1alpha1.Cassandra, from int32) error {
+	for ordinal := from; ; ordinal++ {
+		name := externalServiceName(podNameForCassandra(r, ordinal))
+		err := sdk.Delete(service(name, r.Namespace))
+		if apierrors.IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Deleted the external service %v", name)
+	}
+	if r.Spec.ExternalAccess == nil {
+		return deleteExternalAddresses(r)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package cassandra
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/cql"
+	"github.com/sirupsen/logrus"
+
+	"github.com/operator-framework/operator-sdk/pkg/sdk"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// GrantController manages reconciliation of the permissions of a Cassandra role
+type GrantController interface {
+	ReconcileGrant() error
+}
+
+// Grant represents the permissions of a role on a keyspace or table
+type Grant struct {
+	Resource *v1alpha1.CassandraGrant
+
+	GrantController
+}
+
+// NewCassandraGrant creates a new Cassandra Grant object
+func NewCassandraGrant(g *v1alpha1.CassandraGrant) *Grant {
+	return &Grant{Resource: g}
+}
+
+// ReconcileGrant grants the permissions of the spec to the role and revokes the
+// others it has on the resource. The permissions are revoked once the object is
+// marked for deletion
+func (gr Grant) ReconcileGrant() (err error) {
+	g := gr.Resource
+	status := g.Status.DeepCopy()
+
+	err = g.Validate()
+	if err != nil {
+		return updateGrantStatus(g, status, err)
+	}
+
+	cluster, podName, err := readyPodForCluster(g.Spec.Cluster, g.Namespace)
+	if apierrors.IsNotFound(err) && g.IsBeingDeleted() {
+		logrus.Infof("Cluster %v of grant %v is gone, releasing it", g.Spec.Cluster, g.Name)
+		g.RemoveFinalizer()
+		return sdk.Update(g)
+	}
+	if err != nil {
+		return updateGrantStatus(g, status, fmt.Errorf("could not get cluster %v: %v", g.Spec.Cluster, err))
+	}
+	if cluster.Spec.Paused {
+		logrus.Infof("Reconciliation of %v paused, waiting to reconcile grant %v", cluster.Name, g.Name)
+		return nil
+	}
+	if len(podName) == 0 {
+		logrus.Infof("Waiting for a ready pod of %v to reconcile grant %v", cluster.Name, g.Name)
+		return nil
+	}
+
+	if g.IsBeingDeleted() {
+		if !g.HasFinalizer() {
+			return nil
+		}
+		err = revokePermissions(g, cluster, podName, g.Resource())
+		if err != nil {
+			return err
+		}
+		g.RemoveFinalizer()
+		return sdk.Update(g)
+	}
+	if !cluster.Authorization() {
+		return updateGrantStatus(g, status, fmt.Errorf("cluster %v does not use the %v", cluster.Name, v1alpha1.CassandraAuthorizer))
+	}
+	if g.AddFinalizer() {
+		return sdk.Update(g)
+	}
+
+	err = syncGrant(g, cluster, podName)
+	return updateGrantStatus(g, status, err)
+}
+
+// updateGrantStatus records the error as the reason and updates the grant if its
+// status changed
+func updateGrantStatus(g *v1alpha1.CassandraGrant, status *v1alpha1.CassandraGrantStatus, err error) error {
+	g.Status.Reason = ""
+	if err != nil {
+		g.Status.Reason = err.Error()
+		g.Status.Synced = false
+	}
+	if !reflect.DeepEqual(status, &g.Status) {
+		updateErr := sdk.Update(g)
+		if updateErr != nil {
+			return updateErr
+		}
+	}
+	return err
+}
+
+// syncGrant grants the missing permissions and revokes the ones not in the spec. The
+// permissions on the previous resource are revoked when the resource changes
+func syncGrant(g *v1alpha1.CassandraGrant, cluster *v1alpha1.Cassandra, podName string) error {
+	resource := g.Resource()
+	if len(g.Status.Resource) > 0 && g.Status.Resource != resource {
+		err := revokePermissions(g, cluster, podName, g.Status.Resource)
+		if err != nil {
+			return err
+		}
+		g.Status.Resource, g.Status.Permissions = "", nil
+	}
+
+	current, err := rolePermissions(cluster, podName, g.Spec.Role, resource)
+	if err != nil {
+		return err
+	}
+	desired := g.DesiredPermissions()
+	missing, extra := diffPermissions(current, desired)
+	statements := grantStatements(g.Spec.Role, resource, missing, extra)
+	if len(statements) > 0 {
+		_, err = runCqlsh(cluster, podName, strings.Join(statements, "; "))
+		if err != nil {
+			return err
+		}
+		recordEvent(g, v1.EventTypeNormal, "PermissionsChanged", "Granted %v and revoked %v on %v to role %v", missing, extra, resource, g.Spec.Role)
+	}
+	g.Status.Resource = resource
+	g.Status.Permissions = desired
+	g.Status.Synced = true
+	return nil
+}
+
+// revokePermissions revokes every permission of the role on the resource
+func revokePermissions(g *v1alpha1.CassandraGrant, cluster *v1alpha1.Cassandra, podName, resource string) error {
+	current, err := rolePermissions(cluster, podName, g.Spec.Role, resource)
+	if err != nil || len(current) == 0 {
+		return err
+	}
+	_, err = runCqlsh(cluster, podName, strings.Join(grantStatements(g.Spec.Role, resource, nil, current), "; "))
+	if err != nil {
+		return err
+	}
+	recordEvent(g, v1.EventTypeNormal, "PermissionsRevoked", "Revoked %v on %v from role %v", current, resource, g.Spec.Role)
+	return nil
+}
+
+// rolePermissions returns the sorted permissions of the role on the resource
+func rolePermissions(api *v1alpha1.Cassandra, podName, role, resource string) ([]v1alpha1.Permission, error) {
+	out, err := runCqlsh(api, podName, fmt.Sprintf("SELECT permissions FROM system_auth.role_permissions WHERE role = %s AND resource = %s",
+		cql.QuoteString(role), cql.QuoteString(resource)))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := cql.ParseRows(out)
+	if err != nil {
+		return nil, err
+	}
+	set := map[v1alpha1.Permission]bool{}
+	for _, row := range rows {
+		for _, p := range cql.ParseSet(row["permissions"]) {
+			set[v1alpha1.Permission(p)] = true
+		}
+	}
+	return v1alpha1.SortPermissions(set), nil
+}
+
+// diffPermissions returns the desired permissions that are missing, and the current
+// permissions that are not desired
+func diffPermissions(current, desired []v1alpha1.Permission) (missing, extra []v1alpha1.Permission) {
+	in := func(p v1alpha1.Permission, list []v1alpha1.Permission) bool {
+		for _, l := range list {
+			if l == p {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range desired {
+		if !in(p, current) {
+			missing = append(missing, p)
+		}
+	}
+	for _, p := range current {
+		if !in(p, desired) {
+			extra = append(extra, p)
+		}
+	}
+	return missing, extra
+}
+
+// grantStatements returns the statements granting and revoking the permissions of the
+// role on the resource
+func grantStatements(role, resource string, grant, revoke []v1alpha1.Permission) []string {
+	var statements []string
+	on := resourceClause(resource)
+	for _, p := range grant {
+		statements = append(statements, fmt.Sprintf("GRANT %s ON %s TO %s", p, on, cql.QuoteString(role)))
+	}
+	for _, p := range revoke {
+		statements = append(statements, fmt.Sprintf("REVOKE %s ON %s FROM %s", p, on, cql.QuoteString(role)))
+	}
+	return statements
+}
+
+// resourceClause returns the CQL of the resource, as named in system_auth
+func resourceClause(resource string) string {
+	elem := strings.Split(resource, "/")
+	switch len(elem) {
+	case 2:
+		return "KEYSPACE " + cql.QuoteIdentifier(elem[1])
+	case 3:
+		return "TABLE " + cql.QuoteIdentifier(elem[1]) + "." + cql.QuoteIdentifier(elem[2])
+	default:
+		return "ALL KEYSPACES"
+	}
+}
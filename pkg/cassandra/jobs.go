@@ -0,0 +1,55 @@
+package cassandra
+
+import (
+	"fmt"
+	"strings"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/exec"
+)
+
+const (
+	// jobsPath is where the jobs run in the cassandra container keep their pid,
+	// output and exit code, so they survive the operator restarts
+	jobsPath = cassandraDataPath + "/jobs"
+
+	// jobRunning is the state of a job whose process is alive
+	jobRunning = "running"
+	// jobLost is the state of a job whose process died without an exit code,
+	// as it happens when the container is restarted
+	jobLost = "lost"
+	// jobSucceeded is the state of a job that exited with code 0
+	jobSucceeded = "0"
+)
+
+// startJob starts the commands detached in the cassandra container of the pod. They
+// are run one after the other until one fails
+func startJob(api *v1alpha1.Cassandra, podName, job string, cmds ...[]string) error {
+	var lines []string
+	for _, cmd := range cmds {
+		lines = append(lines, strings.Join(cmd, " "))
+	}
+	prefix := jobsPath + "/" + job
+	script := fmt.Sprintf("mkdir -p %s && rm -f %s.* && nohup sh -c '%s; echo $? > %s.exit' > %s.log 2>&1 < /dev/null & echo $! > %s.pid",
+		jobsPath, prefix, strings.Join(lines, " && "), prefix, prefix, prefix)
+	_, err := exec.ContainerCommand(podName, cassandraContainerName, api.Namespace, "sh", "-c", script) // #nosec
+	return err
+}
+
+// jobState returns the state of the job: running, lost or its exit code
+func jobState(api *v1alpha1.Cassandra, podName, job string) (string, error) {
+	prefix := jobsPath + "/" + job
+	script := fmt.Sprintf("if [ -f %s.exit ]; then cat %s.exit; elif kill -0 $(cat %s.pid) 2>/dev/null; then echo %s; else echo %s; fi",
+		prefix, prefix, prefix, jobRunning, jobLost)
+	out, err := exec.ContainerCommand(podName, cassandraContainerName, api.Namespace, "sh", "-c", script) // #nosec
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// jobOutput returns the last lines of the output of the job
+func jobOutput(api *v1alpha1.Cassandra, podName, job string) string {
+	out, _ := exec.ContainerCommand(podName, cassandraContainerName, api.Namespace, "tail", "-n", "5", jobsPath+"/"+job+".log") // #nosec
+	return strings.TrimSpace(out)
+}
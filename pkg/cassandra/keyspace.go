@@ -0,0 +1,457 @@
+package cassandra
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/cql"
+	"github.com/camilocot/cassandra-operator/pkg/exec"
+	"github.com/camilocot/cassandra-operator/pkg/nodetool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/operator-framework/operator-sdk/pkg/sdk"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// KeyspaceController manages reconciliation of a Cassandra keyspace
+type KeyspaceController interface {
+	ReconcileKeyspace() error
+}
+
+// Keyspace represents a keyspace of a Cassandra cluster
+type Keyspace struct {
+	Resource *v1alpha1.CassandraKeyspace
+
+	KeyspaceController
+}
+
+// NewCassandraKeyspace creates a new Cassandra Keyspace object
+func NewCassandraKeyspace(k *v1alpha1.CassandraKeyspace) *Keyspace {
+	return &Keyspace{Resource: k}
+}
+
+// replication is the replication of a keyspace
+type replication struct {
+	strategy v1alpha1.ReplicationStrategy
+	// factors are the replication factors by datacenter, or as replication_factor
+	// for the SimpleStrategy
+	factors map[string]int32
+}
+
+// ReconcileKeyspace creates the keyspace and brings its replication and durable writes
+// back to the spec when they differ, whether the spec or the keyspace changed. The
+// replication factors are changed by one at a time, and the keyspace is repaired on
+// every node after every change. The deletion policy is applied once the object is
+// marked for deletion
+func (k Keyspace) ReconcileKeyspace() (err error) {
+	r := k.Resource
+	r.SetDefaults()
+	status := r.Status.DeepCopy()
+
+	err = r.Validate()
+	if err != nil {
+		return updateKeyspaceStatus(r, status, err)
+	}
+
+	cluster := cassandra(r.Spec.Cluster, r.Namespace)
+	err = sdk.Get(cluster)
+	if apierrors.IsNotFound(err) && r.IsBeingDeleted() {
+		logrus.Infof("Cluster %v of keyspace %v is gone, releasing it", r.Spec.Cluster, r.Name)
+		r.RemoveFinalizer()
+		return sdk.Update(r)
+	}
+	if err != nil {
+		return updateKeyspaceStatus(r, status, fmt.Errorf("could not get cluster %v: %v", r.Spec.Cluster, err))
+	}
+	if cluster.Spec.Paused {
+		logrus.Infof("Reconciliation of %v paused, waiting to reconcile keyspace %v", cluster.Name, r.Name)
+		return nil
+	}
+	pods, err := podsForCassandra(cluster)
+	if err != nil {
+		return err
+	}
+	if r.IsBeingDeleted() {
+		return finalizeKeyspace(r, cluster, pods)
+	}
+	podName := readyPodName(pods)
+	if len(podName) == 0 {
+		logrus.Infof("Waiting for a ready pod of %v to reconcile keyspace %v", cluster.Name, r.Name)
+		return nil
+	}
+
+	if r.AddFinalizer() {
+		return sdk.Update(r)
+	}
+
+	if r.Status.Repair != nil {
+		r.Status.Repair, err = stepKeyspaceRepair(r, cluster, r.KeyspaceName(), r.Status.Repair)
+	} else {
+		err = syncKeyspace(r, cluster, podName)
+	}
+	return updateKeyspaceStatus(r, status, err)
+}
+
+// updateKeyspaceStatus records the error as the reason and updates the keyspace if
+// its status changed
+func updateKeyspaceStatus(r *v1alpha1.CassandraKeyspace, status *v1alpha1.CassandraKeyspaceStatus, err error) error {
+	r.Status.Reason = ""
+	if err != nil {
+		r.Status.Reason = err.Error()
+	}
+	if !reflect.DeepEqual(status, &r.Status) {
+		updateErr := sdk.Update(r)
+		if updateErr != nil {
+			return updateErr
+		}
+	}
+	return err
+}
+
+// syncKeyspace creates the keyspace, or alters it one step closer to the spec
+func syncKeyspace(r *v1alpha1.CassandraKeyspace, cluster *v1alpha1.Cassandra, podName string) error {
+	name := r.KeyspaceName()
+	desired := replication{strategy: r.Spec.Strategy, factors: r.Replication()}
+	durableWrites := *r.Spec.DurableWrites
+
+	live, liveDurableWrites, found, err := describeKeyspace(cluster, podName, name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		err = checkReplication(cluster, podName, desired)
+		if err != nil {
+			return err
+		}
+		_, err = runCqlsh(cluster, podName, createKeyspaceStatement(name, desired, durableWrites))
+		if err != nil {
+			return err
+		}
+		recordEvent(r, v1.EventTypeNormal, "KeyspaceCreated", "Created keyspace %v with replication %v", name, replicationLiteral(desired))
+		return nil
+	}
+
+	previous := replication{strategy: r.Status.Strategy, factors: r.Status.Replication}
+	if r.Status.Synced && (!live.equal(previous) || liveDurableWrites != r.Status.DurableWrites) {
+		r.Status.DriftDetectedAt = time.Now().Format(time.RFC3339)
+		recordEvent(r, v1.EventTypeWarning, "KeyspaceDrifted", "Keyspace %v was changed to replication %v and durable writes %v, reverting it", name, replicationLiteral(live), liveDurableWrites)
+	}
+	r.Status.Strategy = live.strategy
+	r.Status.Replication = live.factors
+	r.Status.DurableWrites = liveDurableWrites
+	r.Status.Synced = live.equal(desired) && liveDurableWrites == durableWrites
+	if r.Status.Synced {
+		return nil
+	}
+
+	if liveDurableWrites != durableWrites {
+		_, err = runCqlsh(cluster, podName, fmt.Sprintf("ALTER KEYSPACE %s WITH durable_writes = %t", cql.QuoteIdentifier(name), durableWrites))
+		if err != nil {
+			return err
+		}
+		recordEvent(r, v1.EventTypeNormal, "KeyspaceAltered", "Set durable writes of keyspace %v to %v", name, durableWrites)
+		r.Status.DurableWrites = durableWrites
+	}
+
+	if live.equal(desired) {
+		return nil
+	}
+	next, increased, decreased := nextReplication(live, desired)
+	err = checkReplication(cluster, podName, next)
+	if err != nil {
+		return err
+	}
+	_, err = runCqlsh(cluster, podName, fmt.Sprintf("ALTER KEYSPACE %s WITH replication = %s", cql.QuoteIdentifier(name), replicationLiteral(next)))
+	if err != nil {
+		return err
+	}
+	recordEvent(r, v1.EventTypeNormal, "KeyspaceAltered", "Changed replication of keyspace %v from %v to %v", name, replicationLiteral(live), replicationLiteral(next))
+	r.Status.Strategy = next.strategy
+	r.Status.Replication = next.factors
+	if increased || decreased {
+		r.Status.Repair = &v1alpha1.KeyspaceRepairRun{StartedAt: time.Now().Format(time.RFC3339), Cleanup: decreased}
+	}
+	return nil
+}
+
+// describeKeyspace returns the replication and durable writes of the keyspace, and if
+// it exists
+func describeKeyspace(api *v1alpha1.Cassandra, podName, name string) (replication, bool, bool, error) {
+	out, err := runCqlsh(api, podName, "SELECT keyspace_name, durable_writes, replication FROM system_schema.keyspaces WHERE keyspace_name = "+cql.QuoteString(name))
+	if err != nil {
+		return replication{}, false, false, err
+	}
+	rows, err := cql.ParseRows(out)
+	if err != nil {
+		return replication{}, false, false, err
+	}
+	if len(rows) == 0 {
+		return replication{}, false, false, nil
+	}
+	rep, err := parseReplication(cql.ParseMap(rows[0]["replication"]))
+	return rep, cql.ParseBool(rows[0]["durable_writes"]), true, err
+}
+
+// parseReplication returns the replication of the replication map of a keyspace
+func parseReplication(m map[string]string) (replication, error) {
+	class := m["class"]
+	rep := replication{
+		strategy: v1alpha1.ReplicationStrategy(class[strings.LastIndex(class, ".")+1:]),
+		factors:  map[string]int32{},
+	}
+	for key, value := range m {
+		if key == "class" {
+			continue
+		}
+		rf, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return rep, fmt.Errorf("invalid replication factor %q of %v", value, key)
+		}
+		rep.factors[key] = int32(rf)
+	}
+	return rep, nil
+}
+
+// equal returns if both replications place the same replicas
+func (r replication) equal(other replication) bool {
+	if r.strategy != other.strategy || len(r.factors) != len(other.factors) {
+		return false
+	}
+	for key, rf := range r.factors {
+		if other.factors[key] != rf {
+			return false
+		}
+	}
+	return true
+}
+
+// nextReplication returns the replication one step closer to the desired one, and if
+// replicas are added or removed. A change of strategy keeps the replication factors,
+// the replication factor of the SimpleStrategy being the one of every datacenter.
+// Every replication factor is then changed by one at a time
+func nextReplication(live, desired replication) (next replication, increased, decreased bool) {
+	current := live.factors
+	if live.strategy != desired.strategy {
+		var max int32
+		for _, rf := range live.factors {
+			if rf > max {
+				max = rf
+			}
+		}
+		current = map[string]int32{}
+		for key := range desired.factors {
+			current[key] = max
+		}
+	}
+
+	next = replication{strategy: desired.strategy, factors: map[string]int32{}}
+	for key, rf := range desired.factors {
+		c := current[key]
+		switch {
+		case rf > c:
+			c++
+			increased = true
+		case rf < c:
+			c--
+			decreased = true
+		}
+		if c > 0 {
+			next.factors[key] = c
+		}
+	}
+	for key, c := range current {
+		if _, ok := desired.factors[key]; !ok && c > 1 {
+			next.factors[key] = c - 1
+			decreased = true
+		} else if !ok {
+			decreased = true
+		}
+	}
+	return next, increased, decreased
+}
+
+// checkReplication returns an error if a datacenter has less nodes than replicas
+func checkReplication(api *v1alpha1.Cassandra, podName string, rep replication) error {
+	ring, err := ringForCassandra(api, podName)
+	if err != nil {
+		return err
+	}
+	for key, rf := range rep.factors {
+		nodes := nodesInDatacenter(ring, key)
+		if rep.strategy == v1alpha1.SimpleStrategy {
+			nodes = len(ring)
+		}
+		if int(rf) > nodes {
+			return fmt.Errorf("replication factor %v of %v is greater than its %v nodes", rf, key, nodes)
+		}
+	}
+	return nil
+}
+
+// nodesInDatacenter returns the number of nodes of the datacenter in the ring
+func nodesInDatacenter(ring nodetool.Ring, datacenter string) int {
+	n := 0
+	for _, node := range ring {
+		if node.Datacenter == datacenter {
+			n++
+		}
+	}
+	return n
+}
+
+// createKeyspaceStatement returns the statement creating the keyspace
+func createKeyspaceStatement(name string, rep replication, durableWrites bool) string {
+	return fmt.Sprintf("CREATE KEYSPACE IF NOT EXISTS %s WITH replication = %s AND durable_writes = %t",
+		cql.QuoteIdentifier(name), replicationLiteral(rep), durableWrites)
+}
+
+// replicationLiteral returns the replication as a CQL map, with the datacenters sorted
+func replicationLiteral(rep replication) string {
+	var keys []string
+	for key := range rep.factors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	entries := []string{"'class': " + cql.QuoteString(string(rep.strategy))}
+	for _, key := range keys {
+		entries = append(entries, fmt.Sprintf("%s: %d", cql.QuoteString(key), rep.factors[key]))
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// stepKeyspaceRepair repairs the keyspace on the next node after its replication
+// changed, and cleans up the ranges it no longer replicates when replicas were
+// removed. It returns the run, nil once every node has been repaired
+func stepKeyspaceRepair(obj eventObject, cluster *v1alpha1.Cassandra, name string, run *v1alpha1.KeyspaceRepairRun) (*v1alpha1.KeyspaceRepairRun, error) {
+	if run.Ordinal >= cluster.Spec.Size {
+		recordEvent(obj, v1.EventTypeNormal, "RepairCompleted", "Repair of keyspace %v started at %v completed", name, run.StartedAt)
+		return nil, nil
+	}
+
+	podName := podNameForCassandra(cluster, run.Ordinal)
+	p := pod(podName, cluster.Namespace)
+	err := sdk.Get(p)
+	if err != nil {
+		return run, err
+	}
+	if !isPodReady(p) {
+		logrus.Infof("Waiting for %v to be ready to repair keyspace %v", podName, name)
+		return run, nil
+	}
+
+	job := "keyspace-" + name
+	if len(run.Job) == 0 {
+		cmds := [][]string{nodetoolCommand(cluster, "repair", "-full", name)}
+		if run.Cleanup {
+			cmds = append(cmds, nodetoolCommand(cluster, "cleanup", name))
+		}
+		logrus.Infof("Repairing keyspace %v on %v", name, podName)
+		err = startJob(cluster, podName, job, cmds...)
+		if err != nil {
+			return run, err
+		}
+		run.Job = job
+		return run, nil
+	}
+
+	state, err := jobState(cluster, podName, job)
+	if err != nil {
+		return run, err
+	}
+	switch state {
+	case jobRunning:
+		logrus.Infof("Waiting for the repair of keyspace %v on %v", name, podName)
+		return run, nil
+	case jobSucceeded:
+		logrus.Infof("Repaired keyspace %v on %v", name, podName)
+	default:
+		recordEvent(obj, v1.EventTypeWarning, "RepairFailed", "Repair of keyspace %v on %v failed (%v): %v", name, podName, state, jobOutput(cluster, podName, job))
+	}
+	run.Ordinal++
+	run.Job = ""
+	return run, nil
+}
+
+// finalizeKeyspace applies the deletion policy to the keyspace and releases the
+// finalizer. A keyspace with data is only dropped with the ForceDelete policy
+func finalizeKeyspace(r *v1alpha1.CassandraKeyspace, cluster *v1alpha1.Cassandra, pods []v1.Pod) error {
+	if !r.HasFinalizer() {
+		return nil
+	}
+	name := r.KeyspaceName()
+
+	if r.Spec.DeletionPolicy == v1alpha1.KeyspaceDeletionPolicyRetain {
+		logrus.Infof("Retaining keyspace %v", name)
+	} else {
+		podName := readyPodName(pods)
+		if len(podName) == 0 {
+			logrus.Infof("Waiting for a ready pod of %v to apply the deletion policy of keyspace %v", cluster.Name, r.Name)
+			return nil
+		}
+		_, _, found, err := describeKeyspace(cluster, podName, name)
+		if err != nil {
+			return err
+		}
+		if found && r.Spec.DeletionPolicy != v1alpha1.KeyspaceDeletionPolicyForceDelete {
+			var hasData bool
+			hasData, err = keyspaceHasData(cluster, pods, name)
+			if err != nil {
+				return err
+			}
+			if hasData {
+				reason := fmt.Sprintf("keyspace %v has data, set the deletion policy to ForceDelete or Retain to delete it", name)
+				if r.Status.Reason != reason {
+					recordEvent(r, v1.EventTypeWarning, "KeyspaceNotDropped", "Keyspace %v has data, not dropping it", name)
+					r.Status.Reason = reason
+					return sdk.Update(r)
+				}
+				return nil
+			}
+		}
+		if found {
+			_, err = runCqlsh(cluster, podName, "DROP KEYSPACE IF EXISTS "+cql.QuoteIdentifier(name))
+			if err != nil {
+				return err
+			}
+			recordEvent(r, v1.EventTypeNormal, "KeyspaceDropped", "Dropped keyspace %v", name)
+		}
+	}
+
+	r.RemoveFinalizer()
+	return sdk.Update(r)
+}
+
+// keyspaceHasData returns if any node has an SSTable of the keyspace, once its
+// memtables are flushed. Every pod of the cluster must be ready
+func keyspaceHasData(api *v1alpha1.Cassandra, pods []v1.Pod, name string) (bool, error) {
+	if !allPodsReady(api, pods) {
+		return false, fmt.Errorf("all the pods must be ready to check the data of keyspace %v", name)
+	}
+	for _, p := range pods {
+		_, err := runNodetool(api, p.Name, "flush", name)
+		if err != nil {
+			return false, err
+		}
+		out, err := exec.ContainerCommand(p.Name, cassandraContainerName, api.Namespace, "sh", "-c", keyspaceDataCommand(name)) // #nosec
+		if err != nil {
+			return false, err
+		}
+		if len(strings.TrimSpace(out)) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// keyspaceDataCommand returns the shell command listing the SSTables of the keyspace
+// on a node. The keyspace has no directory until a table is created in it
+func keyspaceDataCommand(name string) string {
+	dir := cassandraDataDirectory + "/" + name
+	return fmt.Sprintf("if [ -d '%[1]s' ]; then find '%[1]s' -name '*-Data.db' -not -path '*/snapshots/*' -not -path '*/backups/*'; fi", dir)
+}
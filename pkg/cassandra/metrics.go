@@ -0,0 +1,83 @@
+package cassandra
+
+import (
+	"time"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	backupLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cassandra_operator_backup_last_success_timestamp_seconds",
+		Help: "Completion time of the last successful backup of a backup schedule",
+	}, []string{"namespace", "schedule"})
+
+	backupLastFailure = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cassandra_operator_backup_last_failure_timestamp_seconds",
+		Help: "Completion time of the last failed backup of a backup schedule",
+	}, []string{"namespace", "schedule"})
+
+	recoveryPointLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cassandra_operator_continuous_backup_lag_seconds",
+		Help: "Time since the recovery point of the continuous backup of a node",
+	}, []string{"namespace", "cluster", "node"})
+
+	certificateExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cassandra_operator_certificate_expiry_timestamp_seconds",
+		Help: "Expiry time of a certificate in use by the nodes of a cluster",
+	}, []string{"namespace", "cluster", "certificate"})
+)
+
+func init() {
+	prometheus.MustRegister(backupLastSuccess, backupLastFailure, recoveryPointLag, certificateExpiry)
+}
+
+// setRecoveryPointMetrics exports the lag of the continuous backup of every node
+// with a recovery point
+func setRecoveryPointMetrics(api *v1alpha1.Cassandra) {
+	for _, p := range api.Status.RecoveryPoints {
+		if len(p.RecoveryPoint) > 0 {
+			recoveryPointLag.WithLabelValues(api.Namespace, api.Name, p.Node).Set(float64(p.LagSeconds))
+		}
+	}
+}
+
+// setCertificateMetrics exports the expiry time of the certificates of the cluster
+func setCertificateMetrics(api *v1alpha1.Cassandra) {
+	if api.Status.TLS == nil {
+		return
+	}
+	set := func(certificate string, c *v1alpha1.CertificateStatus) {
+		if c == nil {
+			return
+		}
+		t, err := time.Parse(time.RFC3339, c.NotAfter)
+		if err == nil {
+			certificateExpiry.WithLabelValues(api.Namespace, api.Name, certificate).Set(float64(t.Unix()))
+		}
+	}
+	set("client", api.Status.TLS.Client)
+	set("ca", api.Status.TLS.CA)
+	for i := range api.Status.TLS.Internode {
+		set(api.Status.TLS.Internode[i].Secret, &api.Status.TLS.Internode[i])
+	}
+}
+
+// setBackupScheduleMetrics exports the last successful and failed backups of the schedule
+func setBackupScheduleMetrics(api *v1alpha1.CassandraBackupSchedule) {
+	setTimestamp(backupLastSuccess, api, api.Status.LastSuccessfulBackup)
+	setTimestamp(backupLastFailure, api, api.Status.LastFailedBackup)
+}
+
+func setTimestamp(g *prometheus.GaugeVec, api *v1alpha1.CassandraBackupSchedule, b *v1alpha1.ScheduledBackup) {
+	if b == nil {
+		return
+	}
+	t, err := time.Parse(time.RFC3339, b.CompletedAt)
+	if err != nil {
+		return
+	}
+	g.WithLabelValues(api.Namespace, api.Name).Set(float64(t.Unix()))
+}
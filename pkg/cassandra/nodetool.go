@@ -0,0 +1,120 @@
+package cassandra
+
+import (
+	"fmt"
+	"strings"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/exec"
+	"github.com/camilocot/cassandra-operator/pkg/nodetool"
+
+	"k8s.io/api/core/v1"
+)
+
+// nodetoolCommand returns the nodetool command line with the given arguments. With the
+// JMX authentication it logs in as the JMX user, the command being run by a job in a
+// shell expanding the username in the environment of the cassandra container
+func nodetoolCommand(api *v1alpha1.Cassandra, args ...string) []string {
+	cmd := []string{"nodetool"}
+	if api.JMXAuthentication() {
+		cmd = append(cmd, "-u", `"$`+jmxUsernameEnv+`"`, "-pwf", jmxPasswordPath)
+	}
+	return append(cmd, args...)
+}
+
+// runNodetool runs nodetool with the given arguments in the cassandra container of the pod
+func runNodetool(api *v1alpha1.Cassandra, podName string, args ...string) (string, error) {
+	cmd := nodetoolCommand(api, args...)
+	if api.JMXAuthentication() {
+		login := "exec " + strings.Join(nodetoolCommand(api), " ") + ` "$@"`
+		cmd = append([]string{"sh", "-c", login, "nodetool"}, args...)
+	}
+	return exec.ContainerCommand(podName, cassandraContainerName, api.Namespace, cmd...) // #nosec
+}
+
+// cqlshCommand returns the cqlsh command line with the given arguments. With the
+// password authenticator it logs in with the credentials in the environment of the
+// cassandra container, and with the client TLS it connects with SSL
+func cqlshCommand(api *v1alpha1.Cassandra, args ...string) []string {
+	args = append(cqlshSSLArgs(api), args...)
+	if !api.PasswordAuthentication() {
+		return append([]string{"cqlsh"}, args...)
+	}
+	login := `exec cqlsh -u "$` + usernameEnv + `" -p "$` + passwordEnv + `" "$@"`
+	return append([]string{"sh", "-c", login, "cqlsh"}, args...)
+}
+
+// runCqlsh runs the CQL statements with cqlsh in the cassandra container of the pod
+func runCqlsh(api *v1alpha1.Cassandra, podName, statements string) (string, error) {
+	cmd := cqlshCommand(api, "-e", statements)
+	return exec.ContainerCommand(podName, cassandraContainerName, api.Namespace, cmd...) // #nosec
+}
+
+// runCqlshInput runs the CQL statements with cqlsh reading them from its stdin, for
+// large statements or the ones that should not be part of the command, as passwords
+func runCqlshInput(api *v1alpha1.Cassandra, podName, statements string) error {
+	cmd := cqlshCommand(api)
+	return exec.StreamCommand(podName, cassandraContainerName, api.Namespace, strings.NewReader(statements), nil, cmd...) // #nosec
+}
+
+// runCqlshAsDefaultSuperuser runs the CQL statements with cqlsh logged in as the
+// default superuser, reading them from its stdin. It is only used until the admin
+// role is created
+func runCqlshAsDefaultSuperuser(api *v1alpha1.Cassandra, podName, statements string) error {
+	cmd := append([]string{"cqlsh", "-u", v1alpha1.DefaultSuperuser, "-p", v1alpha1.DefaultSuperuser}, cqlshSSLArgs(api)...)
+	return exec.StreamCommand(podName, cassandraContainerName, api.Namespace, strings.NewReader(statements), nil, cmd...) // #nosec
+}
+
+// cqlshSSLArgs returns the arguments of cqlsh connecting with SSL when the client TLS
+// is enabled, with the cqlshrc written by the run script
+func cqlshSSLArgs(api *v1alpha1.Cassandra) []string {
+	if !api.ClientTLS() {
+		return nil
+	}
+	return []string{"--ssl", "--cqlshrc=" + cqlshrcPath}
+}
+
+// sstableloaderCommand returns the sstableloader command line with the given
+// arguments. It is run by a job, in a shell expanding the credentials and the
+// password of the keystores in the environment of the cassandra container
+func sstableloaderCommand(api *v1alpha1.Cassandra, args ...string) []string {
+	cmd := []string{"sstableloader"}
+	if api.PasswordAuthentication() {
+		cmd = append(cmd, "-u", `"$`+usernameEnv+`"`, "-pw", `"$`+passwordEnv+`"`)
+	}
+	if api.ClientTLS() {
+		password := `"$` + clientStorePasswordEnv + `"`
+		cmd = append(cmd,
+			"-ts", clientTLSPath+"/"+truststoreKey, "-tspw", password,
+			"-ks", clientTLSPath+"/"+keystoreKey, "-kspw", password)
+	}
+	return append(cmd, args...)
+}
+
+// ringForCassandra returns the ring as seen by the given pod
+func ringForCassandra(api *v1alpha1.Cassandra, podName string) (nodetool.Ring, error) {
+	out, err := runNodetool(api, podName, "status")
+	if err != nil {
+		return nil, err
+	}
+	return nodetool.ParseStatus(out)
+}
+
+// ringFromReadyPod returns the ring as seen by the first ready pod
+func ringFromReadyPod(api *v1alpha1.Cassandra, pods []v1.Pod) (nodetool.Ring, error) {
+	podName := readyPodName(pods)
+	if len(podName) == 0 {
+		return nil, fmt.Errorf("no ready pods to get the ring from")
+	}
+	return ringForCassandra(api, podName)
+}
+
+// readyPodName returns the name of the first ready pod, empty if there is none
+func readyPodName(pods []v1.Pod) string {
+	for i := range pods {
+		if isPodReady(&pods[i]) {
+			return pods[i].Name
+		}
+	}
+	return ""
+}
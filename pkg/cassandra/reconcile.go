@@ -3,21 +3,40 @@ package cassandra
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
 	"github.com/camilocot/cassandra-operator/pkg/exec"
+	"github.com/camilocot/cassandra-operator/pkg/nodetool"
+	"github.com/camilocot/cassandra-operator/pkg/util/cron"
 	"github.com/sirupsen/logrus"
 
 	"github.com/operator-framework/operator-sdk/pkg/sdk"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 // Controller manages reconciliation of the Cassandra cluster
 type Controller interface {
 	ReconcileService() error
+	ReconcileExternalAccess() error
+	ReconcileDisruptionBudget() error
 	ReconcileStatus() error
 	ReconcileMembers() error
 	ReconcileStatefulset() error
+	ReconcileTLS() error
+	ReconcileRestart() error
+	ReconcileHosts() error
+	ReconcileDeadNodes() error
+	ReconcileRepair() error
+	ReconcileAuth() error
+	ReconcileFinalizer() error
+	Finalize() error
 	SetDefaults() bool
+	IsPaused() bool
 	FailedReconciliation(string, error) error
 }
 
@@ -33,39 +52,283 @@ func NewCassandraCluster(c *v1alpha1.Cassandra) *Cluster {
 	return &Cluster{Resource: c}
 }
 
-// ReconcileService reconciles the headless service
+// ReconcileService reconciles the headless service of the seeds, the service of
+// the clients and the network policy of the cluster
 func (c Cluster) ReconcileService() (err error) {
-	// Create the headless service if it doesn't exist
 	r := c.Resource
-	existingSvc := Service(r)
-	desiredSvc := Service(r)
+	if r.Spec.ClientService != nil {
+		err = r.Spec.ClientService.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	err = validateNetworkPolicy(r)
+	if err != nil {
+		return err
+	}
 
-	err = sdk.Get(existingSvc)
+	err = reconcileService(Service(r))
 	if err != nil {
-		err = sdk.Create(desiredSvc)
-	} else {
-		if !reflect.DeepEqual(existingSvc.Spec.Ports, desiredSvc.Spec.Ports) {
-			existingSvc.Spec.Ports = desiredSvc.Spec.Ports
-			err = sdk.Update(existingSvc)
+		return err
+	}
+	err = reconcileService(ClientService(r))
+	if err != nil {
+		return err
+	}
+	return reconcileNetworkPolicy(r)
+}
+
+// validateNetworkPolicy rejects clients restricted to peers in kubernetes while the
+// cluster is exposed outside of it. The traffic of a load balancer or a node port is
+// usually SNATed to the address of a kubernetes node, which only an ipBlock allows
+func validateNetworkPolicy(r *v1alpha1.Cassandra) error {
+	spec := r.Spec.NetworkPolicy
+	if spec == nil || len(spec.ClientsFrom) == 0 {
+		return nil
+	}
+	for _, peer := range spec.ClientsFrom {
+		if peer.IPBlock != nil {
+			return nil
 		}
 	}
+	if cs := r.Spec.ClientService; cs != nil && cs.Type != v1.ServiceTypeClusterIP && len(cs.Type) > 0 {
+		return fmt.Errorf("network policy clientsFrom drops the clients of the %v client service, add the ipBlock of their source addresses to it", cs.Type)
+	}
+	if r.Spec.ExternalAccess != nil {
+		return fmt.Errorf("network policy clientsFrom drops the clients of the external access, add the ipBlock of their source addresses to it")
+	}
+	return nil
+}
 
-	return err
+// reconcileNetworkPolicy creates the network policy, or updates it when its spec or
+// labels differ from the desired ones. It is deleted when disabled
+func reconcileNetworkPolicy(r *v1alpha1.Cassandra) error {
+	if r.Spec.NetworkPolicy == nil {
+		err := sdk.Delete(networkPolicy(r.Name, r.Namespace))
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	desired := NetworkPolicy(r)
+	existing := desired.DeepCopy()
+	err := sdk.Get(existing)
+	if apierrors.IsNotFound(err) {
+		return sdk.Create(desired)
+	}
+	if err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(existing.Spec, desired.Spec) && reflect.DeepEqual(existing.Labels, desired.Labels) {
+		return nil
+	}
+	logrus.Infof("Updating network policy %v", existing.Name)
+	existing.Spec = desired.Spec
+	existing.Labels = desired.Labels
+	return sdk.Update(existing)
+}
+
+// reconcileService creates the service, or updates it when its spec, labels or
+// managed annotations differ from the desired ones. The cluster IP and node ports
+// allocated to the existing service are kept
+func reconcileService(desired *v1.Service) error {
+	existing := desired.DeepCopy()
+	err := sdk.Get(existing)
+	if apierrors.IsNotFound(err) {
+		desired.Annotations = mergeServiceAnnotations(nil, desired.Annotations)
+		return sdk.Create(desired)
+	}
+	if err != nil {
+		return err
+	}
+
+	desired.Spec.ClusterIP = existing.Spec.ClusterIP
+	if desired.Spec.Type == existing.Spec.Type && desired.Spec.Type != v1.ServiceTypeClusterIP {
+		for i, p := range desired.Spec.Ports {
+			for _, e := range existing.Spec.Ports {
+				if p.NodePort == 0 && e.Name == p.Name {
+					desired.Spec.Ports[i].NodePort = e.NodePort
+				}
+			}
+		}
+		desired.Spec.HealthCheckNodePort = existing.Spec.HealthCheckNodePort
+	}
+
+	annotations := mergeServiceAnnotations(existing.Annotations, desired.Annotations)
+	if reflect.DeepEqual(existing.Spec, desired.Spec) &&
+		reflect.DeepEqual(existing.Labels, desired.Labels) &&
+		(reflect.DeepEqual(existing.Annotations, annotations) || len(existing.Annotations)+len(annotations) == 0) {
+		return nil
+	}
+	logrus.Infof("Updating service %v", existing.Name)
+	existing.Spec = desired.Spec
+	existing.Labels = desired.Labels
+	existing.Annotations = annotations
+	return sdk.Update(existing)
+}
+
+// mergeServiceAnnotations returns the annotations of the existing service with the
+// managed ones set. The managed annotations the operator set before and no longer
+// wants are removed, the annotations set by others, such as load balancer
+// controllers or users, are kept
+func mergeServiceAnnotations(existing, managed map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range existing {
+		merged[k] = v
+	}
+	if previous := existing[managedAnnotationsAnnotation]; len(previous) > 0 {
+		for _, k := range strings.Split(previous, ",") {
+			delete(merged, k)
+		}
+	}
+	delete(merged, managedAnnotationsAnnotation)
+
+	keys := make([]string, 0, len(managed))
+	for k, v := range managed {
+		merged[k] = v
+		keys = append(keys, k)
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		merged[managedAnnotationsAnnotation] = strings.Join(keys, ",")
+	}
+	return merged
+}
+
+// ReconcileDisruptionBudget reconciles the pod disruption budget of the cluster. While a
+// rolling restart, an update of the statefulset or a decommission stops a node, or is
+// about to in this reconciliation, the budget allows no unavailable pod so an eviction
+// does not stop a second one. The spec of a pod disruption budget is immutable, the
+// budget is recreated when it changes
+func (c Cluster) ReconcileDisruptionBudget() (err error) {
+	r := c.Resource
+	maxUnavailable := int32(v1alpha1.DefaultMaxUnavailable)
+	if db := r.Spec.DisruptionBudget; db != nil {
+		err = db.Validate()
+		if err != nil {
+			return err
+		}
+		maxUnavailable = db.MaxUnavailable
+	}
+
+	ss := StatefulSet(r)
+	err = sdk.Get(ss)
+	if apierrors.IsNotFound(err) {
+		ss = nil
+	} else if err != nil {
+		return err
+	}
+	operation := stoppingOperation(r, ss)
+	if len(operation) > 0 {
+		maxUnavailable = 0
+	}
+
+	desired := PodDisruptionBudget(r, maxUnavailable)
+	existing := desired.DeepCopy()
+	err = sdk.Get(existing)
+	if apierrors.IsNotFound(err) {
+		return sdk.Create(desired)
+	}
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(existing.Spec, desired.Spec) && reflect.DeepEqual(existing.Labels, desired.Labels) {
+		return nil
+	}
+
+	if len(operation) > 0 {
+		logrus.Infof("Holding the evictions of the pods of %v during the %v", r.Name, operation)
+	} else {
+		logrus.Infof("Recreating pod disruption budget %v with max unavailable %v", existing.Name, maxUnavailable)
+	}
+	err = sdk.Delete(existing)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return sdk.Create(desired)
+}
+
+// stoppingOperation returns the operation of the operator or the statefulset controller
+// stopping a node, or starting to in this reconciliation, empty if there is none. No
+// node is stopped before the statefulset exists, it is nil then
+func stoppingOperation(api *v1alpha1.Cassandra, ss *appsv1.StatefulSet) string {
+	if ss == nil {
+		return ""
+	}
+	requestedAt := api.RestartRequestedAt()
+	rs := api.Status.Restart
+	if len(requestedAt) > 0 && (rs == nil || rs.RequestedAt != requestedAt || !rs.Completed) {
+		return "rolling restart"
+	}
+	if ss.Status.Replicas > api.Spec.Size {
+		return "decommission"
+	}
+	// the statefulset is about to be updated
+	if ss.Annotations[templateChecksumAnnotation] != templateChecksum(&StatefulSet(api).Spec.Template) {
+		return "update"
+	}
+	// the pods below the partition are not updated
+	updating := ss.Status.Replicas - api.Spec.Partition
+	if ss.Status.UpdateRevision != ss.Status.CurrentRevision && ss.Status.UpdatedReplicas < updating {
+		return "update"
+	}
+	return ""
 }
 
 // ReconcileStatefulset reconciles the statefulset
 func (c Cluster) ReconcileStatefulset() (err error) {
 
 	r := c.Resource
+	if r.Spec.ContinuousBackup != nil {
+		err = r.Spec.ContinuousBackup.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if r.Spec.TLS != nil {
+		err = r.Spec.TLS.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if r.Spec.Auth != nil {
+		err = r.Spec.Auth.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if r.PasswordAuthentication() {
+		err = reconcileCredentialsSecret(r, r.Spec.Auth.CredentialsSecret, v1alpha1.DefaultAdminRole)
+		if err != nil {
+			return err
+		}
+	}
+	if r.JMXAuthentication() {
+		err = reconcileCredentialsSecret(r, r.Spec.JMX.CredentialsSecret, v1alpha1.DefaultJMXUsername)
+		if err != nil {
+			return err
+		}
+	}
 	existingSs := StatefulSet(r)
 	desiredSs := StatefulSet(r)
+	err = validatePodSpec(&desiredSs.Spec.Template.Spec)
+	if err != nil {
+		return err
+	}
 
 	err = sdk.Get(existingSs)
 	if err != nil {
 		err = sdk.Create(desiredSs)
 	} else {
-		if !reflect.DeepEqual(existingSs.Spec, desiredSs.Spec) {
+		checksum := desiredSs.Annotations[templateChecksumAnnotation]
+		if !reflect.DeepEqual(existingSs.Spec, desiredSs.Spec) || existingSs.Annotations[templateChecksumAnnotation] != checksum {
 			existingSs.Spec = desiredSs.Spec
+			if existingSs.Annotations == nil {
+				existingSs.Annotations = map[string]string{}
+			}
+			existingSs.Annotations[templateChecksumAnnotation] = checksum
 			err = sdk.Update(existingSs)
 		}
 	}
@@ -79,17 +342,38 @@ func (c Cluster) ReconcileStatefulset() (err error) {
 // ReconcileStatus reconciles the cluster status
 func (c Cluster) ReconcileStatus() (err error) {
 	r := c.Resource
-	podNames, err := nodesForCassandra(r)
+	status := r.Status.DeepCopy()
+	pods, err := podsForCassandra(r)
 	if err != nil {
 		return err
 	}
 
-	if !reflect.DeepEqual(podNames, r.Status.Members.Nodes) {
-		r.Status.Members.Nodes = podNames
-		err = sdk.Update(r)
+	r.Status.Members.Nodes = getPodNames(pods)
+	r.Status.Members.Shutdowns = shutdownsForCassandra(r, pods)
+	r.Status.RecoveryPoints = recoveryPointsForCassandra(r, pods, time.Now())
+	setRecoveryPointMetrics(r)
+	r.Status.SetReadyCondition()
+	if r.Spec.Paused {
+		r.Status.SetPausedCondition()
+	} else {
+		r.Status.ClearPausedCondition()
 	}
 
-	r.Status.SetReadyCondition()
+	var unclean []string
+	for _, s := range r.Status.Members.Shutdowns {
+		if s.Shutdown == v1alpha1.ShutdownUnclean {
+			unclean = append(unclean, s.Node)
+		}
+	}
+	if len(unclean) > 0 {
+		r.Status.SetUncleanShutdownCondition(unclean)
+	} else {
+		r.Status.ClearUncleanShutdownCondition()
+	}
+
+	if !reflect.DeepEqual(status, &r.Status) {
+		err = sdk.Update(r)
+	}
 	return err
 }
 
@@ -113,7 +397,7 @@ func (c Cluster) ReconcileMembers() (err error) {
 		return nil
 	}
 
-	podName := "cassandra-cluster-" + fmt.Sprint(existingSize-1)
+	podName := podNameForCassandra(r, existingSize-1)
 
 	if existingSize != desiredSize+1 {
 		return fmt.Errorf("statefulset could not be updated, instance decommission can only be done 1 by 1. Current replica: %v Desired size: %v", existingSize, desiredSize)
@@ -127,11 +411,282 @@ func (c Cluster) ReconcileMembers() (err error) {
 
 	logrus.Infof("Start the decommission of %v", podName)
 
-	out, err := exec.Command(podName, r.Namespace, "nodetool", "decommission") // #nosec
+	out, err := runNodetool(r, podName, "decommission")
 
-	logrus.Infof(out)
-	// @TODO: mark node as decommissioned
+	logrus.Info(out)
+	if err != nil {
+		return err
+	}
 	logrus.Infof("Finished the decommission of %v", podName)
+
+	// a new node with this ordinal must not replace the decommissioned one
+	return forgetHost(r, podName)
+}
+
+// ReconcileRestart performs the latest rolling restart requested with restartRequestedAt,
+// in the spec or in the status. Every pod is drained before the statefulset partition
+// is lowered to restart it, and the next one waits until it is ready and up in the
+// ring. The progress is kept in the status so an interrupted restart is resumed
+func (c Cluster) ReconcileRestart() (err error) {
+	r := c.Resource
+	requestedAt := r.RestartRequestedAt()
+	rs := r.Status.Restart
+
+	if len(requestedAt) == 0 {
+		return nil
+	}
+
+	if rs == nil || rs.RequestedAt != requestedAt {
+		logrus.Infof("Starting the rolling restart requested at %v", requestedAt)
+		r.Status.Restart = &v1alpha1.RestartStatus{RequestedAt: requestedAt, Ordinal: r.Spec.Size}
+		r.Status.SetRestartingCondition(requestedAt)
+		return sdk.Update(r)
+	}
+
+	if rs.Completed {
+		return nil
+	}
+
+	if rs.Ordinal > r.Spec.Size {
+		rs.Ordinal = r.Spec.Size
+	}
+
+	if rs.Ordinal < r.Spec.Size {
+		var restarted bool
+		restarted, err = isRestarted(r, rs.Ordinal)
+		if err != nil || !restarted {
+			return err
+		}
+	}
+
+	if rs.Ordinal <= r.Spec.Partition {
+		logrus.Infof("Finished the rolling restart requested at %v", requestedAt)
+		rs.Completed = true
+		r.Status.ClearRestartingCondition()
+		return sdk.Update(r)
+	}
+
+	next := rs.Ordinal - 1
+	podName := podNameForCassandra(r, next)
+	logrus.Infof("Draining %v before restarting it", podName)
+	_, err = runNodetool(r, podName, "drain")
+	if err != nil {
+		return err
+	}
+
+	rs.Ordinal = next
+	return sdk.Update(r)
+}
+
+// ReconcileHosts records the identity in the ring of every node up and normal. A pod
+// that lost its volume replaces its previous node using the recorded address, the
+// replacement is tracked until the new node is up and normal
+func (c Cluster) ReconcileHosts() (err error) {
+	r := c.Resource
+	cm := HostsConfigMap(r)
+	err = sdk.Get(cm)
+	if apierrors.IsNotFound(err) {
+		cm = HostsConfigMap(r)
+		err = sdk.Create(cm)
+	}
+	if err != nil {
+		return err
+	}
+
+	pods, err := podsForCassandra(r)
+	if err != nil {
+		return err
+	}
+	ring, err := ringFromReadyPod(r, pods)
+	if err != nil {
+		logrus.Infof("Skipping hosts reconciliation: %v", err)
+		return nil
+	}
+
+	status := r.Status.DeepCopy()
+	data := map[string]string{}
+	for k, v := range cm.Data {
+		data[k] = v
+	}
+
+	for i := range pods {
+		p := &pods[i]
+		if len(p.Status.PodIP) == 0 {
+			continue
+		}
+		replacing := r.Status.Members.IsReplacing(p.Name)
+		host := r.Status.Members.Host(p.Name)
+		node := ring.ByAddress(p.Status.PodIP)
+
+		if node != nil && node.IsUpNormal() {
+			if replacing {
+				_, _, err = exec.CommandInContainer(p.Name, cassandraContainerName, r.Namespace, "rm", "-f", replacingMarker)
+				if err != nil {
+					return err
+				}
+				logrus.Infof("%v finished the replacement of its previous node", p.Name)
+				r.Status.Members.SetReplacing(p.Name, false)
+			} else if host != nil && host.HostID != node.HostID {
+				logrus.Warnf("%v changed its host ID from %v to %v without replacing the previous node", p.Name, host.HostID, node.HostID)
+			}
+			r.Status.Members.SetHost(v1alpha1.MemberHost{Node: p.Name, HostID: node.HostID, Address: node.Address})
+			data[p.Name] = node.Address
+			continue
+		}
+
+		if !replacing && host != nil {
+			// the marker is only found when the pod started with an empty volume
+			out, _, catErr := exec.CommandInContainer(p.Name, cassandraContainerName, r.Namespace, "cat", replacingMarker)
+			if catErr == nil && len(strings.TrimSpace(out)) > 0 {
+				logrus.Infof("%v lost its volume, it is replacing %v", p.Name, strings.TrimSpace(out))
+				r.Status.Members.SetReplacing(p.Name, true)
+			}
+		}
+	}
+
+	if len(r.Status.Members.Replacing) > 0 {
+		r.Status.SetReplacingCondition(r.Status.Members.Replacing)
+	} else {
+		r.Status.ClearReplacingCondition()
+	}
+
+	// addresses are only added, data is empty if the config map is
+	if len(data) > 0 && !reflect.DeepEqual(data, cm.Data) {
+		cm.Data = data
+		err = sdk.Update(cm)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !reflect.DeepEqual(status, &r.Status) {
+		err = sdk.Update(r)
+	}
+	return err
+}
+
+// ReconcileDeadNodes removes from the ring the nodes down longer than the dead node
+// grace period whose pod or volume are gone. They are removed one at a time with
+// nodetool removenode run from a healthy peer, or nodetool assassinate if allowed
+// when removenode fails
+func (c Cluster) ReconcileDeadNodes() (err error) {
+	r := c.Resource
+	if r.Spec.DeadNodeGracePeriodSeconds <= 0 {
+		return nil
+	}
+
+	pods, err := podsForCassandra(r)
+	if err != nil {
+		return err
+	}
+	peer := readyPodName(pods)
+	if len(peer) == 0 {
+		logrus.Infof("Skipping dead nodes reconciliation: no ready pods")
+		return nil
+	}
+	ring, err := ringForCassandra(r, peer)
+	if err != nil {
+		return err
+	}
+	volumes, err := volumesForCassandra(r)
+	if err != nil {
+		return err
+	}
+
+	status := r.Status.DeepCopy()
+	var dead []v1alpha1.DeadHost
+	for _, node := range ring {
+		if !node.IsDown() || !isLost(r, node, pods, volumes) {
+			continue
+		}
+		d := r.Status.Members.DeadHost(node.HostID)
+		if d == nil {
+			d = &v1alpha1.DeadHost{HostID: node.HostID, Address: node.Address, Since: time.Now().Format(time.RFC3339)}
+			recordEvent(r, v1.EventTypeWarning, "DeadNode", "Node %v (%v) is down and its pod or volume are gone", d.HostID, d.Address)
+		}
+		dead = append(dead, *d)
+	}
+	r.Status.Members.Dead = dead
+	if !reflect.DeepEqual(status, &r.Status) {
+		err = sdk.Update(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	grace := time.Duration(r.Spec.DeadNodeGracePeriodSeconds) * time.Second
+	for i, d := range dead {
+		if since(d.Since) < grace {
+			continue
+		}
+		err = removeDeadHost(r, peer, d)
+		if err != nil {
+			return err
+		}
+		r.Status.Members.Dead = append(dead[:i], dead[i+1:]...)
+		for _, h := range r.Status.Members.Hosts {
+			if h.HostID == d.HostID {
+				r.Status.Members.RemoveHost(h.Node)
+			}
+		}
+		return sdk.Update(r)
+	}
+	return nil
+}
+
+// ReconcileRepair runs the scheduled repairs. Every run repairs the keyspaces one after
+// the other on every node, one node at a time. The last successful repair of every
+// keyspace is recorded in the status, and a condition is set when it is overdue
+func (c Cluster) ReconcileRepair() (err error) {
+	r := c.Resource
+	spec := r.Spec.Repair
+	if spec == nil {
+		r.Status.ClearRepairOverdueCondition()
+		return nil
+	}
+
+	err = spec.Validate()
+	if err != nil {
+		return err
+	}
+	schedule, err := cron.Parse(spec.Schedule)
+	if err != nil {
+		return err
+	}
+
+	status := r.Status.DeepCopy()
+	if r.Status.Repair == nil {
+		r.Status.Repair = &v1alpha1.RepairStatus{}
+	}
+	rs := r.Status.Repair
+
+	switch {
+	case rs.Run != nil:
+		err = stepRepairRun(r)
+	case r.Status.Restart.IsInProgress(r.RestartRequestedAt()):
+		logrus.Infof("Rolling restart in progress, not starting repairs")
+	default:
+		now := time.Now()
+		due := dueRepair(r, schedule, now)
+		if !due.IsZero() {
+			rs.LastScheduleTime = due.Format(time.RFC3339)
+			rs.Run = &v1alpha1.RepairRun{StartedAt: now.Format(time.RFC3339), Keyspace: spec.Keyspaces[0]}
+			recordEvent(r, v1.EventTypeNormal, "RepairStarted", "Repair of keyspaces %v scheduled at %v started", spec.Keyspaces, rs.LastScheduleTime)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if overdue := overdueKeyspaces(r); len(overdue) > 0 {
+		r.Status.SetRepairOverdueCondition(overdue)
+	} else {
+		r.Status.ClearRepairOverdueCondition()
+	}
+
+	if !reflect.DeepEqual(status, &r.Status) {
+		err = sdk.Update(r)
+	}
 	return err
 }
 
@@ -144,7 +699,333 @@ func (c Cluster) FailedReconciliation(failedObjectName string, err error) error
 	return fmt.Errorf("[%s] API: %s Failed to reconcile %v %v", c.Resource.Namespace, c.Resource.Name, failedObjectName, err)
 }
 
+// ReconcileFinalizer adds the finalizer that applies the deletion policy
+func (c Cluster) ReconcileFinalizer() error {
+	r := c.Resource
+	if !r.AddFinalizer() {
+		return nil
+	}
+	return sdk.Update(r)
+}
+
+// Finalize applies the deletion policy to the cluster data and releases the finalizer.
+// It does nothing until the cluster is ready to be released, so it is run on every
+// event of a deleted Cassandra object
+func (c Cluster) Finalize() (err error) {
+	r := c.Resource
+	if !r.HasFinalizer() {
+		return nil
+	}
+
+	switch r.Spec.DeletionPolicy {
+	case v1alpha1.DeletionPolicyDelete:
+		var deleted bool
+		deleted, err = deleteVolumes(r)
+		if err != nil || !deleted {
+			return err
+		}
+	case v1alpha1.DeletionPolicySnapshotThenDelete:
+		var done, deleted bool
+		done, err = finalBackup(r)
+		if err != nil {
+			return fmt.Errorf("final backup failed, change the deletion policy to skip it: %v", err)
+		}
+		if !done {
+			return nil
+		}
+		deleted, err = deleteVolumes(r)
+		if err != nil || !deleted {
+			return err
+		}
+	default:
+		logrus.Infof("Retaining the volumes of %v", r.Name)
+	}
+
+	r.RemoveFinalizer()
+	return sdk.Update(r)
+}
+
 // SetDefaults sets defaults resource values
 func (c Cluster) SetDefaults() bool {
 	return c.Resource.SetDefaults()
 }
+
+// IsPaused returns if the reconciliation of the cluster is paused
+func (c Cluster) IsPaused() bool {
+	return c.Resource.Spec.Paused
+}
+
+// finalBackup backs the cluster up to its final backup storage before its volumes
+// are deleted. It returns true once the backup is completed
+func finalBackup(api *v1alpha1.Cassandra) (bool, error) {
+	if api.Spec.FinalBackup == nil {
+		return false, fmt.Errorf("the %v deletion policy requires finalBackup", api.Spec.DeletionPolicy)
+	}
+	err := api.Spec.FinalBackup.Validate()
+	if err != nil {
+		return false, err
+	}
+
+	b := finalBackupForCassandra(api)
+	err = sdk.Get(b)
+	if apierrors.IsNotFound(err) {
+		logrus.Infof("Creating final backup %v of %v", b.Name, api.Name)
+		return false, sdk.Create(finalBackupForCassandra(api))
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch b.Status.Phase {
+	case v1alpha1.BackupPhaseCompleted:
+		return true, nil
+	case v1alpha1.BackupPhaseFailed:
+		return false, fmt.Errorf("%v: %v", b.Name, b.Status.Reason)
+	default:
+		logrus.Infof("Waiting for final backup %v of %v", b.Name, api.Name)
+		return false, nil
+	}
+}
+
+// finalBackupForCassandra returns the final backup of the cluster. It is not owned
+// by the cluster, so it outlives it
+func finalBackupForCassandra(api *v1alpha1.Cassandra) *v1alpha1.CassandraBackup {
+	b := cassandraBackup(fmt.Sprintf("%s-final-%d", api.Name, api.DeletionTimestamp.Unix()), api.Namespace)
+	b.Spec = v1alpha1.CassandraBackupSpec{
+		Cluster: api.Name,
+		Storage: *api.Spec.FinalBackup,
+	}
+	return b
+}
+
+// deleteVolumes deletes the statefulset and, once all its pods are gone, the
+// persistent volume claims of the cluster. It returns false while pods remain
+func deleteVolumes(api *v1alpha1.Cassandra) (bool, error) {
+	err := sdk.Delete(StatefulSet(api))
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, err
+	}
+
+	nodes, err := nodesForCassandra(api)
+	if err != nil {
+		return false, err
+	}
+	if len(nodes) > 0 {
+		logrus.Infof("Waiting for pods %v to terminate before deleting the volumes", nodes)
+		return false, nil
+	}
+
+	volumes, err := volumesForCassandra(api)
+	if err != nil {
+		return false, err
+	}
+	for _, name := range volumes {
+		logrus.Infof("Deleting volume %v", name)
+		err = sdk.Delete(pvc(name, api.Namespace))
+		if err != nil && !apierrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// isRestarted returns if the pod with the given ordinal runs the statefulset update
+// revision, is ready and is up and normal in the ring
+func isRestarted(api *v1alpha1.Cassandra, ordinal int32) (bool, error) {
+	ss := StatefulSet(api)
+	err := sdk.Get(ss)
+	if err != nil {
+		return false, err
+	}
+
+	p := pod(podNameForCassandra(api, ordinal), api.Namespace)
+	err = sdk.Get(p)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if p.Labels[appsv1.StatefulSetRevisionLabel] != ss.Status.UpdateRevision || !isPodReady(p) {
+		logrus.Infof("Waiting for %v to be restarted", p.Name)
+		return false, nil
+	}
+
+	ring, err := ringForCassandra(api, p.Name)
+	if err != nil {
+		return false, err
+	}
+	node := ring.ByAddress(p.Status.PodIP)
+	if node == nil || !node.IsUpNormal() {
+		logrus.Infof("Waiting for %v to be up and normal in the ring", p.Name)
+		return false, nil
+	}
+	return true, nil
+}
+
+// shutdownsForCassandra returns how the previous container of every pod was stopped,
+// as recorded by its postStart hook. The record is read once per container
+func shutdownsForCassandra(api *v1alpha1.Cassandra, pods []v1.Pod) []v1alpha1.NodeShutdown {
+	var shutdowns []v1alpha1.NodeShutdown
+	for i := range pods {
+		p := &pods[i]
+		startedAt := containerStartedAt(p, cassandraContainerName)
+		if len(startedAt) == 0 || !isPodReady(p) {
+			continue
+		}
+
+		s := api.Status.Members.Shutdown(p.Name)
+		if s != nil && s.StartedAt == startedAt {
+			shutdowns = append(shutdowns, *s)
+			continue
+		}
+
+		shutdown := v1alpha1.NodeShutdown{Node: p.Name, StartedAt: startedAt}
+		out, err := exec.ContainerCommand(p.Name, cassandraContainerName, api.Namespace, "cat", lastShutdownMarker)
+		switch last := strings.TrimSpace(out); {
+		case err != nil:
+			logrus.Warnf("Could not read the last shutdown of %v: %v", p.Name, err)
+			shutdown.Shutdown = v1alpha1.ShutdownUnknown
+		case last == lastShutdownNone:
+			shutdown.Shutdown = v1alpha1.ShutdownNone
+		case last == lastShutdownUnclean:
+			logrus.Warnf("%v was stopped without being drained", p.Name)
+			shutdown.Shutdown = v1alpha1.ShutdownUnclean
+		default:
+			shutdown.Shutdown = v1alpha1.ShutdownDrained
+			shutdown.DrainedAt = last
+		}
+		shutdowns = append(shutdowns, shutdown)
+	}
+	return shutdowns
+}
+
+// recoveryPointsForCassandra returns the recovery point of the continuous backup of
+// every ready pod, as recorded by its backup agent
+func recoveryPointsForCassandra(api *v1alpha1.Cassandra, pods []v1.Pod, now time.Time) []v1alpha1.NodeRecoveryPoint {
+	if api.Spec.ContinuousBackup == nil {
+		return nil
+	}
+	var points []v1alpha1.NodeRecoveryPoint
+	for i := range pods {
+		p := &pods[i]
+		if !isPodReady(p) {
+			continue
+		}
+		out, err := exec.ContainerCommand(p.Name, cassandraContainerName, api.Namespace, "sh", "-c", "cat "+recoveryPointMarker+" 2>/dev/null || true")
+		if err != nil {
+			logrus.Warnf("Could not read the recovery point of %v: %v", p.Name, err)
+			if last := api.Status.RecoveryPoint(p.Name); last != nil {
+				points = append(points, *last)
+			}
+			continue
+		}
+		points = append(points, recoveryPoint(p.Name, out, now))
+	}
+	return points
+}
+
+// recoveryPoint returns the recovery point of the node recorded by its backup agent
+func recoveryPoint(node, recorded string, now time.Time) v1alpha1.NodeRecoveryPoint {
+	point := v1alpha1.NodeRecoveryPoint{Node: node}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(recorded))
+	if err != nil {
+		return point
+	}
+	point.RecoveryPoint = t.Format(time.RFC3339)
+	point.LagSeconds = int64(now.Sub(t) / time.Second)
+	return point
+}
+
+// forgetHost removes the recorded host of the given pod
+func forgetHost(api *v1alpha1.Cassandra, podName string) error {
+	cm := HostsConfigMap(api)
+	err := sdk.Get(cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if _, ok := cm.Data[podName]; ok {
+		delete(cm.Data, podName)
+		err = sdk.Update(cm)
+		if err != nil {
+			return err
+		}
+	}
+
+	if api.Status.Members.Host(podName) == nil {
+		return nil
+	}
+	api.Status.Members.RemoveHost(podName)
+	return sdk.Update(api)
+}
+
+// isLost returns if the pod or the volume of a node are gone. The node is matched to
+// its pod by address, or by the host recorded in the status when the pod is not running.
+// A node whose host is not recorded is never lost: it may belong to another datacenter,
+// have never been up, or predate the recording of the hosts
+func isLost(api *v1alpha1.Cassandra, node nodetool.Node, pods []v1.Pod, volumes []string) bool {
+	for _, p := range pods {
+		if p.Status.PodIP == node.Address {
+			return false
+		}
+	}
+
+	for _, h := range api.Status.Members.Hosts {
+		if h.HostID != node.HostID {
+			continue
+		}
+		for _, p := range pods {
+			if p.Name == h.Node {
+				return !contains(volumes, volumeNameForPod(h.Node))
+			}
+		}
+		return true
+	}
+
+	// no pod of this cluster is known to have had this host
+	return false
+}
+
+// removeDeadHost removes a dead host from the ring running nodetool from the peer pod
+func removeDeadHost(api *v1alpha1.Cassandra, peer string, d v1alpha1.DeadHost) error {
+	recordEvent(api, v1.EventTypeWarning, "RemovingNode", "Removing node %v (%v) down since %v with nodetool removenode from %v", d.HostID, d.Address, d.Since, peer)
+	_, err := runNodetool(api, peer, "removenode", d.HostID)
+	if err == nil {
+		recordEvent(api, v1.EventTypeNormal, "RemovedNode", "Removed node %v (%v)", d.HostID, d.Address)
+		return nil
+	}
+	recordEvent(api, v1.EventTypeWarning, "RemoveNodeFailed", "Failed to remove node %v (%v): %v", d.HostID, d.Address, err)
+
+	if !api.Spec.AssassinateDeadNodes {
+		return err
+	}
+
+	recordEvent(api, v1.EventTypeWarning, "AssassinatingNode", "Assassinating node %v (%v) with nodetool assassinate from %v", d.HostID, d.Address, peer)
+	_, err = runNodetool(api, peer, "assassinate", d.Address)
+	if err != nil {
+		recordEvent(api, v1.EventTypeWarning, "AssassinateFailed", "Failed to assassinate node %v (%v): %v", d.HostID, d.Address, err)
+		return err
+	}
+	recordEvent(api, v1.EventTypeNormal, "AssassinatedNode", "Assassinated node %v (%v)", d.HostID, d.Address)
+	return nil
+}
+
+// since returns the time elapsed since the given RFC3339 time, zero if it cannot be parsed
+func since(t string) time.Duration {
+	parsed, err := time.Parse(time.RFC3339, t)
+	if err != nil {
+		return 0
+	}
+	return time.Since(parsed)
+}
+
+// contains returns if the string is in the list
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
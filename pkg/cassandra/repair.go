@@ -0,0 +1,180 @@
+package cassandra
+
+import (
+	"fmt"
+	"time"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/nodetool"
+	"github.com/camilocot/cassandra-operator/pkg/util/cron"
+	"github.com/sirupsen/logrus"
+
+	"github.com/operator-framework/operator-sdk/pkg/sdk"
+	"k8s.io/api/core/v1"
+)
+
+// dueRepair returns the latest schedule time of the repairs not started yet, zero
+// if no repair is due
+func dueRepair(api *v1alpha1.Cassandra, schedule *cron.Schedule, now time.Time) time.Time {
+	var last string
+	if api.Status.Repair != nil {
+		last = api.Status.Repair.LastScheduleTime
+	}
+	return dueSchedule(schedule, api.CreationTimestamp, last, now)
+}
+
+// stepRepairRun runs the repair of the current keyspace on the current node of the
+// run. A repair is started when the previous one has finished, so a single node
+// is repaired at a time and replicas of the same range are never repaired concurrently
+func stepRepairRun(api *v1alpha1.Cassandra) error {
+	spec := api.Spec.Repair
+	rs := api.Status.Repair
+	run := rs.Run
+
+	if run.Ordinal >= api.Spec.Size {
+		for _, k := range spec.Keyspaces {
+			if !contains(run.Failed, k) {
+				rs.SetLastSuccessfulRepair(k, run.StartedAt)
+			}
+		}
+		if len(run.Failed) > 0 {
+			recordEvent(api, v1.EventTypeWarning, "RepairFailed", "Repair started at %v failed for keyspaces %v", run.StartedAt, run.Failed)
+		} else {
+			recordEvent(api, v1.EventTypeNormal, "RepairCompleted", "Repair started at %v completed", run.StartedAt)
+		}
+		rs.Run = nil
+		return nil
+	}
+
+	podName := podNameForCassandra(api, run.Ordinal)
+	p := pod(podName, api.Namespace)
+	err := sdk.Get(p)
+	if err != nil {
+		return err
+	}
+	if !isPodReady(p) {
+		logrus.Infof("Waiting for %v to be ready to repair %v", podName, run.Keyspace)
+		return nil
+	}
+
+	if len(run.Job) == 0 {
+		var cmds [][]string
+		cmds, err = repairCommands(api, podName, p.Status.PodIP, run.Keyspace)
+		if err != nil {
+			failRepair(api, podName, err.Error())
+			return nil
+		}
+		if len(cmds) == 0 {
+			logrus.Infof("%v has no ranges of %v to repair", podName, run.Keyspace)
+			nextRepair(api)
+			return nil
+		}
+		job := "repair-" + run.Keyspace
+		logrus.Infof("Repairing %v on %v", run.Keyspace, podName)
+		err = startJob(api, podName, job, cmds...)
+		if err != nil {
+			return err
+		}
+		run.Job = job
+		return nil
+	}
+
+	state, err := jobState(api, podName, run.Job)
+	if err != nil {
+		failRepair(api, podName, err.Error())
+		return nil
+	}
+	switch state {
+	case jobRunning:
+		logrus.Infof("Waiting for the repair of %v on %v", run.Keyspace, podName)
+		return nil
+	case jobSucceeded:
+		logrus.Infof("Repaired %v on %v", run.Keyspace, podName)
+		nextRepair(api)
+	default:
+		failRepair(api, podName, fmt.Sprintf("%v: %v", state, jobOutput(api, podName, run.Job)))
+	}
+	return nil
+}
+
+// failRepair records the failure of the repair of the current keyspace on the pod
+// and moves the run on, so that a keyspace that can't be repaired doesn't block
+// the rest of the run
+func failRepair(api *v1alpha1.Cassandra, podName, reason string) {
+	run := api.Status.Repair.Run
+	recordEvent(api, v1.EventTypeWarning, "RepairFailed", "Repair of %v on %v failed: %v", run.Keyspace, podName, reason)
+	if !contains(run.Failed, run.Keyspace) {
+		run.Failed = append(run.Failed, run.Keyspace)
+	}
+	nextRepair(api)
+}
+
+// nextRepair moves the run to the next keyspace, or to the first keyspace of the
+// next node once all the keyspaces of the node are repaired
+func nextRepair(api *v1alpha1.Cassandra) {
+	keyspaces := api.Spec.Repair.Keyspaces
+	run := api.Status.Repair.Run
+	run.Job = ""
+
+	for i, k := range keyspaces {
+		if k == run.Keyspace && i+1 < len(keyspaces) {
+			run.Keyspace = keyspaces[i+1]
+			return
+		}
+	}
+	run.Ordinal++
+	run.Keyspace = keyspaces[0]
+}
+
+// repairCommands returns the nodetool repair commands that repair the keyspace on the pod
+func repairCommands(api *v1alpha1.Cassandra, podName, address, keyspace string) ([][]string, error) {
+	spec := api.Spec.Repair
+	args := []string{"repair"}
+	if !spec.Incremental {
+		args = append(args, "-full")
+	}
+
+	if !spec.Subrange {
+		if spec.PrimaryRange {
+			args = append(args, "-pr")
+		}
+		return [][]string{nodetoolCommand(api, append(args, keyspace)...)}, nil
+	}
+
+	out, err := runNodetool(api, podName, "describering", keyspace)
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := nodetool.ParseDescribeRing(out)
+	if err != nil {
+		return nil, err
+	}
+
+	var cmds [][]string
+	for _, tr := range nodetool.PrimaryRanges(ranges, address) {
+		rangeArgs := append(append([]string{}, args...), "-st", tr.Start, "-et", tr.End, keyspace)
+		cmds = append(cmds, nodetoolCommand(api, rangeArgs...))
+	}
+	return cmds, nil
+}
+
+// overdueKeyspaces returns the keyspaces not successfully repaired within the overdue
+// period. Keyspaces never repaired are overdue once the cluster is older than it
+func overdueKeyspaces(api *v1alpha1.Cassandra) []string {
+	spec := api.Spec.Repair
+	overdue := time.Duration(spec.OverdueAfterSeconds) * time.Second
+
+	var keyspaces []string
+	for _, k := range spec.Keyspaces {
+		last := api.CreationTimestamp.Format(time.RFC3339)
+		if api.Status.Repair != nil {
+			if t := api.Status.Repair.LastSuccessfulRepair(k); len(t) > 0 {
+				last = t
+			}
+		}
+		if since(last) > overdue {
+			keyspaces = append(keyspaces, k)
+		}
+	}
+	return keyspaces
+}
@@ -0,0 +1,676 @@
+package cassandra
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/backupagent"
+	"github.com/camilocot/cassandra-operator/pkg/cql"
+	"github.com/camilocot/cassandra-operator/pkg/exec"
+	"github.com/camilocot/cassandra-operator/pkg/nodetool"
+	"github.com/camilocot/cassandra-operator/pkg/util/s3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/operator-framework/operator-sdk/pkg/sdk"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+var (
+	// schemaStatementRegexp matches the CREATE statements of a schema, with the kind
+	// of the element created, its name and the table of an index
+	schemaStatementRegexp = regexp.MustCompile(`^CREATE (KEYSPACE|TABLE|TYPE|INDEX|CUSTOM INDEX|MATERIALIZED VIEW|FUNCTION|AGGREGATE) (?:IF NOT EXISTS )?(\S+)(?: ON (\S+))?`)
+	// tableIDRegexp matches the ID appended to the name of a table directory
+	tableIDRegexp = regexp.MustCompile(`-[0-9a-f]{32}$`)
+)
+
+// commitlogPointInTimeLayout is the layout of restore_point_in_time, in UTC
+const commitlogPointInTimeLayout = "2006:01:02 15:04:05"
+
+// RestoreController manages reconciliation of a Cassandra restore
+type RestoreController interface {
+	ReconcileRestore() error
+}
+
+// Restore represents the restore of a backup into a Cassandra cluster
+type Restore struct {
+	Resource *v1alpha1.CassandraRestore
+
+	RestoreController
+}
+
+// NewCassandraRestore creates a new Cassandra Restore object
+func NewCassandraRestore(r *v1alpha1.CassandraRestore) *Restore {
+	return &Restore{Resource: r}
+}
+
+// ReconcileRestore restores the backup one step at a time: the cluster is created if
+// it does not exist, then the schema is applied and the files of every backed up
+// node are staged in a pod and loaded. The restore fails on any error
+func (r Restore) ReconcileRestore() (err error) {
+	rs := r.Resource
+	if rs.Status.IsFinished() {
+		return nil
+	}
+
+	if len(rs.Status.Phase) == 0 {
+		err = startRestore(rs)
+		if err != nil {
+			return failRestore(rs, err)
+		}
+		return sdk.Update(rs)
+	}
+
+	cluster := cassandra(rs.Spec.Cluster, rs.Namespace)
+	err = sdk.Get(cluster)
+	if err != nil {
+		return failRestore(rs, fmt.Errorf("could not get cluster %v: %v", rs.Spec.Cluster, err))
+	}
+	if cluster.Spec.Paused {
+		logrus.Infof("Reconciliation of %v paused, waiting to restore it", cluster.Name)
+		return nil
+	}
+	pods, err := podsForCassandra(cluster)
+	if err != nil {
+		return err
+	}
+	if !allPodsReady(cluster, pods) {
+		logrus.Infof("Waiting for the pods of %v to be ready to restore it", cluster.Name)
+		return nil
+	}
+	if cluster.PasswordAuthentication() && !cluster.Status.Auth.IsAdminRoleCreated() {
+		logrus.Infof("Waiting for the admin role of %v to restore it", cluster.Name)
+		return nil
+	}
+
+	err = restoreStep(rs, cluster, pods)
+	if err != nil {
+		return failRestore(rs, err)
+	}
+	return sdk.Update(rs)
+}
+
+// allPodsReady returns if the cluster has all its pods and they are ready
+func allPodsReady(api *v1alpha1.Cassandra, pods []v1.Pod) bool {
+	if int32(len(pods)) != api.Spec.Size {
+		return false
+	}
+	for i := range pods {
+		if !isPodReady(&pods[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// startRestore resolves the backup restored and lists its nodes. The cluster is
+// created with the tokens of the backed up nodes when it does not exist
+func startRestore(rs *v1alpha1.CassandraRestore) error {
+	err := rs.Spec.Validate()
+	if err != nil {
+		return err
+	}
+
+	source := rs.Spec.Source
+	if len(rs.Spec.Backup) > 0 {
+		b := cassandraBackup(rs.Spec.Backup, rs.Namespace)
+		err = sdk.Get(b)
+		if err != nil {
+			return fmt.Errorf("could not get backup %v: %v", rs.Spec.Backup, err)
+		}
+		if b.Status.Phase != v1alpha1.BackupPhaseCompleted {
+			return fmt.Errorf("backup %v is not completed", b.Name)
+		}
+		source = &v1alpha1.BackupSource{Storage: b.Spec.Storage, Cluster: b.Spec.Cluster, Name: b.Status.Name}
+	}
+
+	storage := source.Storage
+	client, err := storageClient(rs.Namespace, storage)
+	if err != nil {
+		return err
+	}
+	var manifest backupManifest
+	err = getJSON(client, storage.Bucket, storage.Key(source.Cluster, source.Name, manifestObject), &manifest)
+	if err != nil {
+		return fmt.Errorf("could not get the manifest of backup %v: %v", source.Name, err)
+	}
+	if len(manifest.Nodes) == 0 {
+		return fmt.Errorf("backup %v has no nodes", source.Name)
+	}
+	if len(rs.Spec.PointInTime) > 0 && since(rs.Spec.PointInTime) > since(manifest.StartedAt) {
+		return fmt.Errorf("point in time %v is before the start of backup %v", rs.Spec.PointInTime, source.Name)
+	}
+
+	rs.Status.Phase = v1alpha1.RestorePhaseRunning
+	rs.Status.StartedAt = time.Now().Format(time.RFC3339)
+	rs.Status.Source = source
+	rs.Status.Nodes = nil
+	for _, key := range manifest.Nodes {
+		rs.Status.Nodes = append(rs.Status.Nodes, v1alpha1.RestoreNode{Node: path.Base(path.Dir(key)), Manifest: key})
+	}
+
+	cluster := cassandra(rs.Spec.Cluster, rs.Namespace)
+	err = sdk.Get(cluster)
+	if err == nil {
+		recordEvent(rs, v1.EventTypeNormal, "RestoreStarted", "Restoring backup %v into cluster %v", source.Name, cluster.Name)
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("could not get cluster %v: %v", rs.Spec.Cluster, err)
+	}
+	if rs.Spec.ClusterSpec == nil {
+		return fmt.Errorf("cluster %v does not exist and no cluster spec is given", rs.Spec.Cluster)
+	}
+
+	err = provisionCluster(rs, cluster, client)
+	if err != nil {
+		return err
+	}
+	recordEvent(rs, v1.EventTypeNormal, "RestoreStarted", "Restoring backup %v into new cluster %v", source.Name, cluster.Name)
+	return nil
+}
+
+// provisionCluster creates the cluster with a node per backed up node. Every node
+// starts with the tokens of its backed up node, so its files are loaded with refresh
+func provisionCluster(rs *v1alpha1.CassandraRestore, cluster *v1alpha1.Cassandra, client *s3.Client) error {
+	storage := rs.Status.Source.Storage
+	rs.Spec.ClusterSpec.DeepCopyInto(&cluster.Spec)
+	cluster.Spec.Size = int32(len(rs.Status.Nodes))
+
+	cm := TokensConfigMap(cluster)
+	cm.Data = map[string]string{}
+	for i := range rs.Status.Nodes {
+		n := &rs.Status.Nodes[i]
+		var manifest nodeManifest
+		err := getJSON(client, storage.Bucket, n.Manifest, &manifest)
+		if err != nil {
+			return fmt.Errorf("could not get the manifest of %v: %v", n.Node, err)
+		}
+		n.Target = podNameForCassandra(cluster, int32(i))
+		cm.Data[n.Target] = strings.Join(manifest.Tokens, ",")
+	}
+
+	err := sdk.Create(cm)
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not create the tokens of cluster %v: %v", cluster.Name, err)
+	}
+	err = sdk.Create(cluster)
+	if err != nil {
+		return fmt.Errorf("could not create cluster %v: %v", cluster.Name, err)
+	}
+
+	// the tokens are deleted with the cluster
+	addOwnerRefToObject(cm, asOwner(cluster))
+	err = sdk.Update(cm)
+	if err != nil {
+		logrus.Warnf("Could not set the owner of %v: %v", cm.Name, err)
+	}
+
+	rs.Status.Provisioned = true
+	rs.Status.Method = v1alpha1.RestoreMethodRefresh
+	return nil
+}
+
+// restoreStep applies the schema, maps the backed up nodes to the pods or loads the
+// next backed up node
+func restoreStep(rs *v1alpha1.CassandraRestore, cluster *v1alpha1.Cassandra, pods []v1.Pod) error {
+	storage := rs.Status.Source.Storage
+	client, err := storageClient(rs.Namespace, storage)
+	if err != nil {
+		return err
+	}
+
+	if !rs.Status.SchemaRestored {
+		var body io.ReadCloser
+		body, err = client.GetObject(storage.Bucket, storage.Key(rs.Status.Source.Cluster, rs.Status.Source.Name, schemaObject))
+		if err != nil {
+			return fmt.Errorf("could not get the schema: %v", err)
+		}
+		var schema []byte
+		schema, err = ioutil.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("could not get the schema: %v", err)
+		}
+		// the commitlog segments are only replayed into tables with their IDs
+		var ids map[string]string
+		if len(rs.Spec.PointInTime) > 0 {
+			var manifest nodeManifest
+			err = getJSON(client, storage.Bucket, rs.Status.Nodes[0].Manifest, &manifest)
+			if err != nil {
+				return fmt.Errorf("could not get the manifest of %v: %v", rs.Status.Nodes[0].Node, err)
+			}
+			ids = tableIDs(manifest.Files)
+			err = checkTableIDs(cluster, pods[0].Name, ids)
+			if err != nil {
+				return err
+			}
+		}
+		statements := restoreSchema(string(schema), rs.Spec.Keyspaces, ids)
+		logrus.Infof("Restoring the schema of backup %v into %v", rs.Status.Source.Name, cluster.Name)
+		err = runCqlshInput(cluster, pods[0].Name, statements)
+		if err != nil {
+			return fmt.Errorf("could not restore the schema: %v", err)
+		}
+		rs.Status.SchemaRestored = true
+		return nil
+	}
+
+	if len(rs.Status.Method) == 0 {
+		return mapRestoreNodes(rs, cluster, client)
+	}
+
+	for i := range rs.Status.Nodes {
+		n := &rs.Status.Nodes[i]
+		if !n.Restored {
+			return restoreNode(rs, cluster, client, n)
+		}
+	}
+
+	if len(rs.Spec.PointInTime) > 0 {
+		for i := range rs.Status.Nodes {
+			n := &rs.Status.Nodes[i]
+			if !n.Replayed {
+				return replayNode(rs, cluster, client, n)
+			}
+		}
+	}
+
+	rs.Status.Phase = v1alpha1.RestorePhaseCompleted
+	rs.Status.CompletedAt = time.Now().Format(time.RFC3339)
+	recordEvent(rs, v1.EventTypeNormal, "RestoreCompleted", "Restored %v nodes of backup %v into %v", len(rs.Status.Nodes), rs.Status.Source.Name, cluster.Name)
+	return nil
+}
+
+// mapRestoreNodes chooses the pod every backed up node is loaded from. When every
+// backed up node has the tokens of a node of the cluster its files are refreshed
+// there, otherwise they are streamed with sstableloader
+func mapRestoreNodes(rs *v1alpha1.CassandraRestore, cluster *v1alpha1.Cassandra, client *s3.Client) error {
+	storage := rs.Status.Source.Storage
+
+	var backupTokens [][]string
+	for _, n := range rs.Status.Nodes {
+		var manifest nodeManifest
+		err := getJSON(client, storage.Bucket, n.Manifest, &manifest)
+		if err != nil {
+			return fmt.Errorf("could not get the manifest of %v: %v", n.Node, err)
+		}
+		backupTokens = append(backupTokens, manifest.Tokens)
+	}
+
+	var podNames []string
+	podTokens := map[string][]string{}
+	for ordinal := int32(0); ordinal < cluster.Spec.Size; ordinal++ {
+		podName := podNameForCassandra(cluster, ordinal)
+		out, err := runNodetool(cluster, podName, "info", "-T")
+		if err != nil {
+			return err
+		}
+		info, err := nodetool.ParseInfo(out)
+		if err != nil {
+			return err
+		}
+		podNames = append(podNames, podName)
+		podTokens[podName] = info.Tokens
+	}
+
+	method, targets := restoreTargets(backupTokens, podNames, podTokens)
+	if method != v1alpha1.RestoreMethodRefresh && len(rs.Spec.PointInTime) > 0 {
+		return fmt.Errorf("point-in-time restores require a node with the tokens of every backed up node")
+	}
+	for i := range rs.Status.Nodes {
+		rs.Status.Nodes[i].Target = targets[i]
+	}
+	rs.Status.Method = method
+	logrus.Infof("Restoring backup %v into %v with %v", rs.Status.Source.Name, cluster.Name, method)
+	return nil
+}
+
+// restoreTargets returns the method and the pods the backed up nodes are loaded
+// from. Refresh is used when the tokens of every backed up node are the ones of a
+// pod, sstableloader otherwise, spreading the nodes between the pods
+func restoreTargets(backupTokens [][]string, podNames []string, podTokens map[string][]string) (v1alpha1.RestoreMethod, []string) {
+	byTokens := map[string]string{}
+	for _, p := range podNames {
+		byTokens[tokensKey(podTokens[p])] = p
+	}
+
+	var targets []string
+	for _, tokens := range backupTokens {
+		p, ok := byTokens[tokensKey(tokens)]
+		if !ok {
+			break
+		}
+		targets = append(targets, p)
+	}
+	if len(targets) == len(backupTokens) {
+		return v1alpha1.RestoreMethodRefresh, targets
+	}
+
+	targets = nil
+	for i := range backupTokens {
+		targets = append(targets, podNames[i%len(podNames)])
+	}
+	return v1alpha1.RestoreMethodSSTableLoader, targets
+}
+
+// tokensKey returns the tokens sorted as a single string
+func tokensKey(tokens []string) string {
+	sorted := append([]string{}, tokens...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// restoreNode stages the files of the backed up node in its target pod and starts the
+// job that loads them, or records the result of the job once it has finished
+func restoreNode(rs *v1alpha1.CassandraRestore, cluster *v1alpha1.Cassandra, client *s3.Client, n *v1alpha1.RestoreNode) error {
+	storage := rs.Status.Source.Storage
+	job := "restore-" + rs.Name
+
+	if len(rs.Status.Job) == 0 {
+		var manifest nodeManifest
+		err := getJSON(client, storage.Bucket, n.Manifest, &manifest)
+		if err != nil {
+			return fmt.Errorf("could not get the manifest of %v: %v", n.Node, err)
+		}
+
+		var tables [][2]string
+		staged := map[[2]string]bool{}
+		for _, f := range manifest.Files {
+			if !isRestoredFile(f, rs.Spec.Keyspaces) {
+				continue
+			}
+			table := [2]string{f.Keyspace, tableName(f.Table)}
+			dst := strings.Join([]string{restoreDataPath, table[0], table[1], f.Name}, "/")
+			err = downloadFile(cluster, client, storage.Bucket, f.Key, n.Target, dst)
+			if err != nil {
+				return fmt.Errorf("could not stage %v of %v in %v: %v", f.Name, n.Node, n.Target, err)
+			}
+			if !staged[table] {
+				staged[table] = true
+				tables = append(tables, table)
+			}
+		}
+		if len(tables) == 0 {
+			logrus.Infof("%v has no files to restore", n.Node)
+			n.Restored = true
+			return nil
+		}
+
+		p := pod(n.Target, cluster.Namespace)
+		err = sdk.Get(p)
+		if err != nil {
+			return err
+		}
+		cmds := restoreCommands(cluster, rs.Status.Method, p.Status.PodIP, tables)
+		logrus.Infof("Loading %v tables of %v in %v", len(tables), n.Node, n.Target)
+		err = startJob(cluster, n.Target, job, cmds...)
+		if err != nil {
+			return err
+		}
+		rs.Status.Job = job
+		return nil
+	}
+
+	state, err := jobState(cluster, n.Target, job)
+	if err != nil {
+		return err
+	}
+	switch state {
+	case jobRunning:
+		logrus.Infof("Waiting for %v to be loaded in %v", n.Node, n.Target)
+		return nil
+	case jobSucceeded:
+		logrus.Infof("Restored %v in %v", n.Node, n.Target)
+		recordEvent(rs, v1.EventTypeNormal, "NodeRestored", "Restored %v in %v", n.Node, n.Target)
+		n.Restored = true
+		rs.Status.Job = ""
+		return nil
+	default:
+		return fmt.Errorf("could not load %v in %v (%v): %v", n.Node, n.Target, state, jobOutput(cluster, n.Target, job))
+	}
+}
+
+// replayNode stages the commitlog segments of the backed up node archived since the
+// start of the backup in its target pod, and restarts the pod so they are replayed
+// up to the point in time. The staged segments are removed once the pod is ready
+func replayNode(rs *v1alpha1.CassandraRestore, cluster *v1alpha1.Cassandra, client *s3.Client, n *v1alpha1.RestoreNode) error {
+	source := rs.Status.Source
+	storage := source.Storage
+
+	if len(n.ReplayStartedAt) == 0 {
+		var manifest backupManifest
+		err := getJSON(client, storage.Bucket, storage.Key(source.Cluster, source.Name, manifestObject), &manifest)
+		if err != nil {
+			return fmt.Errorf("could not get the manifest of backup %v: %v", source.Name, err)
+		}
+		pointInTime, err := time.Parse(time.RFC3339, rs.Spec.PointInTime)
+		if err != nil {
+			return err
+		}
+		objects, err := client.ListObjects(storage.Bucket, continuousBackupKey(storage, source.Cluster, n.Node, backupagent.CommitlogPrefix)+"/")
+		if err != nil {
+			return err
+		}
+		segments := segmentsToReplay(objects, manifest.StartedAt, pointInTime)
+		if len(segments) == 0 {
+			logrus.Infof("%v has no commitlog segments to replay", n.Node)
+			n.Replayed = true
+			return nil
+		}
+
+		for _, o := range segments {
+			err = downloadFile(cluster, client, storage.Bucket, o.Key, n.Target, commitlogRestorePath+"/"+path.Base(o.Key))
+			if err != nil {
+				return fmt.Errorf("could not stage %v of %v in %v: %v", path.Base(o.Key), n.Node, n.Target, err)
+			}
+		}
+		marker := fmt.Sprintf("echo '%s' > %s", pointInTime.UTC().Format(commitlogPointInTimeLayout), restorePointInTimeMarker)
+		_, err = exec.ContainerCommand(n.Target, cassandraContainerName, cluster.Namespace, "sh", "-c", marker) // #nosec
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("Restarting %v to replay %v commitlog segments of %v", n.Target, len(segments), n.Node)
+		err = sdk.Delete(pod(n.Target, cluster.Namespace))
+		if err != nil {
+			return err
+		}
+		n.ReplayStartedAt = time.Now().Format(time.RFC3339)
+		return nil
+	}
+
+	p := pod(n.Target, cluster.Namespace)
+	err := sdk.Get(p)
+	if err != nil {
+		return err
+	}
+	startedAt := containerStartedAt(p, cassandraContainerName)
+	if !isPodReady(p) || len(startedAt) == 0 || since(startedAt) > since(n.ReplayStartedAt) {
+		logrus.Infof("Waiting for %v to replay the commitlog segments of %v", n.Target, n.Node)
+		return nil
+	}
+
+	_, err = exec.ContainerCommand(n.Target, cassandraContainerName, cluster.Namespace, "rm", "-rf", commitlogRestorePath, restorePointInTimeMarker) // #nosec
+	if err != nil {
+		return err
+	}
+	recordEvent(rs, v1.EventTypeNormal, "NodeReplayed", "Replayed the commitlog of %v in %v up to %v", n.Node, n.Target, rs.Spec.PointInTime)
+	n.Replayed = true
+	return nil
+}
+
+// segmentsToReplay returns the commitlog segments shipped since the start of the
+// backup, in the order they were shipped, up to the first one shipped after the
+// point in time. Earlier segments were archived before the snapshot was taken, and
+// the snapshot flushed their writes. The first segment shipped after the point in
+// time may hold writes made before it, the later ones are not needed
+func segmentsToReplay(objects []s3.Object, backupStartedAt string, pointInTime time.Time) []s3.Object {
+	// every segment is replayed when the start of the backup is unknown
+	startedAt, _ := time.Parse(time.RFC3339, backupStartedAt)
+	shipped := make([]s3.Object, len(objects))
+	copy(shipped, objects)
+	sort.SliceStable(shipped, func(i, j int) bool {
+		return shipped[i].LastModified.Before(shipped[j].LastModified)
+	})
+
+	var segments []s3.Object
+	for _, o := range shipped {
+		if o.LastModified.Before(startedAt) {
+			continue
+		}
+		segments = append(segments, o)
+		if o.LastModified.After(pointInTime) {
+			break
+		}
+	}
+	return segments
+}
+
+// tableIDs returns the ID of every table, by keyspace and table name, of the files
+// of a snapshot
+func tableIDs(files []backupFile) map[string]string {
+	ids := map[string]string{}
+	for _, f := range files {
+		id := tableIDRegexp.FindString(f.Table)
+		if len(id) == 0 {
+			continue
+		}
+		id = id[1:]
+		ids[f.Keyspace+"."+tableName(f.Table)] = strings.Join([]string{id[:8], id[8:12], id[12:16], id[16:20], id[20:]}, "-")
+	}
+	return ids
+}
+
+// checkTableIDs fails when a table of the backup already exists in the cluster with
+// another ID. Its schema is not created again, and the commitlog segments would not
+// be replayed into it
+func checkTableIDs(api *v1alpha1.Cassandra, podName string, ids map[string]string) error {
+	out, err := runCqlsh(api, podName, "SELECT keyspace_name, table_name, id FROM system_schema.tables")
+	if err != nil {
+		return fmt.Errorf("could not get the table IDs: %v", err)
+	}
+	rows, err := cql.ParseRows(out)
+	if err != nil {
+		return fmt.Errorf("could not get the table IDs: %v", err)
+	}
+	tables := conflictingTables(ids, rows)
+	if len(tables) > 0 {
+		return fmt.Errorf("tables %v already exist with other IDs than the backed up ones, their commitlog could not be replayed", strings.Join(tables, ", "))
+	}
+	return nil
+}
+
+// conflictingTables returns the sorted names of the existing tables whose ID is not
+// the one of the backup
+func conflictingTables(ids map[string]string, rows []cql.Row) []string {
+	var tables []string
+	for _, row := range rows {
+		name := row["keyspace_name"] + "." + row["table_name"]
+		if id, ok := ids[name]; ok && id != row["id"] {
+			tables = append(tables, name)
+		}
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// restoreCommands returns the commands that load the staged tables and then remove
+// the staging directory
+func restoreCommands(api *v1alpha1.Cassandra, method v1alpha1.RestoreMethod, address string, tables [][2]string) [][]string {
+	var cmds [][]string
+	for _, t := range tables {
+		staged := strings.Join([]string{restoreDataPath, t[0], t[1]}, "/")
+		if method == v1alpha1.RestoreMethodRefresh {
+			// the table directory created with the schema is the most recent one
+			dir := fmt.Sprintf(`"$(ls -td %s/%s/%s-* | head -1)"`, cassandraDataDirectory, t[0], t[1])
+			cmds = append(cmds, []string{"mv", staged + "/*", dir + "/"})
+			cmds = append(cmds, nodetoolCommand(api, "refresh", t[0], t[1]))
+		} else {
+			cmds = append(cmds, sstableloaderCommand(api, "-d", address, staged))
+		}
+	}
+	return append(cmds, []string{"rm", "-rf", restoreDataPath})
+}
+
+// isRestoredFile returns if the file of a snapshot is loaded: files of the restored
+// keyspaces, or of any keyspace but the system ones, without the snapshot manifests
+// and schemas. Secondary indexes are built again when their table is loaded
+func isRestoredFile(f backupFile, keyspaces []string) bool {
+	if len(keyspaces) > 0 && !contains(keyspaces, f.Keyspace) {
+		return false
+	}
+	if isSystemKeyspace(f.Keyspace) || strings.Contains(f.Name, "/") {
+		return false
+	}
+	return f.Name != manifestObject && f.Name != schemaObject
+}
+
+// tableName returns the name of the table of a table directory
+func tableName(dir string) string {
+	return tableIDRegexp.ReplaceAllString(dir, "")
+}
+
+// restoreSchema returns the statements of the schema that create the elements of the
+// restored keyspaces, or of any keyspace but the system ones. The statements do not
+// fail when the element already exists. Tables are created with their ID when given
+func restoreSchema(schema string, keyspaces []string, ids map[string]string) string {
+	var statements []string
+	for _, s := range strings.Split(schema, ";\n") {
+		s = strings.TrimSpace(s)
+		m := schemaStatementRegexp.FindStringSubmatch(s)
+		if m == nil {
+			continue
+		}
+		name := m[2]
+		if len(m[3]) > 0 {
+			name = m[3]
+		}
+		keyspace := name
+		if m[1] != "KEYSPACE" {
+			keyspace = strings.SplitN(name, ".", 2)[0]
+		}
+		keyspace = strings.Trim(keyspace, `"`)
+		if isSystemKeyspace(keyspace) || (len(keyspaces) > 0 && !contains(keyspaces, keyspace)) {
+			continue
+		}
+		if id, ok := ids[unquoteName(name)]; ok && m[1] == "TABLE" && !strings.Contains(s, "ID = ") {
+			s = strings.Replace(s, "\n) WITH ", "\n) WITH ID = "+id+"\n    AND ", 1)
+		}
+		if !strings.HasPrefix(s[len("CREATE "+m[1]):], " IF NOT EXISTS") {
+			s = "CREATE " + m[1] + " IF NOT EXISTS" + s[len("CREATE "+m[1]):]
+		}
+		statements = append(statements, strings.TrimSuffix(s, ";")+";\n")
+	}
+	return strings.Join(statements, "\n")
+}
+
+// unquoteName returns the name of a schema element without the quotes of its parts
+func unquoteName(name string) string {
+	parts := strings.Split(name, ".")
+	for i := range parts {
+		parts[i] = strings.Trim(parts[i], `"`)
+	}
+	return strings.Join(parts, ".")
+}
+
+// failRestore records the failure of the restore
+func failRestore(rs *v1alpha1.CassandraRestore, err error) error {
+	rs.Status.Phase = v1alpha1.RestorePhaseFailed
+	rs.Status.Reason = err.Error()
+	rs.Status.CompletedAt = time.Now().Format(time.RFC3339)
+	recordEvent(rs, v1.EventTypeWarning, "RestoreFailed", "Restore failed: %v", err)
+
+	updateErr := sdk.Update(rs)
+	if updateErr != nil {
+		return updateErr
+	}
+	return err
+}
@@ -0,0 +1,192 @@
+package cassandra
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/cql"
+	"github.com/sirupsen/logrus"
+
+	"github.com/operator-framework/operator-sdk/pkg/sdk"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// RoleController manages reconciliation of a Cassandra role
+type RoleController interface {
+	ReconcileRole() error
+}
+
+// Role represents a role of a Cassandra cluster
+type Role struct {
+	Resource *v1alpha1.CassandraRole
+
+	RoleController
+}
+
+// NewCassandraRole creates a new Cassandra Role object
+func NewCassandraRole(r *v1alpha1.CassandraRole) *Role {
+	return &Role{Resource: r}
+}
+
+// ReconcileRole creates the role and brings its flags back to the spec. The password
+// of the role is changed when its secret changes. The role is dropped once the
+// object is marked for deletion
+func (ro Role) ReconcileRole() (err error) {
+	r := ro.Resource
+	r.SetDefaults()
+	status := r.Status.DeepCopy()
+
+	err = r.Validate()
+	if err != nil {
+		return updateRoleStatus(r, status, err)
+	}
+
+	cluster, podName, err := readyPodForCluster(r.Spec.Cluster, r.Namespace)
+	if apierrors.IsNotFound(err) && r.IsBeingDeleted() {
+		logrus.Infof("Cluster %v of role %v is gone, releasing it", r.Spec.Cluster, r.Name)
+		r.RemoveFinalizer()
+		return sdk.Update(r)
+	}
+	if err != nil {
+		return updateRoleStatus(r, status, fmt.Errorf("could not get cluster %v: %v", r.Spec.Cluster, err))
+	}
+	if cluster.Spec.Paused {
+		logrus.Infof("Reconciliation of %v paused, waiting to reconcile role %v", cluster.Name, r.Name)
+		return nil
+	}
+	if len(podName) == 0 {
+		logrus.Infof("Waiting for a ready pod of %v to reconcile role %v", cluster.Name, r.Name)
+		return nil
+	}
+
+	if r.IsBeingDeleted() {
+		if !r.HasFinalizer() {
+			return nil
+		}
+		err = runCqlshInput(cluster, podName, "DROP ROLE IF EXISTS "+cql.QuoteString(r.RoleName())+";\n")
+		if err != nil {
+			return err
+		}
+		recordEvent(r, v1.EventTypeNormal, "RoleDropped", "Dropped role %v", r.RoleName())
+		r.RemoveFinalizer()
+		return sdk.Update(r)
+	}
+	if !cluster.PasswordAuthentication() {
+		return updateRoleStatus(r, status, fmt.Errorf("cluster %v does not use the %v", cluster.Name, v1alpha1.PasswordAuthenticator))
+	}
+	if r.AddFinalizer() {
+		return sdk.Update(r)
+	}
+
+	err = syncRole(r, cluster, podName)
+	return updateRoleStatus(r, status, err)
+}
+
+// updateRoleStatus records the error as the reason and updates the role if its status
+// changed
+func updateRoleStatus(r *v1alpha1.CassandraRole, status *v1alpha1.CassandraRoleStatus, err error) error {
+	r.Status.Reason = ""
+	if err != nil {
+		r.Status.Reason = err.Error()
+	}
+	if !reflect.DeepEqual(status, &r.Status) {
+		updateErr := sdk.Update(r)
+		if updateErr != nil {
+			return updateErr
+		}
+	}
+	return err
+}
+
+// syncRole creates the role, or alters the options that differ from the spec. The
+// statements are given to cqlsh in its stdin, so the password is not part of the
+// command
+func syncRole(r *v1alpha1.CassandraRole, cluster *v1alpha1.Cassandra, podName string) error {
+	name := r.RoleName()
+	superuser, login := r.Spec.Superuser, *r.Spec.Login
+
+	var password, version string
+	if len(r.Spec.PasswordSecret) > 0 {
+		s := secret(r.Spec.PasswordSecret, r.Namespace)
+		err := sdk.Get(s)
+		if err != nil {
+			return fmt.Errorf("could not get password secret %v: %v", r.Spec.PasswordSecret, err)
+		}
+		password, version = string(s.Data[v1alpha1.PasswordKey]), s.ResourceVersion
+		if len(password) == 0 {
+			return fmt.Errorf("password secret %v has no %v", r.Spec.PasswordSecret, v1alpha1.PasswordKey)
+		}
+	}
+
+	found, liveSuperuser, liveLogin, err := describeRole(cluster, podName, name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		options := []string{fmt.Sprintf("SUPERUSER = %t", superuser), fmt.Sprintf("LOGIN = %t", login)}
+		if len(password) > 0 {
+			options = append(options, "PASSWORD = "+cql.QuoteString(password))
+		}
+		err = runCqlshInput(cluster, podName, roleStatement("CREATE ROLE IF NOT EXISTS", name, options))
+		if err != nil {
+			return err
+		}
+		recordEvent(r, v1.EventTypeNormal, "RoleCreated", "Created role %v", name)
+		r.Status.Superuser, r.Status.Login = superuser, login
+		r.Status.PasswordSecretVersion = version
+		if len(password) > 0 {
+			r.Status.PasswordChangedAt = time.Now().Format(time.RFC3339)
+		}
+		return nil
+	}
+
+	var options, changes []string
+	if liveSuperuser != superuser {
+		options = append(options, fmt.Sprintf("SUPERUSER = %t", superuser))
+		changes = append(changes, fmt.Sprintf("superuser to %t", superuser))
+	}
+	if liveLogin != login {
+		options = append(options, fmt.Sprintf("LOGIN = %t", login))
+		changes = append(changes, fmt.Sprintf("login to %t", login))
+	}
+	rotate := len(password) > 0 && version != r.Status.PasswordSecretVersion
+	if rotate {
+		options = append(options, "PASSWORD = "+cql.QuoteString(password))
+		changes = append(changes, "the password")
+	}
+	if len(options) > 0 {
+		err = runCqlshInput(cluster, podName, roleStatement("ALTER ROLE", name, options))
+		if err != nil {
+			return err
+		}
+		recordEvent(r, v1.EventTypeNormal, "RoleAltered", "Changed %v of role %v", strings.Join(changes, " and "), name)
+	}
+	r.Status.Superuser, r.Status.Login = superuser, login
+	if rotate {
+		r.Status.PasswordSecretVersion = version
+		r.Status.PasswordChangedAt = time.Now().Format(time.RFC3339)
+	}
+	return nil
+}
+
+// describeRole returns if the role exists, and its superuser and login flags
+func describeRole(api *v1alpha1.Cassandra, podName, name string) (bool, bool, bool, error) {
+	out, err := runCqlsh(api, podName, "SELECT role, is_superuser, can_login FROM system_auth.roles WHERE role = "+cql.QuoteString(name))
+	if err != nil {
+		return false, false, false, err
+	}
+	rows, err := cql.ParseRows(out)
+	if err != nil || len(rows) == 0 {
+		return false, false, false, err
+	}
+	return true, cql.ParseBool(rows[0]["is_superuser"]), cql.ParseBool(rows[0]["can_login"]), nil
+}
+
+// roleStatement returns the statement creating or altering the role with the options
+func roleStatement(verb, name string, options []string) string {
+	return fmt.Sprintf("%s %s WITH %s;\n", verb, cql.QuoteString(name), strings.Join(options, " AND "))
+}
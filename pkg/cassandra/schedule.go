@@ -0,0 +1,28 @@
+package cassandra
+
+import (
+	"time"
+
+	"github.com/camilocot/cassandra-operator/pkg/util/cron"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// dueSchedule returns the latest schedule time after the last schedule time, or
+// after the object was created, that is not after now. It is zero if there is none.
+// Missed schedule times are only returned once
+func dueSchedule(schedule *cron.Schedule, created metav1.Time, lastScheduleTime string, now time.Time) time.Time {
+	last := created.Time
+	if len(lastScheduleTime) > 0 {
+		parsed, err := time.Parse(time.RFC3339, lastScheduleTime)
+		if err == nil {
+			last = parsed
+		}
+	}
+
+	var due time.Time
+	for t := schedule.Next(last); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		due = t
+	}
+	return due
+}
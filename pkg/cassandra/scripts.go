@@ -0,0 +1,225 @@
+package cassandra
+
+const (
+	// cassandraDataPath is where the cassandra volume is mounted
+	cassandraDataPath = "/cassandra_data"
+	// cassandraDataDirectory is the data_file_directories of cassandra, with a
+	// directory per keyspace
+	cassandraDataDirectory = cassandraDataPath + "/data"
+
+	// drainedMarker is written by the preStop hook once the node has been drained
+	drainedMarker = cassandraDataPath + "/drained"
+	// runningMarker is written by the postStart hook, it is found on the next
+	// start if the node was stopped without being drained
+	runningMarker = cassandraDataPath + "/running"
+	// jmxPasswordPath is the JMX password file written by the run script, in the
+	// format of the password file of nodetool
+	jmxPasswordPath = cassandraDataPath + "/jmxremote.password"
+	// jmxAccessPath is the JMX access file written by the run script
+	jmxAccessPath = cassandraDataPath + "/jmxremote.access"
+	// lastShutdownMarker is written by the postStart hook with how the previous
+	// container was stopped: the time it was drained, "unclean" or "none"
+	lastShutdownMarker = cassandraDataPath + "/last-shutdown"
+
+	// hostsPath is where the hosts config map is mounted
+	hostsPath = "/etc/cassandra-hosts"
+	// replacingMarker holds the address of the node being replaced, it is
+	// removed by the operator once the replacement is up and normal
+	replacingMarker = cassandraDataPath + "/replacing"
+	// clientTLSPath is where the keystore secret of the client TLS is mounted
+	clientTLSPath = "/etc/cassandra-client-tls"
+	// internodeTLSPath is where the keystore secret of the internode TLS is mounted
+	internodeTLSPath = "/etc/cassandra-internode-tls"
+	// cqlshrcPath is the cqlshrc written for the client TLS, cqlsh reads it when run
+	// with --ssl
+	cqlshrcPath = cassandraDataPath + "/cqlshrc"
+	// externalAddressesPath is where the external addresses config map is mounted
+	externalAddressesPath = "/etc/cassandra-external-addresses"
+	// tokensPath is where the tokens config map of a restored cluster is mounted
+	tokensPath = "/etc/cassandra-tokens"
+	// restoreDataPath is where the files of a backup are staged before being loaded
+	restoreDataPath = cassandraDataPath + "/restore"
+
+	// commitlogArchivePath is where cassandra archives the full commitlog segments
+	// until the backup agent ships them
+	commitlogArchivePath = cassandraDataPath + "/commitlog_archive"
+	// commitlogRestorePath is where the archived commitlog segments replayed on the
+	// next start are staged
+	commitlogRestorePath = cassandraDataPath + "/commitlog_restore"
+	// restorePointInTimeMarker holds the time up to which the staged commitlog
+	// segments are replayed, it is removed by the operator once they are replayed
+	restorePointInTimeMarker = cassandraDataPath + "/restore-point-in-time"
+	// recoveryPointMarker is written by the backup agent with the start time of its
+	// last shipment of every pending file
+	recoveryPointMarker = cassandraDataPath + "/recovery-point"
+
+	// commitlogArchivingEnv enables the archiving of the commitlog when set to true
+	commitlogArchivingEnv = "CASSANDRA_COMMITLOG_ARCHIVING"
+
+	// authenticatorEnv is the authenticator of cassandra, when set
+	authenticatorEnv = "CASSANDRA_AUTHENTICATOR"
+	// authorizerEnv is the authorizer of cassandra, when set
+	authorizerEnv = "CASSANDRA_AUTHORIZER"
+	// usernameEnv is the role the cassandra-operator logs in with
+	usernameEnv = "CASSANDRA_OPERATOR_USERNAME"
+	// passwordEnv is the password of the role the cassandra-operator logs in with
+	passwordEnv = "CASSANDRA_OPERATOR_PASSWORD"
+
+	// startRPCEnv starts the Thrift server of the node when set to true, it is
+	// handled by the run script of the image
+	startRPCEnv = "CASSANDRA_START_RPC"
+
+	// externalAccessEnv is the type of the external access of the node, when set. With
+	// LoadBalancer the node waits for its external address before starting
+	externalAccessEnv = "CASSANDRA_EXTERNAL_ACCESS"
+	// broadcastRPCAddressEnv is the broadcast_rpc_address of the node, it is handled
+	// by the run script of the image
+	broadcastRPCAddressEnv = "CASSANDRA_BROADCAST_RPC_ADDRESS"
+
+	// jmxUsernameEnv is the JMX user nodetool is run with, when set
+	jmxUsernameEnv = "CASSANDRA_JMX_USERNAME"
+	// jmxPasswordEnv is the password of the JMX user
+	jmxPasswordEnv = "CASSANDRA_JMX_PASSWORD"
+	// jmxLocalOnlyEnv binds JMX to localhost when set to true
+	jmxLocalOnlyEnv = "CASSANDRA_JMX_LOCAL_ONLY"
+
+	// clientEncryptionEnv enables the client_encryption_options when set to true
+	clientEncryptionEnv = "CASSANDRA_CLIENT_ENCRYPTION"
+	// clientRequireAuthEnv is the require_client_auth of the client_encryption_options
+	clientRequireAuthEnv = "CASSANDRA_CLIENT_REQUIRE_AUTH"
+	// clientStorePasswordEnv is the password of the keystore and truststore of the
+	// client TLS
+	clientStorePasswordEnv = "CASSANDRA_CLIENT_STORE_PASSWORD"
+
+	// internodeEncryptionEnv enables the server_encryption_options when set to true
+	internodeEncryptionEnv = "CASSANDRA_INTERNODE_ENCRYPTION"
+	// internodeStorePasswordEnv is the password of the keystores and truststore of the
+	// internode TLS
+	internodeStorePasswordEnv = "CASSANDRA_INTERNODE_STORE_PASSWORD"
+
+	// lastShutdownUnclean is the last shutdown of a node that was not drained
+	lastShutdownUnclean = "unclean"
+	// lastShutdownNone is the last shutdown of a node started for the first time
+	lastShutdownNone = "none"
+
+	// nodetoolLogin are the arguments of nodetool logging in as the JMX user, when
+	// set, expanded by a shell
+	nodetoolLogin = `${` + jmxUsernameEnv + `:+-u "$` + jmxUsernameEnv + `" -pwf ` + jmxPasswordPath + `}`
+
+	// drainScript drains the node before its container is stopped, flushing the
+	// memtables so no commitlog has to be replayed on the next start
+	drainScript = `set -e
+rm -f ` + drainedMarker + `
+nodetool ` + nodetoolLogin + ` drain
+date -u +%Y-%m-%dT%H:%M:%SZ > ` + drainedMarker + `
+`
+
+	// readyScript is the readiness probe with JMX authentication, the node is ready
+	// once it is up and normal in the ring
+	readyScript = `nodetool ` + nodetoolLogin + ` status | grep -E "^UN +$POD_IP "`
+
+	// runScript starts cassandra. When the volume is empty but the pod had a node in
+	// the ring, the volume has been lost and the previous node is replaced. A new node
+	// of a restored cluster starts with the tokens of the backed up node. The
+	// authenticator and authorizer are set when given, and the client_encryption_options
+	// and server_encryption_options replaced with the keystores of the client and
+	// internode TLS, the keystore of the node being named after its pod. JMX
+	// authenticates the JMX user with the password and access files written from its
+	// credentials, and is bound to localhost when local only. Commitlog archiving is
+	// configured for the continuous backup, and staged commitlog segments are
+	// replayed up to the restored point in time. Behind a load balancer, the node
+	// waits for its external address to broadcast it to the clients
+	runScript = `CONF_DIR=${CASSANDRA_CONF_DIR:-/etc/cassandra}
+if [ -n "$` + authenticatorEnv + `" ]; then
+  sed -ri "s/^(# )?authenticator:.*/authenticator: $` + authenticatorEnv + `/" $CONF_DIR/cassandra.yaml
+fi
+if [ -n "$` + authorizerEnv + `" ]; then
+  sed -ri "s/^(# )?authorizer:.*/authorizer: $` + authorizerEnv + `/" $CONF_DIR/cassandra.yaml
+fi
+replace_options() {
+  sed -i "/^$1:/,/^[^ #]/{/^$1:/d;/^[ #]/d}" $CONF_DIR/cassandra.yaml
+  cat >> $CONF_DIR/cassandra.yaml
+}
+rm -f ` + cqlshrcPath + `
+if [ "$` + clientEncryptionEnv + `" = "true" ]; then
+  replace_options client_encryption_options <<EOF
+client_encryption_options:
+  enabled: true
+  optional: false
+  keystore: ` + clientTLSPath + `/keystore.jks
+  keystore_password: $` + clientStorePasswordEnv + `
+  require_client_auth: $` + clientRequireAuthEnv + `
+  truststore: ` + clientTLSPath + `/truststore.jks
+  truststore_password: $` + clientStorePasswordEnv + `
+EOF
+  cat > ` + cqlshrcPath + ` <<EOF
+[ssl]
+validate = true
+certfile = ` + clientTLSPath + `/` + caCertKey + `
+usercert = ` + clientTLSPath + `/tls.crt
+userkey = ` + clientTLSPath + `/tls.key
+EOF
+fi
+if [ "$` + internodeEncryptionEnv + `" = "true" ]; then
+  replace_options server_encryption_options <<EOF
+server_encryption_options:
+  internode_encryption: all
+  keystore: ` + internodeTLSPath + `/$HOSTNAME.keystore.jks
+  keystore_password: $` + internodeStorePasswordEnv + `
+  truststore: ` + internodeTLSPath + `/truststore.jks
+  truststore_password: $` + internodeStorePasswordEnv + `
+  require_client_auth: true
+EOF
+fi
+if [ -n "$` + jmxUsernameEnv + `" ]; then
+  rm -f ` + jmxPasswordPath + ` ` + jmxAccessPath + `
+  echo "$` + jmxUsernameEnv + ` $` + jmxPasswordEnv + `" > ` + jmxPasswordPath + `
+  echo "$` + jmxUsernameEnv + ` readwrite" > ` + jmxAccessPath + `
+  chown --reference=$CONF_DIR/cassandra.yaml ` + jmxPasswordPath + ` ` + jmxAccessPath + `
+  chmod 400 ` + jmxPasswordPath + ` ` + jmxAccessPath + `
+  export JVM_EXTRA_OPTS="$JVM_EXTRA_OPTS -Dcom.sun.management.jmxremote.authenticate=true -Dcom.sun.management.jmxremote.password.file=` + jmxPasswordPath + ` -Dcom.sun.management.jmxremote.access.file=` + jmxAccessPath + `"
+  if [ "$` + jmxLocalOnlyEnv + `" = "true" ]; then
+    export LOCAL_JMX=yes
+  else
+    export LOCAL_JMX=no
+  fi
+fi
+: > $CONF_DIR/commitlog_archiving.properties
+if [ "$` + commitlogArchivingEnv + `" = "true" ]; then
+  mkdir -p ` + commitlogArchivePath + `
+  echo "archive_command=/bin/ln %path ` + commitlogArchivePath + `/%name" >> $CONF_DIR/commitlog_archiving.properties
+fi
+if [ -s ` + restorePointInTimeMarker + ` ]; then
+  echo "restore_command=/bin/cp -f %from %to" >> $CONF_DIR/commitlog_archiving.properties
+  echo "restore_directories=` + commitlogRestorePath + `" >> $CONF_DIR/commitlog_archiving.properties
+  echo "restore_point_in_time=$(cat ` + restorePointInTimeMarker + `)" >> $CONF_DIR/commitlog_archiving.properties
+fi
+if [ ! -d ` + cassandraDataDirectory + ` ] && [ -s ` + hostsPath + `/$HOSTNAME ]; then
+  cp ` + hostsPath + `/$HOSTNAME ` + replacingMarker + `
+elif [ ! -d ` + cassandraDataDirectory + ` ] && [ -s ` + tokensPath + `/$HOSTNAME ]; then
+  export JVM_EXTRA_OPTS="$JVM_EXTRA_OPTS -Dcassandra.initial_token=$(cat ` + tokensPath + `/$HOSTNAME)"
+fi
+if [ -f ` + replacingMarker + ` ]; then
+  export JVM_EXTRA_OPTS="$JVM_EXTRA_OPTS -Dcassandra.replace_address_first_boot=$(cat ` + replacingMarker + `)"
+fi
+if [ "$` + externalAccessEnv + `" = "LoadBalancer" ]; then
+  until [ -s ` + externalAddressesPath + `/$HOSTNAME ]; do
+    echo "Waiting for the external address of $HOSTNAME"
+    sleep 5
+  done
+  export ` + broadcastRPCAddressEnv + `=$(cat ` + externalAddressesPath + `/$HOSTNAME)
+fi
+exec /run.sh
+`
+
+	// startScript records how the previous container was stopped
+	startScript = `if [ -f ` + drainedMarker + ` ]; then
+  mv ` + drainedMarker + ` ` + lastShutdownMarker + `
+elif [ -f ` + runningMarker + ` ]; then
+  echo ` + lastShutdownUnclean + ` > ` + lastShutdownMarker + `
+else
+  echo ` + lastShutdownNone + ` > ` + lastShutdownMarker + `
+fi
+touch ` + runningMarker + `
+`
+)
@@ -0,0 +1,111 @@
+package cassandra
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/exec"
+	"github.com/camilocot/cassandra-operator/pkg/util/s3"
+
+	"github.com/operator-framework/operator-sdk/pkg/sdk"
+)
+
+// uploadPartSize is the size of the parts of the files uploaded in multiple parts,
+// in MiB. Smaller files are uploaded in a single request
+const uploadPartSize = 256
+
+// storageClient returns a client of the object storage with the credentials of its secret
+func storageClient(namespace string, storage v1alpha1.BackupStorage) (*s3.Client, error) {
+	s := secret(storage.CredentialsSecret, namespace)
+	err := sdk.Get(s)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the storage credentials: %v", err)
+	}
+
+	accessKey := string(s.Data[v1alpha1.AccessKeyIDKey])
+	secretKey := string(s.Data[v1alpha1.SecretAccessKeyKey])
+	if len(accessKey) == 0 || len(secretKey) == 0 {
+		return nil, fmt.Errorf("secret %v must have the %v and %v keys", s.Name, v1alpha1.AccessKeyIDKey, v1alpha1.SecretAccessKeyKey)
+	}
+	return s3.New(storage.Endpoint, storage.Region, accessKey, secretKey)
+}
+
+// uploadFile streams a file of the cassandra container of the pod to the object
+// storage. Files larger than a part are uploaded in multiple parts
+func uploadFile(api *v1alpha1.Cassandra, client *s3.Client, bucket, podName, path, key string, size int64) error {
+	partSize := int64(uploadPartSize) << 20
+	if size <= partSize {
+		body := streamFile(api, podName, "cat", path)
+		defer body.Close()
+		return client.PutObject(bucket, key, body, size)
+	}
+
+	uploadID, err := client.CreateMultipartUpload(bucket, key)
+	if err != nil {
+		return err
+	}
+	var etags []string
+	for part := int64(0); part*partSize < size; part++ {
+		length := size - part*partSize
+		if length > partSize {
+			length = partSize
+		}
+		dd := fmt.Sprintf("dd if=%s bs=1M skip=%d count=%d 2>/dev/null", path, part*uploadPartSize, uploadPartSize)
+		body := streamFile(api, podName, "sh", "-c", dd)
+		var etag string
+		etag, err = client.UploadPart(bucket, key, uploadID, int(part)+1, body, length)
+		body.Close()
+		if err != nil {
+			// the parts already uploaded are freed, the error is the one of the part
+			_ = client.AbortMultipartUpload(bucket, key, uploadID)
+			return err
+		}
+		etags = append(etags, etag)
+	}
+	return client.CompleteMultipartUpload(bucket, key, uploadID, etags)
+}
+
+// streamFile returns a reader of the output of the command run in the cassandra
+// container of the pod. A failure of the command is returned by the reader, and
+// closing the reader stops the command
+func streamFile(api *v1alpha1.Cassandra, podName string, cmd ...string) io.ReadCloser {
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(exec.StreamCommand(podName, cassandraContainerName, api.Namespace, nil, w, cmd...)) // #nosec
+	}()
+	return r
+}
+
+// downloadFile streams an object of the object storage to a file of the cassandra
+// container of the pod, creating its directory
+func downloadFile(api *v1alpha1.Cassandra, client *s3.Client, bucket, key, podName, path string) error {
+	body, err := client.GetObject(bucket, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	cat := fmt.Sprintf("mkdir -p $(dirname '%s') && cat > '%s'", path, path)
+	return exec.StreamCommand(podName, cassandraContainerName, api.Namespace, body, nil, "sh", "-c", cat) // #nosec
+}
+
+// getJSON decodes the object of the object storage encoded as JSON into the value
+func getJSON(client *s3.Client, bucket, key string, v interface{}) error {
+	body, err := client.GetObject(bucket, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return json.NewDecoder(body).Decode(v)
+}
+
+// putJSON stores the value encoded as JSON in the object storage
+func putJSON(client *s3.Client, bucket, key string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return client.PutObject(bucket, key, bytes.NewReader(data), int64(len(data)))
+}
@@ -0,0 +1,669 @@
+package cassandra
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"time"
+
+	v1alpha1 "github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
+	"github.com/camilocot/cassandra-operator/pkg/util/jks"
+	"github.com/camilocot/cassandra-operator/pkg/util/pki"
+	"github.com/sirupsen/logrus"
+
+	"github.com/operator-framework/operator-sdk/pkg/sdk"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// caCertKey is the key of the CA certificates in a kubernetes.io/tls secret
+	caCertKey = "ca.crt"
+	// keystoreKey is the key of the keystore in a keystore secret
+	keystoreKey = "keystore.jks"
+	// truststoreKey is the key of the truststore in a keystore secret
+	truststoreKey = "truststore.jks"
+	// storePasswordKey is the key of the password of the keystore and truststore in a
+	// keystore secret
+	storePasswordKey = "storePassword"
+	// keystoreAlias is the alias of the key of the node in its keystore
+	keystoreAlias = "cassandra"
+
+	// ChecksumAnnotation is the checksum of the certificates a keystore secret was
+	// converted from
+	ChecksumAnnotation = "database.camilocot/checksum"
+
+	// caValidity is the validity of the generated internode CA
+	caValidity = 10 * 365 * 24 * time.Hour
+
+	// certificateAPIVersion is the API version of the cert-manager certificates
+	certificateAPIVersion = "certmanager.k8s.io/v1alpha1"
+)
+
+// ReconcileTLS converts the certificates of the client TLS secret and of the nodes to
+// the keystores and truststores of the nodes, issuing and renewing the certificates
+// of the nodes with the internal CA. When a certificate changes the keystore secret
+// is updated and a rolling restart is requested, so every node is drained before it
+// loads the new certificate
+func (c Cluster) ReconcileTLS() (err error) {
+	r := c.Resource
+	status := r.Status.DeepCopy()
+	if !r.ClientTLS() && !r.InternodeTLS() {
+		r.Status.TLS = nil
+	} else {
+		if r.Status.TLS == nil {
+			r.Status.TLS = &v1alpha1.TLSStatus{}
+		}
+		err = reconcileTLS(r)
+	}
+
+	if !reflect.DeepEqual(status, &r.Status) {
+		updateErr := sdk.Update(r)
+		if updateErr != nil {
+			return updateErr
+		}
+	}
+	return err
+}
+
+// reconcileTLS reconciles the client and internode TLS enabled in the spec
+func reconcileTLS(r *v1alpha1.Cassandra) error {
+	err := r.Spec.TLS.Validate()
+	if err != nil {
+		return err
+	}
+	if !r.ClientTLS() {
+		r.Status.TLS.Client = nil
+	} else {
+		err = reconcileClientTLS(r)
+		if err != nil {
+			return err
+		}
+	}
+	if !r.InternodeTLS() {
+		r.Status.TLS.CA, r.Status.TLS.Internode = nil, nil
+		r.Status.ClearCAExpiringCondition()
+		return nil
+	}
+	return reconcileInternodeTLS(r)
+}
+
+// reconcileClientTLS converts the client TLS secret when its checksum changes
+func reconcileClientTLS(r *v1alpha1.Cassandra) error {
+	spec := r.Spec.TLS.Client
+	if spec.Issuer != nil {
+		err := reconcileCertificate(r, spec.Secret, spec.Issuer)
+		if err != nil {
+			return err
+		}
+	}
+
+	source := secret(spec.Secret, r.Namespace)
+	err := sdk.Get(source)
+	if apierrors.IsNotFound(err) && spec.Issuer != nil {
+		logrus.Infof("Waiting for the certificate of %v to be issued in %v", r.Name, spec.Secret)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not get client TLS secret %v: %v", spec.Secret, err)
+	}
+	if len(source.Data[caCertKey]) == 0 {
+		return fmt.Errorf("client TLS secret %v requires the %v key, to validate the nodes and authenticate the clients", spec.Secret, caCertKey)
+	}
+
+	checksum := secretChecksum(source)
+	err = reconcileKeystore(r, clientKeystoreSecretName(r), checksum, func(password string, now time.Time) (map[string][]byte, error) {
+		return keystoreData(source, password, now)
+	})
+	if err != nil {
+		return fmt.Errorf("could not convert client TLS secret %v: %v", spec.Secret, err)
+	}
+
+	previous := r.Status.TLS.Client
+	r.Status.TLS.Client, err = certificateStatus(source, checksum)
+	if err != nil {
+		return err
+	}
+	setCertificateMetrics(r)
+	if previous != nil && len(previous.Checksum) > 0 && previous.Checksum != checksum {
+		certificateRotated(r, source.Name)
+	}
+	return nil
+}
+
+// reconcileInternodeTLS issues the missing certificates of the nodes and renews the
+// ones expiring, then converts them to the internode keystore secret with a keystore
+// per node, the pods picking theirs by hostname. When the CA is replaced, the nodes
+// trust both CAs before their certificates are issued by the new one
+func reconcileInternodeTLS(r *v1alpha1.Cassandra) error {
+	ca, caSecret, err := reconcileCA(r)
+	if err != nil {
+		return err
+	}
+	err = startCARotation(r, ca, caSecret)
+	if err != nil {
+		return err
+	}
+	previousCA, keep := internodeTrust(r)
+
+	now := time.Now()
+	checkCAExpiry(r, ca, now)
+	var nodes []string
+	var sources []*v1.Secret
+	for ordinal := int32(0); ordinal < r.Spec.Size; ordinal++ {
+		podName := podNameForCassandra(r, ordinal)
+		s, err := reconcileNodeCertificate(r, ca, podName, keep, now)
+		if err != nil {
+			return fmt.Errorf("could not issue the internode certificate of %v: %v", podName, err)
+		}
+		nodes = append(nodes, podName)
+		sources = append(sources, s)
+	}
+	err = deleteNodeCertificates(r, r.Spec.Size)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	for _, s := range append([]*v1.Secret{caSecret}, sources...) {
+		fmt.Fprintf(h, "%s=%s:", s.Name, secretChecksum(s))
+	}
+	h.Write(previousCA)
+	checksum := hex.EncodeToString(h.Sum(nil))
+	trusted := append(ca.CertificatePEM(), previousCA...)
+	err = reconcileKeystore(r, internodeKeystoreSecretName(r), checksum, func(password string, now time.Time) (map[string][]byte, error) {
+		return internodeKeystoreData(nodes, sources, trusted, password, now)
+	})
+	if err != nil {
+		return fmt.Errorf("could not convert the internode certificates: %v", err)
+	}
+
+	r.Status.TLS.CA, err = certificateStatus(caSecret, secretChecksum(caSecret))
+	if err != nil {
+		return err
+	}
+	previous := map[string]string{}
+	for _, c := range r.Status.TLS.Internode {
+		previous[c.Secret] = c.Checksum
+	}
+	rotated := false
+	r.Status.TLS.Internode = nil
+	for _, s := range sources {
+		status, err := certificateStatus(s, secretChecksum(s))
+		if err != nil {
+			return err
+		}
+		rotated = rotated || (len(previous[s.Name]) > 0 && previous[s.Name] != status.Checksum)
+		r.Status.TLS.Internode = append(r.Status.TLS.Internode, *status)
+	}
+	setCertificateMetrics(r)
+	if step := advanceCARotation(r, rotated, now); len(step) > 0 {
+		recordEvent(r, v1.EventTypeNormal, "CARotation", "Rolling restart requested at %v %v", r.Status.RestartRequestedAt, step)
+	} else if rotated {
+		certificateRotated(r, "the nodes")
+	}
+	return nil
+}
+
+// startCARotation starts the rotation of the CA when the CA secret changed and the
+// certificates of the nodes were issued by another CA, which they keep trusting
+func startCARotation(r *v1alpha1.Cassandra, ca *pki.CA, caSecret *v1.Secret) error {
+	st := r.Status.TLS
+	if st.CARotation != nil || st.CA == nil || st.CA.Checksum == secretChecksum(caSecret) {
+		return nil
+	}
+	for ordinal := int32(0); ordinal < r.Spec.Size; ordinal++ {
+		s := secret(nodeCertificateSecretName(podNameForCassandra(r, ordinal)), r.Namespace)
+		err := sdk.Get(s)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		previous := s.Data[caCertKey]
+		if len(previous) > 0 && !bytes.Equal(previous, ca.CertificatePEM()) {
+			st.CARotation = &v1alpha1.CARotationStatus{PreviousCA: string(previous)}
+			recordEvent(r, v1.EventTypeNormal, "CARotationStarted", "The internode CA of %v changed, the nodes trust the previous CA until they have certificates of the new one", r.Spec.TLS.Internode.CASecret)
+			return nil
+		}
+	}
+	return nil
+}
+
+// internodeTrust returns the previous CA trusted by the nodes during the rotation of
+// the CA, and if the certificates of the nodes are kept, not issued by the new CA,
+// until the nodes restarted trusting both CAs
+func internodeTrust(r *v1alpha1.Cassandra) ([]byte, bool) {
+	rotation := r.Status.TLS.CARotation
+	if rotation == nil {
+		return nil, false
+	}
+	return []byte(rotation.PreviousCA), len(rotation.IssuedAt) == 0 && !restartCompletedSince(r, rotation.TrustedAt)
+}
+
+// advanceCARotation requests the rolling restart loading the truststore with both
+// CAs, then once it completed and the certificates of the nodes were issued by the new
+// CA, the one loading them. The previous CA is dropped once the second restart
+// completed. It returns the step of the restart requested, if any
+func advanceCARotation(r *v1alpha1.Cassandra, rotated bool, now time.Time) string {
+	rotation := r.Status.TLS.CARotation
+	switch {
+	case rotation == nil:
+		return ""
+	case len(rotation.TrustedAt) == 0:
+		rotation.TrustedAt = requestRestart(r, now)
+		return "to trust the previous and the new internode CA"
+	case len(rotation.IssuedAt) == 0:
+		if !restartCompletedSince(r, rotation.TrustedAt) {
+			return ""
+		}
+		if !rotated {
+			// no node certificate to issue again
+			rotation.IssuedAt = rotation.TrustedAt
+			return ""
+		}
+		rotation.IssuedAt = requestRestart(r, now)
+		return "to load the certificates issued by the new internode CA"
+	case restartCompletedSince(r, rotation.IssuedAt):
+		r.Status.TLS.CARotation = nil
+	}
+	return ""
+}
+
+// restartCompletedSince returns if a rolling restart requested at or after the given
+// time completed
+func restartCompletedSince(r *v1alpha1.Cassandra, requestedAt string) bool {
+	rs := r.Status.Restart
+	if len(requestedAt) == 0 || rs == nil || !rs.Completed {
+		return false
+	}
+	if rs.RequestedAt == requestedAt {
+		return true
+	}
+	completed, err := time.Parse(time.RFC3339, rs.RequestedAt)
+	if err != nil {
+		return false
+	}
+	since, err := time.Parse(time.RFC3339, requestedAt)
+	return err == nil && completed.After(since)
+}
+
+// checkCAExpiry warns once the CA expires within renewBeforeDays. Nothing rotates the
+// CA, the certificates of the nodes are no longer renewed as they would expire with it
+func checkCAExpiry(r *v1alpha1.Cassandra, ca *pki.CA, now time.Time) {
+	spec := r.Spec.TLS.Internode
+	if !ca.Expiring(days(spec.RenewBeforeDays), now) {
+		r.Status.ClearCAExpiringCondition()
+		return
+	}
+	notAfter := ca.Certificate.NotAfter.UTC().Format(time.RFC3339)
+	if !r.Status.HasCondition(v1alpha1.ClusterConditionCAExpiring) {
+		recordEvent(r, v1.EventTypeWarning, "CAExpiring", "Internode CA of %v expires at %v, replace it to renew the certificates of the nodes", spec.CASecret, notAfter)
+	}
+	r.Status.SetCAExpiringCondition(spec.CASecret, notAfter)
+}
+
+// reconcileCA returns the CA of the internode certificates, generating it in the CA
+// secret when it does not exist
+func reconcileCA(r *v1alpha1.Cassandra) (*pki.CA, *v1.Secret, error) {
+	name := r.Spec.TLS.Internode.CASecret
+	s := secret(name, r.Namespace)
+	err := sdk.Get(s)
+	if err == nil {
+		ca, err := pki.LoadCA(s.Data[v1.TLSCertKey], s.Data[v1.TLSPrivateKeyKey])
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not load the CA of %v: %v", name, err)
+		}
+		return ca, s, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, nil, err
+	}
+
+	ca, err := pki.NewCA(r.Name+"-ca", caValidity, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := ca.KeyPEM()
+	if err != nil {
+		return nil, nil, err
+	}
+	s.Labels = labelsForCassandra(r.Name)
+	s.Type = v1.SecretTypeTLS
+	s.Data = map[string][]byte{
+		v1.TLSCertKey:       ca.CertificatePEM(),
+		v1.TLSPrivateKeyKey: keyPEM,
+	}
+	addOwnerRefToObject(s, asOwner(r))
+	err = sdk.Create(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	recordEvent(r, v1.EventTypeNormal, "CAGenerated", "Generated the internode CA in %v, valid until %v", name, ca.Certificate.NotAfter.UTC().Format(time.RFC3339))
+	return ca, s, nil
+}
+
+// reconcileNodeCertificate returns the secret with the internode certificate of the
+// pod, issuing it when it is missing, is not issued by the CA, has other DNS names or
+// expires within renewBeforeDays
+func reconcileNodeCertificate(r *v1alpha1.Cassandra, ca *pki.CA, podName string, keep bool, now time.Time) (*v1.Secret, error) {
+	spec := r.Spec.TLS.Internode
+	s := secret(nodeCertificateSecretName(podName), r.Namespace)
+	err := sdk.Get(s)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	exists := err == nil
+	dnsNames := internodeDNSNames(r, podName)
+	reason := "no certificate"
+	if exists && keep {
+		return s, nil
+	}
+	if exists {
+		reason = ca.Renewal(s.Data[v1.TLSCertKey], dnsNames, days(spec.RenewBeforeDays), now)
+		if len(reason) == 0 {
+			return s, nil
+		}
+	}
+
+	certPEM, keyPEM, err := ca.Issue(dnsNames[0], dnsNames, days(spec.ValidityDays), now)
+	if err != nil {
+		return nil, err
+	}
+	s.Labels = labelsForCassandra(r.Name)
+	s.Type = v1.SecretTypeTLS
+	s.Data = map[string][]byte{
+		v1.TLSCertKey:       certPEM,
+		v1.TLSPrivateKeyKey: keyPEM,
+		caCertKey:           ca.CertificatePEM(),
+	}
+	if exists {
+		err = sdk.Update(s)
+	} else {
+		addOwnerRefToObject(s, asOwner(r))
+		err = sdk.Create(s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	recordEvent(r, v1.EventTypeNormal, "CertificateIssued", "Issued the internode certificate of %v: %v", podName, reason)
+	return s, nil
+}
+
+// deleteNodeCertificates deletes the internode certificates of the pods from the
+// given ordinal, removed by a scale down
+func deleteNodeCertificates(r *v1alpha1.Cassandra, from int32) error {
+	for ordinal := from; ; ordinal++ {
+		err := sdk.Delete(secret(nodeCertificateSecretName(podNameForCassandra(r, ordinal)), r.Namespace))
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// certificateRotated requests the rolling restart loading the rotated certificates
+func certificateRotated(r *v1alpha1.Cassandra, of string) {
+	requestRestart(r, time.Now())
+	recordEvent(r, v1.EventTypeNormal, "CertificateRotated", "Certificate of %v changed, rolling restart requested at %v", of, r.Status.RestartRequestedAt)
+}
+
+// requestRestart requests a rolling restart of the cluster in the status, and returns
+// the time it was requested at
+func requestRestart(r *v1alpha1.Cassandra, now time.Time) string {
+	r.Status.RestartRequestedAt = now.UTC().Format(time.RFC3339)
+	return r.Status.RestartRequestedAt
+}
+
+// reconcileKeystore converts the certificates to the keystore secret, owned by the
+// cluster, when the checksum of the certificates changes. The password of an existing
+// keystore secret is kept
+func reconcileKeystore(r *v1alpha1.Cassandra, name, checksum string, convert func(password string, now time.Time) (map[string][]byte, error)) error {
+	keystore := secret(name, r.Namespace)
+	err := sdk.Get(keystore)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+	if exists && keystore.Annotations[ChecksumAnnotation] == checksum {
+		return nil
+	}
+
+	password := string(keystore.Data[storePasswordKey])
+	if len(password) == 0 {
+		password, err = randomPassword()
+		if err != nil {
+			return err
+		}
+	}
+	keystore.Data, err = convert(password, time.Now())
+	if err != nil {
+		return err
+	}
+	keystore.Labels = labelsForCassandra(r.Name)
+	keystore.Annotations = map[string]string{ChecksumAnnotation: checksum}
+	keystore.Type = v1.SecretTypeOpaque
+	if exists {
+		err = sdk.Update(keystore)
+	} else {
+		addOwnerRefToObject(keystore, asOwner(r))
+		err = sdk.Create(keystore)
+	}
+	if err != nil {
+		return err
+	}
+	recordEvent(r, v1.EventTypeNormal, "KeystoreConverted", "Converted the certificates to %v", keystore.Name)
+	return nil
+}
+
+// keystoreData returns the data of the keystore secret of a kubernetes.io/tls secret:
+// the keystore with the key and certificate chain, the truststore with the CA
+// certificates, their password and a copy of the PEM files for cqlsh
+func keystoreData(source *v1.Secret, password string, now time.Time) (map[string][]byte, error) {
+	keystore, err := encodeKeystore(source, password, now)
+	if err != nil {
+		return nil, err
+	}
+	truststore, err := encodeTruststore(source.Data[caCertKey], password, now)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		keystoreKey:         keystore,
+		truststoreKey:       truststore,
+		storePasswordKey:    []byte(password),
+		v1.TLSCertKey:       source.Data[v1.TLSCertKey],
+		v1.TLSPrivateKeyKey: source.Data[v1.TLSPrivateKeyKey],
+		caCertKey:           source.Data[caCertKey],
+	}, nil
+}
+
+// encodeKeystore returns the keystore with the key and certificate chain of the
+// kubernetes.io/tls secret
+func encodeKeystore(source *v1.Secret, password string, now time.Time) ([]byte, error) {
+	chain, err := pki.ParseCertificates(source.Data[v1.TLSCertKey])
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no certificate in %v", v1.TLSCertKey)
+	}
+	key, err := pki.ParsePrivateKey(source.Data[v1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %v: %v", v1.TLSPrivateKeyKey, err)
+	}
+	if !pki.KeyMatches(key, chain[0]) {
+		return nil, fmt.Errorf("private key does not match the certificate %v", chain[0].Subject)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &jks.KeyStore{PrivateKeys: []jks.PrivateKeyEntry{{Alias: keystoreAlias, PKCS8Key: pkcs8}}}
+	for _, c := range chain {
+		ks.PrivateKeys[0].Chain = append(ks.PrivateKeys[0].Chain, c.Raw)
+	}
+	return ks.Encode(password, now)
+}
+
+// encodeTruststore returns the truststore with the PEM CA certificates
+func encodeTruststore(caPEM []byte, password string, now time.Time) ([]byte, error) {
+	cas, err := pki.ParseCertificates(caPEM)
+	if err != nil {
+		return nil, err
+	}
+	ts := &jks.KeyStore{}
+	for i, c := range cas {
+		ts.TrustedCertificates = append(ts.TrustedCertificates, jks.TrustedCertificateEntry{Alias: fmt.Sprintf("ca-%d", i), Certificate: c.Raw})
+	}
+	return ts.Encode(password, now)
+}
+
+// internodeKeystoreData returns the data of the internode keystore secret: the
+// keystore of every node, named after its pod, the truststore with the CA and their
+// password
+func internodeKeystoreData(nodes []string, sources []*v1.Secret, caPEM []byte, password string, now time.Time) (map[string][]byte, error) {
+	truststore, err := encodeTruststore(caPEM, password, now)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string][]byte{
+		truststoreKey:    truststore,
+		storePasswordKey: []byte(password),
+	}
+	for i, node := range nodes {
+		data[node+"."+keystoreKey], err = encodeKeystore(sources[i], password, now)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", sources[i].Name, err)
+		}
+	}
+	return data, nil
+}
+
+// secretChecksum returns the checksum of the certificates of a kubernetes.io/tls secret
+func secretChecksum(s *v1.Secret) string {
+	h := sha256.New()
+	for _, k := range []string{caCertKey, v1.TLSCertKey, v1.TLSPrivateKeyKey} {
+		fmt.Fprintf(h, "%s=%d:", k, len(s.Data[k]))
+		h.Write(s.Data[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// certificateStatus returns the status of the certificate of the secret
+func certificateStatus(s *v1.Secret, checksum string) (*v1alpha1.CertificateStatus, error) {
+	chain, err := pki.ParseCertificates(s.Data[v1.TLSCertKey])
+	if err != nil || len(chain) == 0 {
+		return nil, fmt.Errorf("could not parse the certificate of %v: %v", s.Name, err)
+	}
+	return &v1alpha1.CertificateStatus{
+		Secret:   s.Name,
+		Subject:  chain[0].Subject.String(),
+		NotAfter: chain[0].NotAfter.UTC().Format(time.RFC3339),
+		Checksum: checksum,
+	}, nil
+}
+
+// reconcileCertificate requests the certificate of the secret from the cert-manager
+// issuer, for the names the clients connect to
+func reconcileCertificate(r *v1alpha1.Cassandra, secretName string, issuer *v1alpha1.IssuerReference) error {
+	dnsNames := []interface{}{}
+	for _, n := range clientDNSNames(r) {
+		dnsNames = append(dnsNames, n)
+	}
+	spec := map[string]interface{}{
+		"secretName": secretName,
+		"commonName": dnsNames[0],
+		"dnsNames":   dnsNames,
+		"issuerRef": map[string]interface{}{
+			"name": issuer.Name,
+			"kind": issuer.Kind,
+		},
+	}
+
+	cert := certificate(secretName, r.Namespace)
+	err := sdk.Get(cert)
+	if apierrors.IsNotFound(err) {
+		cert = certificate(secretName, r.Namespace)
+		cert.SetLabels(labelsForCassandra(r.Name))
+		cert.SetOwnerReferences([]metav1.OwnerReference{asOwner(r)})
+		cert.Object["spec"] = spec
+		return sdk.Create(cert)
+	}
+	if err != nil {
+		return fmt.Errorf("could not get certificate %v: %v", secretName, err)
+	}
+	if !reflect.DeepEqual(cert.Object["spec"], spec) {
+		cert.Object["spec"] = spec
+		return sdk.Update(cert)
+	}
+	return nil
+}
+
+// clientDNSNames returns the names the clients connect to, the first one is the
+// name of the service of the clients
+func clientDNSNames(api *v1alpha1.Cassandra) []string {
+	var names []string
+	for _, service := range []string{api.Name, api.Name + "-unready"} {
+		names = append(names,
+			service+"."+api.Namespace+".svc.cluster.local",
+			service,
+			service+"."+api.Namespace,
+			service+"."+api.Namespace+".svc",
+		)
+	}
+	return append(names, "*."+api.Name+"-unready."+api.Namespace+".svc.cluster.local")
+}
+
+// internodeDNSNames returns the DNS names of the pod in the headless service, the
+// first one being fully qualified
+func internodeDNSNames(api *v1alpha1.Cassandra, podName string) []string {
+	host := podName + "." + api.Name + "-unready"
+	return []string{
+		host + "." + api.Namespace + ".svc.cluster.local",
+		host,
+		host + "." + api.Namespace,
+		host + "." + api.Namespace + ".svc",
+	}
+}
+
+// days returns the duration of the number of days
+func days(n int32) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
+
+// nodeCertificateSecretName returns the name of the secret of the internode
+// certificate of the pod
+func nodeCertificateSecretName(podName string) string {
+	return podName + "-internode-tls"
+}
+
+// internodeKeystoreSecretName returns the name of the keystore secret of the
+// internode TLS
+func internodeKeystoreSecretName(api *v1alpha1.Cassandra) string {
+	return api.Name + "-internode-keystore"
+}
+
+// clientKeystoreSecretName returns the name of the keystore secret of the client TLS
+func clientKeystoreSecretName(api *v1alpha1.Cassandra) string {
+	return api.Name + "-client-keystore"
+}
+
+// certificate returns a cert-manager Certificate object
+func certificate(name, namespace string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(certificateAPIVersion)
+	u.SetKind("Certificate")
+	u.SetName(name)
+	u.SetNamespace(namespace)
+	return u
+}
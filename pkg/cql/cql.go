@@ -0,0 +1,100 @@
+// Package cql builds the CQL statements run by the operator with cqlsh and parses
+// their output
+package cql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// mapEntryRegexp matches the entries of a text map as printed by cqlsh
+var mapEntryRegexp = regexp.MustCompile(`'((?:[^']|'')*)'\s*:\s*'((?:[^']|'')*)'`)
+
+// setElementRegexp matches the elements of a text set as printed by cqlsh
+var setElementRegexp = regexp.MustCompile(`'((?:[^']|'')*)'`)
+
+// Row is a row of a query result, by column name
+type Row map[string]string
+
+// ParseRows parses the rows of the output of a query run with cqlsh
+func ParseRows(out string) ([]Row, error) {
+	lines := strings.Split(out, "\n")
+	for i, line := range lines {
+		if !isSeparator(line) {
+			continue
+		}
+		if i == 0 {
+			break
+		}
+		columns := splitColumns(lines[i-1])
+		var rows []Row
+		for _, line := range lines[i+1:] {
+			if len(strings.TrimSpace(line)) == 0 {
+				break
+			}
+			values := splitColumns(line)
+			if len(values) != len(columns) {
+				return nil, fmt.Errorf("invalid row %q", line)
+			}
+			row := Row{}
+			for j, c := range columns {
+				row[c] = values[j]
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("no rows found in cqlsh output")
+}
+
+// isSeparator returns if the line separates the header from the rows
+func isSeparator(line string) bool {
+	line = strings.TrimSpace(line)
+	return len(line) > 0 && strings.Trim(line, "-+") == ""
+}
+
+func splitColumns(line string) []string {
+	values := strings.Split(line, "|")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values
+}
+
+// ParseMap parses a map of text as printed by cqlsh, such as the replication of a keyspace
+func ParseMap(value string) map[string]string {
+	m := map[string]string{}
+	for _, entry := range mapEntryRegexp.FindAllStringSubmatch(value, -1) {
+		m[unescape(entry[1])] = unescape(entry[2])
+	}
+	return m
+}
+
+// ParseSet parses a set of text as printed by cqlsh, such as the permissions of a role
+func ParseSet(value string) []string {
+	var elements []string
+	for _, element := range setElementRegexp.FindAllStringSubmatch(value, -1) {
+		elements = append(elements, unescape(element[1]))
+	}
+	return elements
+}
+
+func unescape(s string) string {
+	return strings.Replace(s, "''", "'", -1)
+}
+
+// ParseBool parses a boolean as printed by cqlsh
+func ParseBool(value string) bool {
+	return strings.EqualFold(value, "true")
+}
+
+// QuoteIdentifier returns the identifier quoted, so its case is kept
+func QuoteIdentifier(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
+
+// QuoteString returns the string as a CQL string literal
+func QuoteString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -0,0 +1,53 @@
+package cql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const keyspacesOutput = `
+ keyspace_name | durable_writes | replication
+---------------+----------------+---------------------------------------------------------------------------------------
+           ks1 |           True | {'class': 'org.apache.cassandra.locator.NetworkTopologyStrategy', 'DC1-K8Demo': '3'}
+           ks2 |          False |      {'class': 'org.apache.cassandra.locator.SimpleStrategy', 'replication_factor': '1'}
+
+(2 rows)
+`
+
+func TestParseRows(t *testing.T) {
+	rows, err := ParseRows(keyspacesOutput)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "ks1", rows[0]["keyspace_name"])
+	assert.True(t, ParseBool(rows[0]["durable_writes"]))
+	assert.False(t, ParseBool(rows[1]["durable_writes"]))
+	assert.Equal(t, map[string]string{
+		"class":      "org.apache.cassandra.locator.NetworkTopologyStrategy",
+		"DC1-K8Demo": "3",
+	}, ParseMap(rows[0]["replication"]))
+}
+
+func TestParseRowsEmpty(t *testing.T) {
+	rows, err := ParseRows(`
+ keyspace_name | durable_writes | replication
+---------------+----------------+-------------
+
+(0 rows)
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(rows))
+
+	_, err = ParseRows("")
+	assert.Error(t, err)
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, `"MyKeyspace"`, QuoteIdentifier("MyKeyspace"))
+	assert.Equal(t, `"a""b"`, QuoteIdentifier(`a"b`))
+	assert.Equal(t, `'it''s'`, QuoteString("it's"))
+	assert.Equal(t, map[string]string{"k": "it's"}, ParseMap(`{'k': 'it''s'}`))
+	assert.Equal(t, []string{"MODIFY", "SELECT"}, ParseSet(`{'MODIFY', 'SELECT'}`))
+	assert.Nil(t, ParseSet("null"))
+}
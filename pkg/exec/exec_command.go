@@ -30,25 +30,41 @@ type Options struct {
 	Stdin         io.Reader
 	CaptureStdout bool
 	CaptureStderr bool
+
+	// Stdout, if set, receives the stdout of the command instead of it
+	// being captured, so large outputs are not held in memory
+	Stdout io.Writer
 }
 
-// Command run a command in a pod
+// DefaultContainerAnnotation is the pod annotation naming the container
+// that commands are run in when the container is not given by the caller
+const DefaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// Command run a command in a pod. The container is taken from the
+// DefaultContainerAnnotation annotation, or it is the only one in the pod
 func Command(podName, namespaceName string, cmd ...string) (string, error) {
+	return ContainerCommand(podName, "", namespaceName, cmd...)
+}
+
+// ContainerCommand run a command in the named container of a pod. If
+// containerName is empty the container is determined as in Command
+func ContainerCommand(podName, containerName, namespaceName string, cmd ...string) (string, error) {
 
 	pod, err := getPod(podName, namespaceName)
 	if err != nil {
 		return "", fmt.Errorf("could not get pod info: %v", err)
 	}
 
-	if len(pod.Spec.Containers) != 1 {
-		return "", fmt.Errorf("could not determine which container to use")
+	containerName, err = selectContainer(pod, containerName)
+	if err != nil {
+		return "", err
 	}
 
-	if pod.Status.ContainerStatuses[0].Ready != true {
-		return "", fmt.Errorf("container is not ready")
+	if !isContainerReady(pod, containerName) {
+		return "", fmt.Errorf("container %s is not ready", containerName)
 	}
 
-	execOut, execErr, err := CommandInContainer(podName, pod.Spec.Containers[0].Name, namespaceName, cmd...)
+	execOut, execErr, err := CommandInContainer(podName, containerName, namespaceName, cmd...)
 
 	if err != nil {
 		return "", fmt.Errorf("could not execute: %v", err)
@@ -76,6 +92,32 @@ func CommandInContainer(podName, containerName, namespaceName string, cmd ...str
 	})
 }
 
+// StreamCommand runs a command in the named container of a pod reading its stdin
+// from the given reader, if any, and writing its stdout to the given writer, if
+// any. Any output in stderr is returned as an error
+func StreamCommand(podName, containerName, namespaceName string, stdin io.Reader, stdout io.Writer, cmd ...string) error {
+	_, execErr, err := WithOptions(Options{
+		Command:       cmd,
+		Namespace:     namespaceName,
+		PodName:       podName,
+		ContainerName: containerName,
+
+		Stdin:         stdin,
+		CaptureStderr: true,
+		Stdout:        stdout,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not execute: %v", err)
+	}
+
+	if len(execErr) > 0 {
+		return fmt.Errorf("stderr: %v", execErr)
+	}
+
+	return nil
+}
+
 // WithOptions executes a command in the specified container,
 // returning stdout, stderr and error. `options` allowed for
 // additional parameters to be passed.
@@ -101,13 +143,17 @@ func WithOptions(options Options) (string, string, error) {
 		Container: options.ContainerName,
 		Command:   options.Command,
 		Stdin:     options.Stdin != nil,
-		Stdout:    options.CaptureStdout,
+		Stdout:    options.CaptureStdout || options.Stdout != nil,
 		Stderr:    options.CaptureStderr,
 		TTY:       tty,
 	}, scheme.ParameterCodec)
 
 	var stdout, stderr bytes.Buffer
-	err = execute("POST", req.URL(), config, options.Stdin, &stdout, &stderr, tty)
+	var out io.Writer = &stdout
+	if options.Stdout != nil {
+		out = options.Stdout
+	}
+	err = execute("POST", req.URL(), config, options.Stdin, out, &stderr, tty)
 
 	return stdout.String(), stderr.String(), err
 }
@@ -188,3 +234,36 @@ func getPod(podName, namespaceName string) (*v1.Pod, error) {
 
 	return pod, err
 }
+
+// selectContainer returns the name of the container to run commands in,
+// falling back to the DefaultContainerAnnotation annotation and then to
+// the only container of the pod
+func selectContainer(pod *v1.Pod, containerName string) (string, error) {
+	if len(containerName) == 0 {
+		containerName = pod.Annotations[DefaultContainerAnnotation]
+	}
+
+	if len(containerName) == 0 {
+		if len(pod.Spec.Containers) != 1 {
+			return "", fmt.Errorf("could not determine which container to use")
+		}
+		return pod.Spec.Containers[0].Name, nil
+	}
+
+	for _, c := range pod.Spec.Containers {
+		if c.Name == containerName {
+			return containerName, nil
+		}
+	}
+	return "", fmt.Errorf("container %s not found in pod %s", containerName, pod.Name)
+}
+
+// isContainerReady returns if the named container of the pod is ready
+func isContainerReady(pod *v1.Pod, containerName string) bool {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == containerName {
+			return cs.Ready
+		}
+	}
+	return false
+}
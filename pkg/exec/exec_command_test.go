@@ -0,0 +1,53 @@
+package exec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newPod(containers ...string) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-0",
+			Namespace: "default",
+		},
+	}
+	for _, c := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{Name: c})
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, v1.ContainerStatus{Name: c, Ready: c != "sidecar"})
+	}
+	return pod
+}
+
+func TestSelectContainer(t *testing.T) {
+	name, err := selectContainer(newPod("cassandra"), "")
+	assert.Nil(t, err)
+	assert.Equal(t, "cassandra", name)
+
+	_, err = selectContainer(newPod("cassandra", "sidecar"), "")
+	assert.Error(t, err)
+
+	name, err = selectContainer(newPod("sidecar", "cassandra"), "cassandra")
+	assert.Nil(t, err)
+	assert.Equal(t, "cassandra", name)
+
+	pod := newPod("sidecar", "cassandra")
+	pod.Annotations = map[string]string{DefaultContainerAnnotation: "cassandra"}
+	name, err = selectContainer(pod, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "cassandra", name)
+
+	_, err = selectContainer(newPod("cassandra"), "missing")
+	assert.Error(t, err)
+}
+
+func TestIsContainerReady(t *testing.T) {
+	pod := newPod("sidecar", "cassandra")
+
+	assert.True(t, isContainerReady(pod, "cassandra"))
+	assert.False(t, isContainerReady(pod, "sidecar"))
+	assert.False(t, isContainerReady(pod, "missing"))
+}
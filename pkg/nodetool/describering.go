@@ -0,0 +1,53 @@
+package nodetool
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var tokenRangeRegexp = regexp.MustCompile(`TokenRange\(start_token:(-?\d+), end_token:(-?\d+), endpoints:\[([^\]]*)\]`)
+
+// TokenRange is a token range of a keyspace as reported by nodetool describering
+type TokenRange struct {
+	Start string
+	End   string
+	// Endpoints are the addresses of the replicas, the first one is the primary
+	Endpoints []string
+}
+
+// Primary returns the address of the primary replica of the range
+func (tr TokenRange) Primary() string {
+	if len(tr.Endpoints) == 0 {
+		return ""
+	}
+	return tr.Endpoints[0]
+}
+
+// ParseDescribeRing parses the output of nodetool describering
+func ParseDescribeRing(out string) ([]TokenRange, error) {
+	var ranges []TokenRange
+	for _, m := range tokenRangeRegexp.FindAllStringSubmatch(out, -1) {
+		tr := TokenRange{Start: m[1], End: m[2]}
+		for _, e := range strings.Split(m[3], ",") {
+			tr.Endpoints = append(tr.Endpoints, strings.TrimSpace(e))
+		}
+		ranges = append(ranges, tr)
+	}
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("no token ranges found in nodetool describering output")
+	}
+	return ranges, nil
+}
+
+// PrimaryRanges returns the ranges whose primary replica is the given address
+func PrimaryRanges(ranges []TokenRange, address string) []TokenRange {
+	var primary []TokenRange
+	for _, tr := range ranges {
+		if tr.Primary() == address {
+			primary = append(primary, tr)
+		}
+	}
+	return primary
+}
@@ -0,0 +1,40 @@
+package nodetool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const describeRingOutput = `Schema Version:2207c2a9-f598-3971-986b-2926e09e239d
+TokenRange: 
+	TokenRange(start_token:-9204477816281284350, end_token:-9087373357046186484, endpoints:[172.17.0.4, 172.17.0.5], rpc_endpoints:[172.17.0.4, 172.17.0.5], endpoint_details:[EndpointDetails(host:172.17.0.4, datacenter:DC1-K8Demo, rack:Rack1-K8Demo), EndpointDetails(host:172.17.0.5, datacenter:DC1-K8Demo, rack:Rack1-K8Demo)])
+	TokenRange(start_token:-9087373357046186484, end_token:8955447386702470101, endpoints:[172.17.0.5, 172.17.0.4], rpc_endpoints:[172.17.0.5, 172.17.0.4], endpoint_details:[EndpointDetails(host:172.17.0.5, datacenter:DC1-K8Demo, rack:Rack1-K8Demo), EndpointDetails(host:172.17.0.4, datacenter:DC1-K8Demo, rack:Rack1-K8Demo)])
+`
+
+func TestParseDescribeRing(t *testing.T) {
+	ranges, err := ParseDescribeRing(describeRingOutput)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []TokenRange{
+		{
+			Start:     "-9204477816281284350",
+			End:       "-9087373357046186484",
+			Endpoints: []string{"172.17.0.4", "172.17.0.5"},
+		},
+		{
+			Start:     "-9087373357046186484",
+			End:       "8955447386702470101",
+			Endpoints: []string{"172.17.0.5", "172.17.0.4"},
+		},
+	}, ranges)
+
+	primary := PrimaryRanges(ranges, "172.17.0.5")
+	assert.Equal(t, 1, len(primary))
+	assert.Equal(t, "8955447386702470101", primary[0].End)
+}
+
+func TestParseDescribeRingWithoutRanges(t *testing.T) {
+	_, err := ParseDescribeRing("Keyspace 'missing' does not exist")
+	assert.Error(t, err)
+}
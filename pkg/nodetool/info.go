@@ -0,0 +1,41 @@
+package nodetool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Info is the identity of a node as reported by nodetool info -T
+type Info struct {
+	HostID     string
+	Datacenter string
+	Rack       string
+	Tokens     []string
+}
+
+// ParseInfo parses the output of nodetool info -T
+func ParseInfo(out string) (Info, error) {
+	var info Info
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(fields[1])
+		switch strings.TrimSpace(fields[0]) {
+		case "ID":
+			info.HostID = value
+		case "Data Center":
+			info.Datacenter = value
+		case "Rack":
+			info.Rack = value
+		case "Token":
+			info.Tokens = append(info.Tokens, value)
+		}
+	}
+
+	if len(info.HostID) == 0 {
+		return info, fmt.Errorf("no host ID found in nodetool info output")
+	}
+	return info, nil
+}
@@ -0,0 +1,39 @@
+package nodetool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const infoOutput = `ID                     : 3d4b3a4b-1b36-4f0a-9b52-6a4c1e8b6f11
+Gossip active          : true
+Thrift active          : false
+Native Transport active: true
+Load                   : 108.45 KiB
+Generation No          : 1525168290
+Uptime (seconds)       : 3600
+Heap Memory (MB)       : 120.28 / 512.00
+Data Center            : DC1-K8Demo
+Rack                   : Rack1-K8Demo
+Exceptions             : 0
+Token                  : -9204477816281284350
+Token                  : -9087373357046186484
+`
+
+func TestParseInfo(t *testing.T) {
+	info, err := ParseInfo(infoOutput)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Info{
+		HostID:     "3d4b3a4b-1b36-4f0a-9b52-6a4c1e8b6f11",
+		Datacenter: "DC1-K8Demo",
+		Rack:       "Rack1-K8Demo",
+		Tokens:     []string{"-9204477816281284350", "-9087373357046186484"},
+	}, info)
+}
+
+func TestParseInfoWithoutHostID(t *testing.T) {
+	_, err := ParseInfo("nodetool: Failed to connect")
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+// Package nodetool parses the output of the nodetool commands run by the operator
+package nodetool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Node represents a member of the ring as reported by nodetool status
+type Node struct {
+	Datacenter string
+	// Status is U (up) or D (down)
+	Status string
+	// State is N (normal), L (leaving), J (joining) or M (moving)
+	State   string
+	Address string
+	Load    string
+	Tokens  string
+	Owns    string
+	HostID  string
+	Rack    string
+}
+
+// IsUpNormal returns if the node is up and in the normal state
+func (n Node) IsUpNormal() bool {
+	return n.Status == "U" && n.State == "N"
+}
+
+// IsDown returns if the node is down
+func (n Node) IsDown() bool {
+	return n.Status == "D"
+}
+
+// Ring is the list of nodes reported by nodetool status
+type Ring []Node
+
+// ByAddress returns the node with the given address, nil if not found
+func (r Ring) ByAddress(address string) *Node {
+	for i := range r {
+		if r[i].Address == address {
+			return &r[i]
+		}
+	}
+	return nil
+}
+
+// ByHostID returns the node with the given host ID, nil if not found
+func (r Ring) ByHostID(hostID string) *Node {
+	for i := range r {
+		if r[i].HostID == hostID {
+			return &r[i]
+		}
+	}
+	return nil
+}
+
+// ParseStatus parses the output of nodetool status
+func ParseStatus(out string) (Ring, error) {
+	var ring Ring
+	dc := ""
+
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Datacenter:") {
+			dc = strings.TrimSpace(strings.TrimPrefix(line, "Datacenter:"))
+			continue
+		}
+
+		f := strings.Fields(line)
+		if len(f) < 7 || len(f[0]) != 2 || !strings.ContainsAny(f[0][:1], "UD") || !strings.ContainsAny(f[0][1:], "NLJM") {
+			continue
+		}
+
+		// the load has a unit separated by a space, except when it is unknown
+		n := len(f)
+		ring = append(ring, Node{
+			Datacenter: dc,
+			Status:     f[0][:1],
+			State:      f[0][1:],
+			Address:    f[1],
+			Load:       strings.Join(f[2:n-4], " "),
+			Tokens:     f[n-4],
+			Owns:       f[n-3],
+			HostID:     f[n-2],
+			Rack:       f[n-1],
+		})
+	}
+
+	if len(ring) == 0 {
+		return nil, fmt.Errorf("no nodes found in nodetool status output")
+	}
+	return ring, nil
+}
@@ -0,0 +1,51 @@
+package nodetool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const statusOutput = `Datacenter: DC1-K8Demo
+======================
+Status=Up/Down
+|/ State=Normal/Leaving/Joining/Moving
+--  Address     Load       Tokens       Owns (effective)  Host ID                               Rack
+UN  172.17.0.4  99.45 KiB  32           65.2%             a8a4c3b9-27e0-4d52-a1b6-0ba4d8b23e0e  Rack1-K8Demo
+DN  172.17.0.5  ?          32           68.1%             0e3e3d5b-1b55-4a4f-a0bc-9d3b9e3b5c71  Rack1-K8Demo
+UJ  172.17.0.6  14.27 MiB  32           ?                 5f1d4a0e-68e1-4b47-b0e3-4a5b3c8e9a11  Rack1-K8Demo
+`
+
+func TestParseStatus(t *testing.T) {
+	ring, err := ParseStatus(statusOutput)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(ring))
+	assert.Equal(t, Node{
+		Datacenter: "DC1-K8Demo",
+		Status:     "U",
+		State:      "N",
+		Address:    "172.17.0.4",
+		Load:       "99.45 KiB",
+		Tokens:     "32",
+		Owns:       "65.2%",
+		HostID:     "a8a4c3b9-27e0-4d52-a1b6-0ba4d8b23e0e",
+		Rack:       "Rack1-K8Demo",
+	}, ring[0])
+	assert.True(t, ring[0].IsUpNormal())
+
+	assert.Equal(t, "?", ring[1].Load)
+	assert.True(t, ring[1].IsDown())
+
+	assert.False(t, ring[2].IsUpNormal())
+	assert.Equal(t, "J", ring[2].State)
+
+	assert.Equal(t, "172.17.0.5", ring.ByHostID("0e3e3d5b-1b55-4a4f-a0bc-9d3b9e3b5c71").Address)
+	assert.Equal(t, "5f1d4a0e-68e1-4b47-b0e3-4a5b3c8e9a11", ring.ByAddress("172.17.0.6").HostID)
+	assert.Nil(t, ring.ByAddress("172.17.0.7"))
+}
+
+func TestParseStatusWithoutNodes(t *testing.T) {
+	_, err := ParseStatus("nodetool: Failed to connect to '127.0.0.1:7199'")
+	assert.Error(t, err)
+}
@@ -3,6 +3,7 @@ package stub
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/camilocot/cassandra-operator/pkg/apis/database/v1alpha1"
 	"github.com/camilocot/cassandra-operator/pkg/util/probe"
@@ -27,14 +28,68 @@ type CassandraHandler struct {
 func (h *CassandraHandler) Handle(ctx context.Context, event sdk.Event) (err error) {
 	switch o := event.Object.(type) {
 	case *v1alpha1.Cassandra:
-		// Ignore the delete event since the garbage collector will clean up all secondary resources for the CR
+		// Ignore the delete event since the finalizer has been released and the
+		// garbage collector will clean up all secondary resources for the CR
 		if event.Deleted {
 			return nil
 		}
-		err = h.Reconcile(cassandra.NewCassandraCluster(o))
+		if o.IsBeingDeleted() {
+			err = h.Finalize(cassandra.NewCassandraCluster(o))
+		} else {
+			err = h.Reconcile(cassandra.NewCassandraCluster(o))
+		}
 		if err != nil {
 			logrus.Errorf("Reconciliation error: %v", err)
 		}
+	case *v1alpha1.CassandraBackup:
+		if event.Deleted {
+			return nil
+		}
+		err = h.ReconcileBackup(cassandra.NewCassandraBackup(o))
+		if err != nil {
+			logrus.Errorf("Backup reconciliation error: %v", err)
+		}
+	case *v1alpha1.CassandraBackupSchedule:
+		if event.Deleted {
+			return nil
+		}
+		err = h.ReconcileBackupSchedule(cassandra.NewCassandraBackupSchedule(o))
+		if err != nil {
+			logrus.Errorf("Backup schedule reconciliation error: %v", err)
+		}
+	case *v1alpha1.CassandraRestore:
+		if event.Deleted {
+			return nil
+		}
+		err = h.ReconcileRestore(cassandra.NewCassandraRestore(o))
+		if err != nil {
+			logrus.Errorf("Restore reconciliation error: %v", err)
+		}
+	case *v1alpha1.CassandraKeyspace:
+		// The deletion policy is applied while the finalizer is set
+		if event.Deleted {
+			return nil
+		}
+		err = h.ReconcileKeyspace(cassandra.NewCassandraKeyspace(o))
+		if err != nil {
+			logrus.Errorf("Keyspace reconciliation error: %v", err)
+		}
+	case *v1alpha1.CassandraRole:
+		if event.Deleted {
+			return nil
+		}
+		err = h.ReconcileRole(cassandra.NewCassandraRole(o))
+		if err != nil {
+			logrus.Errorf("Role reconciliation error: %v", err)
+		}
+	case *v1alpha1.CassandraGrant:
+		if event.Deleted {
+			return nil
+		}
+		err = h.ReconcileGrant(cassandra.NewCassandraGrant(o))
+		if err != nil {
+			logrus.Errorf("Grant reconciliation error: %v", err)
+		}
 	}
 	return err
 }
@@ -50,22 +105,48 @@ func (h *CassandraHandler) Reconcile(c cassandra.Controller) (err error) {
 
 	c.SetDefaults()
 
-	// Reconcile Service object
-	err = c.ReconcileService()
-	if err != nil {
-		return c.FailedReconciliation("service", err)
+	// The steps mutating the cluster, in order
+	steps := []struct {
+		name      string
+		reconcile func() error
+	}{
+		// the finalizer that applies the deletion policy
+		{"finalizer", c.ReconcileFinalizer},
+		// the services and the network policy
+		{"service", c.ReconcileService},
+		// the external services of the nodes, requesting a rolling restart when their addresses change
+		{"external access", c.ReconcileExternalAccess},
+		// the pod disruption budget, tightened while a node is stopped
+		{"disruption budget", c.ReconcileDisruptionBudget},
+		{"members", c.ReconcileMembers},
+		// the keystores of the certificates, requesting a rolling restart when they change
+		{"tls", c.ReconcileTLS},
+		// the rolling restart
+		{"restart", c.ReconcileRestart},
+		{"statefulset", c.ReconcileStatefulset},
+		// the hosts of the nodes
+		{"hosts", c.ReconcileHosts},
+		{"dead nodes", c.ReconcileDeadNodes},
+		// the scheduled repairs
+		{"repair", c.ReconcileRepair},
+		// the admin role, the replication of system_auth and the default superuser
+		{"auth", c.ReconcileAuth},
 	}
 
-	// Reconcile Members
-	err = c.ReconcileMembers()
-	if err != nil {
-		return c.FailedReconciliation("members", err)
-	}
-
-	// Reconcile StatefulSet object
-	err = c.ReconcileStatefulset()
-	if err != nil {
-		return c.FailedReconciliation("statefulset", err)
+	// Only the status is reconciled while the cluster is paused
+	if c.IsPaused() {
+		skipped := make([]string, 0, len(steps))
+		for _, step := range steps {
+			skipped = append(skipped, step.name)
+		}
+		logrus.Infof("Reconciliation paused, skipping %v", strings.Join(skipped, ", "))
+	} else {
+		for _, step := range steps {
+			err = step.reconcile()
+			if err != nil {
+				return c.FailedReconciliation(step.name, err)
+			}
+		}
 	}
 
 	// Reconcile Status object
@@ -77,3 +158,76 @@ func (h *CassandraHandler) Reconcile(c cassandra.Controller) (err error) {
 	return nil
 
 }
+
+// Finalize applies the deletion policy of a cassandra cluster marked for deletion
+func (h *CassandraHandler) Finalize(c cassandra.Controller) (err error) {
+	if c == nil {
+		return fmt.Errorf("controller cannot be nil")
+	}
+
+	if c.IsPaused() {
+		logrus.Infof("Reconciliation paused, skipping the deletion policy")
+		return nil
+	}
+
+	err = c.Finalize()
+	if err != nil {
+		return c.FailedReconciliation("finalizer", err)
+	}
+
+	return nil
+}
+
+// ReconcileBackup takes the backup of a cassandra cluster
+func (h *CassandraHandler) ReconcileBackup(c cassandra.BackupController) error {
+	if c == nil {
+		return fmt.Errorf("controller cannot be nil")
+	}
+
+	return c.ReconcileBackup()
+}
+
+// ReconcileBackupSchedule creates and prunes the backups of a backup schedule
+func (h *CassandraHandler) ReconcileBackupSchedule(c cassandra.BackupScheduleController) error {
+	if c == nil {
+		return fmt.Errorf("controller cannot be nil")
+	}
+
+	return c.ReconcileBackupSchedule()
+}
+
+// ReconcileRestore restores a backup into a cassandra cluster
+func (h *CassandraHandler) ReconcileRestore(c cassandra.RestoreController) error {
+	if c == nil {
+		return fmt.Errorf("controller cannot be nil")
+	}
+
+	return c.ReconcileRestore()
+}
+
+// ReconcileKeyspace creates, alters or drops a keyspace of a cassandra cluster
+func (h *CassandraHandler) ReconcileKeyspace(c cassandra.KeyspaceController) error {
+	if c == nil {
+		return fmt.Errorf("controller cannot be nil")
+	}
+
+	return c.ReconcileKeyspace()
+}
+
+// ReconcileRole creates, alters or drops a role of a cassandra cluster
+func (h *CassandraHandler) ReconcileRole(c cassandra.RoleController) error {
+	if c == nil {
+		return fmt.Errorf("controller cannot be nil")
+	}
+
+	return c.ReconcileRole()
+}
+
+// ReconcileGrant grants or revokes the permissions of a role of a cassandra cluster
+func (h *CassandraHandler) ReconcileGrant(c cassandra.GrantController) error {
+	if c == nil {
+		return fmt.Errorf("controller cannot be nil")
+	}
+
+	return c.ReconcileGrant()
+}
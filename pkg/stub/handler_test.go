@@ -27,6 +27,16 @@ func (m *MockCassandaCluster) ReconcileService() error {
 	return args.Error(0)
 }
 
+func (m *MockCassandaCluster) ReconcileExternalAccess() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockCassandaCluster) ReconcileDisruptionBudget() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 func (m *MockCassandaCluster) ReconcileMembers() error {
 	args := m.Called()
 	return args.Error(0)
@@ -42,16 +52,115 @@ func (m *MockCassandaCluster) ReconcileStatus() error {
 	return args.Error(0)
 }
 
+func (m *MockCassandaCluster) ReconcileTLS() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockCassandaCluster) ReconcileRestart() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockCassandaCluster) ReconcileHosts() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockCassandaCluster) ReconcileDeadNodes() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockCassandaCluster) ReconcileRepair() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockCassandaCluster) ReconcileAuth() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockCassandaCluster) ReconcileFinalizer() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockCassandaCluster) Finalize() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 func (m *MockCassandaCluster) SetDefaults() bool {
 	args := m.Called()
 	return args.Bool(0)
 }
 
+func (m *MockCassandaCluster) IsPaused() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
+
 func (m *MockCassandaCluster) FailedReconciliation(failedObjectName string, err error) error {
 	_ = m.Called(failedObjectName, err)
 	return fmt.Errorf("%s %v", failedObjectName, err)
 }
 
+type MockCassandraBackup struct {
+	mock.Mock
+}
+
+func (m *MockCassandraBackup) ReconcileBackup() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type MockCassandraBackupSchedule struct {
+	mock.Mock
+}
+
+func (m *MockCassandraBackupSchedule) ReconcileBackupSchedule() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type MockCassandraRestore struct {
+	mock.Mock
+}
+
+func (m *MockCassandraRestore) ReconcileRestore() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type MockCassandraKeyspace struct {
+	mock.Mock
+}
+
+func (m *MockCassandraKeyspace) ReconcileKeyspace() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type MockCassandraRole struct {
+	mock.Mock
+}
+
+func (m *MockCassandraRole) ReconcileRole() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+type MockCassandraGrant struct {
+	mock.Mock
+}
+
+func (m *MockCassandraGrant) ReconcileGrant() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 func (suite *HandlerTestSuite) SetupTest() {
 	// Run before each test...
 }
@@ -86,9 +195,19 @@ func (suite *HandlerTestSuite) TestReconcileWithValidInput() {
 	cluster := new(MockCassandaCluster)
 
 	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(nil)
 	cluster.On("ReconcileService").Return(nil)
+	cluster.On("ReconcileExternalAccess").Return(nil)
+	cluster.On("ReconcileDisruptionBudget").Return(nil)
 	cluster.On("ReconcileMembers").Return(nil)
+	cluster.On("ReconcileTLS").Return(nil)
+	cluster.On("ReconcileRestart").Return(nil)
 	cluster.On("ReconcileStatefulset").Return(nil)
+	cluster.On("ReconcileHosts").Return(nil)
+	cluster.On("ReconcileDeadNodes").Return(nil)
+	cluster.On("ReconcileRepair").Return(nil)
+	cluster.On("ReconcileAuth").Return(nil)
 	cluster.On("ReconcileStatus").Return(nil)
 
 	handler := NewHandler()
@@ -103,6 +222,8 @@ func (suite *HandlerTestSuite) TestReconcileWithServiceFailure() {
 	err := errors.New("failed")
 	cluster := new(MockCassandaCluster)
 	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(nil)
 	cluster.On("ReconcileService").Return(err)
 	cluster.On("FailedReconciliation", "service", err).Return(nil)
 
@@ -114,11 +235,52 @@ func (suite *HandlerTestSuite) TestReconcileWithServiceFailure() {
 	assert.Equal(suite.T(), "service failed", err.Error())
 }
 
+func (suite *HandlerTestSuite) TestReconcileWithExternalAccessFailure() {
+	err := errors.New("failed")
+	cluster := new(MockCassandaCluster)
+	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(nil)
+	cluster.On("ReconcileService").Return(nil)
+	cluster.On("ReconcileExternalAccess").Return(err)
+	cluster.On("FailedReconciliation", "external access", err).Return(nil)
+
+	handler := NewHandler()
+	err = handler.Reconcile(cluster)
+
+	cluster.AssertExpectations(suite.T())
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), "external access failed", err.Error())
+}
+
+func (suite *HandlerTestSuite) TestReconcileWithDisruptionBudgetFailure() {
+	err := errors.New("failed")
+	cluster := new(MockCassandaCluster)
+	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(nil)
+	cluster.On("ReconcileService").Return(nil)
+	cluster.On("ReconcileExternalAccess").Return(nil)
+	cluster.On("ReconcileDisruptionBudget").Return(err)
+	cluster.On("FailedReconciliation", "disruption budget", err).Return(nil)
+
+	handler := NewHandler()
+	err = handler.Reconcile(cluster)
+
+	cluster.AssertExpectations(suite.T())
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), "disruption budget failed", err.Error())
+}
+
 func (suite *HandlerTestSuite) TestReconcileWithMembersFailure() {
 	err := errors.New("failed")
 	cluster := new(MockCassandaCluster)
 	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(nil)
 	cluster.On("ReconcileService").Return(nil)
+	cluster.On("ReconcileExternalAccess").Return(nil)
+	cluster.On("ReconcileDisruptionBudget").Return(nil)
 	cluster.On("ReconcileMembers").Return(errors.New("failed"))
 	cluster.On("FailedReconciliation", "members", err).Return(nil)
 
@@ -130,12 +292,61 @@ func (suite *HandlerTestSuite) TestReconcileWithMembersFailure() {
 	assert.Equal(suite.T(), "members failed", err.Error())
 }
 
+func (suite *HandlerTestSuite) TestReconcileWithTLSFailure() {
+	err := errors.New("failed")
+	cluster := new(MockCassandaCluster)
+	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(nil)
+	cluster.On("ReconcileService").Return(nil)
+	cluster.On("ReconcileExternalAccess").Return(nil)
+	cluster.On("ReconcileDisruptionBudget").Return(nil)
+	cluster.On("ReconcileMembers").Return(nil)
+	cluster.On("ReconcileTLS").Return(err)
+	cluster.On("FailedReconciliation", "tls", err).Return(nil)
+
+	handler := NewHandler()
+	err = handler.Reconcile(cluster)
+
+	cluster.AssertExpectations(suite.T())
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), "tls failed", err.Error())
+}
+
+func (suite *HandlerTestSuite) TestReconcileWithRestartFailure() {
+	err := errors.New("failed")
+	cluster := new(MockCassandaCluster)
+	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(nil)
+	cluster.On("ReconcileService").Return(nil)
+	cluster.On("ReconcileExternalAccess").Return(nil)
+	cluster.On("ReconcileDisruptionBudget").Return(nil)
+	cluster.On("ReconcileMembers").Return(nil)
+	cluster.On("ReconcileTLS").Return(nil)
+	cluster.On("ReconcileRestart").Return(err)
+	cluster.On("FailedReconciliation", "restart", err).Return(nil)
+
+	handler := NewHandler()
+	err = handler.Reconcile(cluster)
+
+	cluster.AssertExpectations(suite.T())
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), "restart failed", err.Error())
+}
+
 func (suite *HandlerTestSuite) TestReconcileWithStatefulsetFailure() {
 	err := errors.New("failed")
 	cluster := new(MockCassandaCluster)
 	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(nil)
 	cluster.On("ReconcileService").Return(nil)
+	cluster.On("ReconcileExternalAccess").Return(nil)
+	cluster.On("ReconcileDisruptionBudget").Return(nil)
 	cluster.On("ReconcileMembers").Return(nil)
+	cluster.On("ReconcileTLS").Return(nil)
+	cluster.On("ReconcileRestart").Return(nil)
 	cluster.On("ReconcileStatefulset").Return(errors.New("failed"))
 	cluster.On("FailedReconciliation", "statefulset", err).Return(nil)
 
@@ -147,13 +358,125 @@ func (suite *HandlerTestSuite) TestReconcileWithStatefulsetFailure() {
 	assert.Equal(suite.T(), "statefulset failed", err.Error())
 }
 
+func (suite *HandlerTestSuite) TestReconcileWithHostsFailure() {
+	err := errors.New("failed")
+	cluster := new(MockCassandaCluster)
+	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(nil)
+	cluster.On("ReconcileService").Return(nil)
+	cluster.On("ReconcileExternalAccess").Return(nil)
+	cluster.On("ReconcileDisruptionBudget").Return(nil)
+	cluster.On("ReconcileMembers").Return(nil)
+	cluster.On("ReconcileTLS").Return(nil)
+	cluster.On("ReconcileRestart").Return(nil)
+	cluster.On("ReconcileStatefulset").Return(nil)
+	cluster.On("ReconcileHosts").Return(err)
+	cluster.On("FailedReconciliation", "hosts", err).Return(nil)
+
+	handler := NewHandler()
+	err = handler.Reconcile(cluster)
+
+	cluster.AssertExpectations(suite.T())
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), "hosts failed", err.Error())
+}
+
+func (suite *HandlerTestSuite) TestReconcileWithDeadNodesFailure() {
+	err := errors.New("failed")
+	cluster := new(MockCassandaCluster)
+	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(nil)
+	cluster.On("ReconcileService").Return(nil)
+	cluster.On("ReconcileExternalAccess").Return(nil)
+	cluster.On("ReconcileDisruptionBudget").Return(nil)
+	cluster.On("ReconcileMembers").Return(nil)
+	cluster.On("ReconcileTLS").Return(nil)
+	cluster.On("ReconcileRestart").Return(nil)
+	cluster.On("ReconcileStatefulset").Return(nil)
+	cluster.On("ReconcileHosts").Return(nil)
+	cluster.On("ReconcileDeadNodes").Return(err)
+	cluster.On("FailedReconciliation", "dead nodes", err).Return(nil)
+
+	handler := NewHandler()
+	err = handler.Reconcile(cluster)
+
+	cluster.AssertExpectations(suite.T())
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), "dead nodes failed", err.Error())
+}
+
+func (suite *HandlerTestSuite) TestReconcileWithRepairFailure() {
+	err := errors.New("failed")
+	cluster := new(MockCassandaCluster)
+	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(nil)
+	cluster.On("ReconcileService").Return(nil)
+	cluster.On("ReconcileExternalAccess").Return(nil)
+	cluster.On("ReconcileDisruptionBudget").Return(nil)
+	cluster.On("ReconcileMembers").Return(nil)
+	cluster.On("ReconcileTLS").Return(nil)
+	cluster.On("ReconcileRestart").Return(nil)
+	cluster.On("ReconcileStatefulset").Return(nil)
+	cluster.On("ReconcileHosts").Return(nil)
+	cluster.On("ReconcileDeadNodes").Return(nil)
+	cluster.On("ReconcileRepair").Return(err)
+	cluster.On("FailedReconciliation", "repair", err).Return(nil)
+
+	handler := NewHandler()
+	err = handler.Reconcile(cluster)
+
+	cluster.AssertExpectations(suite.T())
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), "repair failed", err.Error())
+}
+
+func (suite *HandlerTestSuite) TestReconcileWithAuthFailure() {
+	err := errors.New("failed")
+	cluster := new(MockCassandaCluster)
+	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(nil)
+	cluster.On("ReconcileService").Return(nil)
+	cluster.On("ReconcileExternalAccess").Return(nil)
+	cluster.On("ReconcileDisruptionBudget").Return(nil)
+	cluster.On("ReconcileMembers").Return(nil)
+	cluster.On("ReconcileTLS").Return(nil)
+	cluster.On("ReconcileRestart").Return(nil)
+	cluster.On("ReconcileStatefulset").Return(nil)
+	cluster.On("ReconcileHosts").Return(nil)
+	cluster.On("ReconcileDeadNodes").Return(nil)
+	cluster.On("ReconcileRepair").Return(nil)
+	cluster.On("ReconcileAuth").Return(err)
+	cluster.On("FailedReconciliation", "auth", err).Return(nil)
+
+	handler := NewHandler()
+	err = handler.Reconcile(cluster)
+
+	cluster.AssertExpectations(suite.T())
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), "auth failed", err.Error())
+}
+
 func (suite *HandlerTestSuite) TestReconcileWithStatusFailure() {
 	err := errors.New("failed")
 	cluster := new(MockCassandaCluster)
 	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(nil)
 	cluster.On("ReconcileService").Return(nil)
+	cluster.On("ReconcileExternalAccess").Return(nil)
+	cluster.On("ReconcileDisruptionBudget").Return(nil)
 	cluster.On("ReconcileMembers").Return(nil)
+	cluster.On("ReconcileTLS").Return(nil)
+	cluster.On("ReconcileRestart").Return(nil)
 	cluster.On("ReconcileStatefulset").Return(nil)
+	cluster.On("ReconcileHosts").Return(nil)
+	cluster.On("ReconcileDeadNodes").Return(nil)
+	cluster.On("ReconcileRepair").Return(nil)
+	cluster.On("ReconcileAuth").Return(nil)
 	cluster.On("ReconcileStatus").Return(errors.New("failed"))
 	cluster.On("FailedReconciliation", "status", err).Return(nil)
 
@@ -165,7 +488,195 @@ func (suite *HandlerTestSuite) TestReconcileWithStatusFailure() {
 	assert.Equal(suite.T(), "status failed", err.Error())
 }
 
+func (suite *HandlerTestSuite) TestReconcileWithFinalizerFailure() {
+	err := errors.New("failed")
+	cluster := new(MockCassandaCluster)
+	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("ReconcileFinalizer").Return(err)
+	cluster.On("FailedReconciliation", "finalizer", err).Return(nil)
+
+	handler := NewHandler()
+	err = handler.Reconcile(cluster)
+
+	cluster.AssertExpectations(suite.T())
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), "finalizer failed", err.Error())
+}
+
+func (suite *HandlerTestSuite) TestReconcilePaused() {
+	cluster := new(MockCassandaCluster)
+	cluster.On("SetDefaults").Return(false)
+	cluster.On("IsPaused").Return(true)
+	cluster.On("ReconcileStatus").Return(nil)
+
+	handler := NewHandler()
+	err := handler.Reconcile(cluster)
+
+	cluster.AssertExpectations(suite.T())
+	cluster.AssertNotCalled(suite.T(), "ReconcileFinalizer")
+	cluster.AssertNotCalled(suite.T(), "ReconcileService")
+	cluster.AssertNotCalled(suite.T(), "ReconcileExternalAccess")
+	cluster.AssertNotCalled(suite.T(), "ReconcileDisruptionBudget")
+	cluster.AssertNotCalled(suite.T(), "ReconcileMembers")
+	cluster.AssertNotCalled(suite.T(), "ReconcileTLS")
+	cluster.AssertNotCalled(suite.T(), "ReconcileRestart")
+	cluster.AssertNotCalled(suite.T(), "ReconcileStatefulset")
+	cluster.AssertNotCalled(suite.T(), "ReconcileHosts")
+	cluster.AssertNotCalled(suite.T(), "ReconcileDeadNodes")
+	cluster.AssertNotCalled(suite.T(), "ReconcileRepair")
+	cluster.AssertNotCalled(suite.T(), "ReconcileAuth")
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestFinalizePaused() {
+	cluster := new(MockCassandaCluster)
+	cluster.On("IsPaused").Return(true)
+
+	handler := NewHandler()
+	err := handler.Finalize(cluster)
+
+	cluster.AssertExpectations(suite.T())
+	cluster.AssertNotCalled(suite.T(), "Finalize")
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestFinalizeWithNilInput() {
+	handler := NewHandler()
+	err := handler.Finalize(nil)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestFinalizeWithValidInput() {
+	cluster := new(MockCassandaCluster)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("Finalize").Return(nil)
+
+	handler := NewHandler()
+	err := handler.Finalize(cluster)
+
+	cluster.AssertExpectations(suite.T())
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestFinalizeWithFailure() {
+	err := errors.New("failed")
+	cluster := new(MockCassandaCluster)
+	cluster.On("IsPaused").Return(false)
+	cluster.On("Finalize").Return(err)
+	cluster.On("FailedReconciliation", "finalizer", err).Return(nil)
+
+	handler := NewHandler()
+	err = handler.Finalize(cluster)
+
+	cluster.AssertExpectations(suite.T())
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), "finalizer failed", err.Error())
+}
+
 // Run test suite...
+func (suite *HandlerTestSuite) TestReconcileBackup() {
+	backup := new(MockCassandraBackup)
+	backup.On("ReconcileBackup").Return(nil)
+
+	handler := NewHandler()
+	err := handler.ReconcileBackup(backup)
+
+	backup.AssertExpectations(suite.T())
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestReconcileBackupWithNilInput() {
+	handler := NewHandler()
+	err := handler.ReconcileBackup(nil)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestReconcileBackupSchedule() {
+	schedule := new(MockCassandraBackupSchedule)
+	schedule.On("ReconcileBackupSchedule").Return(nil)
+
+	handler := NewHandler()
+	err := handler.ReconcileBackupSchedule(schedule)
+
+	schedule.AssertExpectations(suite.T())
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestReconcileBackupScheduleWithNilInput() {
+	handler := NewHandler()
+	err := handler.ReconcileBackupSchedule(nil)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestReconcileRestore() {
+	restore := new(MockCassandraRestore)
+	restore.On("ReconcileRestore").Return(nil)
+
+	handler := NewHandler()
+	err := handler.ReconcileRestore(restore)
+
+	restore.AssertExpectations(suite.T())
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestReconcileRestoreWithNilInput() {
+	handler := NewHandler()
+	err := handler.ReconcileRestore(nil)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestReconcileKeyspace() {
+	keyspace := new(MockCassandraKeyspace)
+	keyspace.On("ReconcileKeyspace").Return(nil)
+
+	handler := NewHandler()
+	err := handler.ReconcileKeyspace(keyspace)
+
+	keyspace.AssertExpectations(suite.T())
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestReconcileKeyspaceWithNilInput() {
+	handler := NewHandler()
+	err := handler.ReconcileKeyspace(nil)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestReconcileRole() {
+	role := new(MockCassandraRole)
+	role.On("ReconcileRole").Return(nil)
+
+	handler := NewHandler()
+	err := handler.ReconcileRole(role)
+
+	role.AssertExpectations(suite.T())
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestReconcileRoleWithNilInput() {
+	handler := NewHandler()
+	err := handler.ReconcileRole(nil)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestReconcileGrant() {
+	grant := new(MockCassandraGrant)
+	grant.On("ReconcileGrant").Return(nil)
+
+	handler := NewHandler()
+	err := handler.ReconcileGrant(grant)
+
+	grant.AssertExpectations(suite.T())
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *HandlerTestSuite) TestReconcileGrantWithNilInput() {
+	handler := NewHandler()
+	err := handler.ReconcileGrant(nil)
+	assert.Error(suite.T(), err)
+}
+
 func TestHandlerTestSuite(t *testing.T) {
 	suite.Run(t, new(HandlerTestSuite))
 }
@@ -0,0 +1,50 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseTime(t *testing.T, value string) time.Time {
+	parsed, err := time.Parse(time.RFC3339, value)
+	assert.Nil(t, err)
+	return parsed
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		spec, from, next string
+	}{
+		{"* * * * *", "2018-07-01T10:00:30Z", "2018-07-01T10:01:00Z"},
+		{"30 2 * * *", "2018-07-01T10:00:00Z", "2018-07-02T02:30:00Z"},
+		{"*/15 * * * *", "2018-07-01T10:16:00Z", "2018-07-01T10:30:00Z"},
+		{"0 0 * * 0", "2018-07-02T00:00:00Z", "2018-07-08T00:00:00Z"},
+		{"0 0 * * 7", "2018-07-02T00:00:00Z", "2018-07-08T00:00:00Z"},
+		{"0 1 1,15 * *", "2018-07-02T00:00:00Z", "2018-07-15T01:00:00Z"},
+		{"0 0 1 * 1", "2018-07-03T00:00:00Z", "2018-07-09T00:00:00Z"},
+		{"0 22 * * 1-5", "2018-07-06T23:00:00Z", "2018-07-09T22:00:00Z"},
+		{"@monthly", "2018-12-15T00:00:00Z", "2019-01-01T00:00:00Z"},
+		{"0 0 29 2 *", "2018-03-01T00:00:00Z", "2020-02-29T00:00:00Z"},
+	}
+
+	for _, test := range tests {
+		s, err := Parse(test.spec)
+		assert.Nil(t, err, test.spec)
+		assert.Equal(t, parseTime(t, test.next), s.Next(parseTime(t, test.from)), test.spec)
+	}
+}
+
+func TestNextWithoutActivation(t *testing.T) {
+	s, err := Parse("0 0 31 2 *")
+	assert.Nil(t, err)
+	assert.True(t, s.Next(parseTime(t, "2018-07-01T00:00:00Z")).IsZero())
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
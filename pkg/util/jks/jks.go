@@ -0,0 +1,170 @@
+// Package jks encodes Java KeyStores, the format of the keystores and truststores
+// read by cassandra. The private keys are protected with the proprietary algorithm
+// of the JKS provider of the JDK, and the keystore with its integrity digest
+package jks
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1" // #nosec, required by the JKS format
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	magic   = 0xfeedfeed
+	version = 2
+
+	privateKeyTag         = 1
+	trustedCertificateTag = 2
+
+	certificateType = "X.509"
+	// whitener is mixed in the integrity digest of the keystore
+	whitener = "Mighty Aphrodite"
+)
+
+// keyProtectorOID identifies the algorithm protecting the private keys
+var keyProtectorOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+// PrivateKeyEntry is a private key and its certificate chain
+type PrivateKeyEntry struct {
+	Alias string
+	// PKCS8Key is the private key in PKCS #8, DER encoded
+	PKCS8Key []byte
+	// Chain are the DER encoded certificates, the one of the key first
+	Chain [][]byte
+}
+
+// TrustedCertificateEntry is a trusted certificate
+type TrustedCertificateEntry struct {
+	Alias string
+	// Certificate is the DER encoded certificate
+	Certificate []byte
+}
+
+// KeyStore is a Java KeyStore
+type KeyStore struct {
+	PrivateKeys         []PrivateKeyEntry
+	TrustedCertificates []TrustedCertificateEntry
+}
+
+// encryptedPrivateKeyInfo is the protected private key of an entry
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// Encode returns the keystore protected with the password, the same one protecting
+// its private keys. Aliases are case insensitive, they should be lower case
+func (ks *KeyStore) Encode(password string, created time.Time) ([]byte, error) {
+	passwordBytes := passwordBytes(password)
+	timestamp := uint64(created.UnixNano() / int64(time.Millisecond))
+
+	var buf bytes.Buffer
+	write := func(v interface{}) {
+		// writes to a bytes.Buffer do not fail
+		_ = binary.Write(&buf, binary.BigEndian, v)
+	}
+	writeUTF := func(s string) {
+		write(uint16(len(s)))
+		buf.WriteString(s)
+	}
+
+	write(uint32(magic))
+	write(uint32(version))
+	write(uint32(len(ks.PrivateKeys) + len(ks.TrustedCertificates)))
+	for _, e := range ks.PrivateKeys {
+		protected, err := protectKey(e.PKCS8Key, passwordBytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not protect the key of %v: %v", e.Alias, err)
+		}
+		write(uint32(privateKeyTag))
+		writeUTF(e.Alias)
+		write(timestamp)
+		write(uint32(len(protected)))
+		buf.Write(protected)
+		write(uint32(len(e.Chain)))
+		for _, c := range e.Chain {
+			writeUTF(certificateType)
+			write(uint32(len(c)))
+			buf.Write(c)
+		}
+	}
+	for _, e := range ks.TrustedCertificates {
+		write(uint32(trustedCertificateTag))
+		writeUTF(e.Alias)
+		write(timestamp)
+		writeUTF(certificateType)
+		write(uint32(len(e.Certificate)))
+		buf.Write(e.Certificate)
+	}
+
+	buf.Write(digest(passwordBytes, buf.Bytes()))
+	return buf.Bytes(), nil
+}
+
+// digest returns the integrity digest of the keystore data
+func digest(passwordBytes, data []byte) []byte {
+	h := sha1.New() // #nosec
+	h.Write(passwordBytes)
+	h.Write([]byte(whitener))
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// protectKey returns the private key protected as the JKS provider does: it is
+// XORed with a key stream of chained SHA-1 digests of the password and a random
+// salt, followed by the digest of the password and the plain key
+func protectKey(plainKey, passwordBytes []byte) ([]byte, error) {
+	salt := make([]byte, sha1.Size)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	protected := append([]byte{}, salt...)
+	protected = append(protected, xorKeyStream(plainKey, salt, passwordBytes)...)
+	h := sha1.New() // #nosec
+	h.Write(passwordBytes)
+	h.Write(plainKey)
+	protected = append(protected, h.Sum(nil)...)
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  keyProtectorOID,
+			Parameters: asn1.RawValue{Tag: asn1.TagNull},
+		},
+		EncryptedData: protected,
+	})
+}
+
+// xorKeyStream XORs the data with the key stream of the salt and password, it both
+// protects and recovers a key
+func xorKeyStream(data, salt, passwordBytes []byte) []byte {
+	out := make([]byte, len(data))
+	d := salt
+	for offset := 0; offset < len(data); offset += sha1.Size {
+		h := sha1.New() // #nosec
+		h.Write(passwordBytes)
+		h.Write(d)
+		d = h.Sum(nil)
+		for i := 0; i < sha1.Size && offset+i < len(data); i++ {
+			out[offset+i] = data[offset+i] ^ d[i]
+		}
+	}
+	return out
+}
+
+// passwordBytes returns the password as the big endian UTF-16 code units of its
+// characters, as java does
+func passwordBytes(password string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(password)) {
+		b = append(b, byte(c>>8), byte(c))
+	}
+	return b
+}
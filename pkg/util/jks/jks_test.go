@@ -0,0 +1,100 @@
+package jks
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reader reads the fields of a keystore
+type reader struct {
+	t *testing.T
+	r *bytes.Reader
+}
+
+func (r reader) uint32() uint32 {
+	var v uint32
+	assert.Nil(r.t, binary.Read(r.r, binary.BigEndian, &v))
+	return v
+}
+
+func (r reader) bytes(n int) []byte {
+	b := make([]byte, n)
+	_, err := r.r.Read(b)
+	assert.Nil(r.t, err)
+	return b
+}
+
+func (r reader) utf() string {
+	var n uint16
+	assert.Nil(r.t, binary.Read(r.r, binary.BigEndian, &n))
+	return string(r.bytes(int(n)))
+}
+
+func TestEncode(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+
+	ks := &KeyStore{
+		PrivateKeys:         []PrivateKeyEntry{{Alias: "cassandra", PKCS8Key: pkcs8, Chain: [][]byte{[]byte("leaf"), []byte("ca")}}},
+		TrustedCertificates: []TrustedCertificateEntry{{Alias: "ca-0", Certificate: []byte("ca")}},
+	}
+	created := time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+	data, err := ks.Encode("changeit", created)
+	assert.Nil(t, err)
+
+	// the integrity digest covers the rest of the keystore
+	body, sum := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	assert.Equal(t, digest(passwordBytes("changeit"), body), sum)
+	assert.NotEqual(t, digest(passwordBytes("other"), body), sum)
+
+	r := reader{t: t, r: bytes.NewReader(body)}
+	assert.Equal(t, uint32(0xfeedfeed), r.uint32())
+	assert.Equal(t, uint32(2), r.uint32())
+	assert.Equal(t, uint32(2), r.uint32())
+
+	assert.Equal(t, uint32(1), r.uint32())
+	assert.Equal(t, "cassandra", r.utf())
+	assert.Equal(t, uint32(created.Unix()*1000>>32), r.uint32())
+	assert.Equal(t, uint32(created.Unix()*1000), r.uint32())
+	var info encryptedPrivateKeyInfo
+	_, err = asn1.Unmarshal(r.bytes(int(r.uint32())), &info)
+	assert.Nil(t, err)
+	assert.True(t, info.Algorithm.Algorithm.Equal(keyProtectorOID))
+	protected := info.EncryptedData
+	salt, encrypted, check := protected[:20], protected[20:len(protected)-20], protected[len(protected)-20:]
+	recovered := xorKeyStream(encrypted, salt, passwordBytes("changeit"))
+	assert.Equal(t, pkcs8, recovered)
+	h := sha1.New()
+	h.Write(passwordBytes("changeit"))
+	h.Write(recovered)
+	assert.Equal(t, h.Sum(nil), check)
+	assert.Equal(t, uint32(2), r.uint32())
+	for _, c := range []string{"leaf", "ca"} {
+		assert.Equal(t, "X.509", r.utf())
+		assert.Equal(t, c, string(r.bytes(int(r.uint32()))))
+	}
+
+	assert.Equal(t, uint32(2), r.uint32())
+	assert.Equal(t, "ca-0", r.utf())
+	r.bytes(8)
+	assert.Equal(t, "X.509", r.utf())
+	assert.Equal(t, "ca", string(r.bytes(int(r.uint32()))))
+	assert.Equal(t, 0, r.r.Len())
+}
+
+func TestPasswordBytes(t *testing.T) {
+	assert.Equal(t, []byte{0, 'a', 0, 'b'}, passwordBytes("ab"))
+	assert.Equal(t, []byte{0x20, 0xac}, passwordBytes("€"))
+}
@@ -0,0 +1,244 @@
+// Package pki implements a minimal certificate authority issuing the certificates
+// of the nodes, and the PEM parsing of certificates and keys
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	certificateBlockType = "CERTIFICATE"
+	privateKeyBlockType  = "PRIVATE KEY"
+
+	// clockSkew backdates the certificates, so they are valid on the nodes with a
+	// clock behind the one of the issuer
+	clockSkew = time.Hour
+)
+
+// CA is a certificate authority
+type CA struct {
+	Certificate *x509.Certificate
+	Key         crypto.Signer
+}
+
+// NewCA returns a new self-signed certificate authority
+func NewCA(commonName string, validity time.Duration, now time.Time) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template, err := newTemplate(commonName, validity, now)
+	if err != nil {
+		return nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Certificate: cert, Key: key}, nil
+}
+
+// LoadCA returns the certificate authority of the PEM certificate and key
+func LoadCA(certPEM, keyPEM []byte) (*CA, error) {
+	certs, err := ParseCertificates(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no CA certificate")
+	}
+	if !certs[0].IsCA {
+		return nil, fmt.Errorf("certificate %v is not a CA", certs[0].Subject)
+	}
+	key, err := ParsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if !KeyMatches(key, certs[0]) {
+		return nil, fmt.Errorf("private key does not match the certificate %v", certs[0].Subject)
+	}
+	return &CA{Certificate: certs[0], Key: key}, nil
+}
+
+// CertificatePEM returns the certificate of the CA in PEM
+func (ca *CA) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: certificateBlockType, Bytes: ca.Certificate.Raw})
+}
+
+// KeyPEM returns the private key of the CA in PKCS #8 PEM
+func (ca *CA) KeyPEM() ([]byte, error) {
+	return encodeKey(ca.Key)
+}
+
+// Issue returns a new certificate and its private key in PEM, for the DNS names. The
+// certificate authenticates both servers and clients, as the nodes are both
+func (ca *CA) Issue(commonName string, dnsNames []string, validity time.Duration, now time.Time) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := newTemplate(commonName, validity, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.DNSNames = dnsNames
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	if template.NotAfter.After(ca.Certificate.NotAfter) {
+		template.NotAfter = ca.Certificate.NotAfter
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, key.Public(), ca.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err = encodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: certificateBlockType, Bytes: der}), keyPEM, nil
+}
+
+// Expiring returns if the CA expires within renewBefore. The certificates it issues do
+// not outlive it, so renewing them would not extend their validity
+func (ca *CA) Expiring(renewBefore time.Duration, now time.Time) bool {
+	return now.Add(renewBefore).After(ca.Certificate.NotAfter)
+}
+
+// Renewal returns why the PEM certificate should be renewed, empty if it is valid: it
+// is not issued by the CA, its DNS names differ or it expires within renewBefore. A
+// certificate expiring is not renewed once the CA is expiring too
+func (ca *CA) Renewal(certPEM []byte, dnsNames []string, renewBefore time.Duration, now time.Time) string {
+	certs, err := ParseCertificates(certPEM)
+	if err != nil || len(certs) == 0 {
+		return "no valid certificate"
+	}
+	cert := certs[0]
+	if cert.CheckSignatureFrom(ca.Certificate) != nil {
+		return "not issued by the CA " + ca.Certificate.Subject.CommonName
+	}
+	if !equal(cert.DNSNames, dnsNames) {
+		return fmt.Sprintf("DNS names %v changed", cert.DNSNames)
+	}
+	if now.Add(renewBefore).After(cert.NotAfter) && !ca.Expiring(renewBefore, now) {
+		return "expires at " + cert.NotAfter.UTC().Format(time.RFC3339)
+	}
+	return ""
+}
+
+// ParseCertificates returns the certificates of the PEM data, skipping the other blocks
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs, nil
+		}
+		if block.Type != certificateBlockType {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+}
+
+// ParsePrivateKey returns the first private key of the PEM data, in PKCS #1, SEC 1
+// or PKCS #8
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no private key")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// KeyMatches returns if the private key is the one of the certificate
+func KeyMatches(key crypto.Signer, cert *x509.Certificate) bool {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		return ok && pub.N.Cmp(k.N) == 0 && pub.E == k.E
+	case *ecdsa.PrivateKey:
+		pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+		return ok && pub.X.Cmp(k.X) == 0 && pub.Y.Cmp(k.Y) == 0
+	default:
+		return false
+	}
+}
+
+// newTemplate returns the template of a certificate with a random serial number
+func newTemplate(commonName string, validity time.Duration, now time.Time) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-clockSkew).UTC(),
+		NotAfter:     now.Add(validity).UTC(),
+	}, nil
+}
+
+// encodeKey returns the private key in PKCS #8 PEM
+func encodeKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: privateKeyBlockType, Bytes: der}), nil
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package pki
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssue(t *testing.T) {
+	now := time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+	ca, err := NewCA("example-ca", 10*365*24*time.Hour, now)
+	assert.Nil(t, err)
+	assert.True(t, ca.Certificate.IsCA)
+
+	dnsNames := []string{"example-0.example-unready.default.svc.cluster.local", "example-0.example-unready"}
+	certPEM, keyPEM, err := ca.Issue(dnsNames[0], dnsNames, 365*24*time.Hour, now)
+	assert.Nil(t, err)
+
+	certs, err := ParseCertificates(certPEM)
+	assert.Nil(t, err)
+	assert.Len(t, certs, 1)
+	assert.Equal(t, dnsNames, certs[0].DNSNames)
+	assert.Equal(t, now.Add(365*24*time.Hour), certs[0].NotAfter)
+	assert.Equal(t, now.Add(-time.Hour), certs[0].NotBefore)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate)
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		DNSName:     dnsNames[1],
+		Roots:       roots,
+		CurrentTime: now,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	assert.Nil(t, err)
+
+	key, err := ParsePrivateKey(keyPEM)
+	assert.Nil(t, err)
+	assert.True(t, KeyMatches(key, certs[0]))
+	assert.False(t, KeyMatches(key, ca.Certificate))
+
+	// certificates do not outlive their CA
+	certPEM, _, err = ca.Issue(dnsNames[0], dnsNames, 20*365*24*time.Hour, now)
+	assert.Nil(t, err)
+	certs, _ = ParseCertificates(certPEM)
+	assert.Equal(t, ca.Certificate.NotAfter, certs[0].NotAfter)
+}
+
+func TestLoadCA(t *testing.T) {
+	now := time.Now()
+	ca, err := NewCA("example-ca", time.Hour, now)
+	assert.Nil(t, err)
+	keyPEM, err := ca.KeyPEM()
+	assert.Nil(t, err)
+
+	loaded, err := LoadCA(ca.CertificatePEM(), keyPEM)
+	assert.Nil(t, err)
+	assert.Equal(t, ca.Certificate.Raw, loaded.Certificate.Raw)
+
+	other, err := NewCA("other-ca", time.Hour, now)
+	assert.Nil(t, err)
+	_, err = LoadCA(other.CertificatePEM(), keyPEM)
+	assert.EqualError(t, err, "private key does not match the certificate CN=other-ca")
+
+	certPEM, leafKeyPEM, err := ca.Issue("node", nil, time.Hour, now)
+	assert.Nil(t, err)
+	_, err = LoadCA(certPEM, leafKeyPEM)
+	assert.EqualError(t, err, "certificate CN=node is not a CA")
+}
+
+func TestRenewal(t *testing.T) {
+	now := time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+	ca, err := NewCA("example-ca", 10*365*24*time.Hour, now)
+	assert.Nil(t, err)
+	dnsNames := []string{"example-0.example-unready"}
+	certPEM, _, err := ca.Issue(dnsNames[0], dnsNames, 365*24*time.Hour, now)
+	assert.Nil(t, err)
+
+	renewBefore := 30 * 24 * time.Hour
+	assert.Equal(t, "", ca.Renewal(certPEM, dnsNames, renewBefore, now))
+	assert.Equal(t, "", ca.Renewal(certPEM, dnsNames, renewBefore, now.Add(334*24*time.Hour)))
+	assert.Equal(t, "expires at 2019-06-01T10:00:00Z", ca.Renewal(certPEM, dnsNames, renewBefore, now.Add(336*24*time.Hour)))
+	assert.Equal(t, "DNS names [example-0.example-unready] changed", ca.Renewal(certPEM, []string{"other"}, renewBefore, now))
+	assert.Equal(t, "no valid certificate", ca.Renewal(nil, dnsNames, renewBefore, now))
+
+	other, err := NewCA("other-ca", 10*365*24*time.Hour, now)
+	assert.Nil(t, err)
+	assert.Equal(t, "not issued by the CA other-ca", other.Renewal(certPEM, dnsNames, renewBefore, now))
+}
+
+func TestRenewalWithExpiringCA(t *testing.T) {
+	now := time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+	renewBefore := 30 * 24 * time.Hour
+	ca, err := NewCA("example-ca", 20*24*time.Hour, now)
+	assert.Nil(t, err)
+	assert.True(t, ca.Expiring(renewBefore, now))
+	assert.False(t, ca.Expiring(renewBefore, now.Add(-11*24*time.Hour)))
+
+	// the certificate is capped at the expiry of the CA, within the renew window
+	dnsNames := []string{"example-0.example-unready"}
+	certPEM, _, err := ca.Issue(dnsNames[0], dnsNames, 365*24*time.Hour, now)
+	assert.Nil(t, err)
+	assert.Equal(t, "", ca.Renewal(certPEM, dnsNames, renewBefore, now))
+	assert.Equal(t, "", ca.Renewal(certPEM, dnsNames, renewBefore, now.Add(19*24*time.Hour)))
+	// the other reasons still renew it
+	assert.Equal(t, "DNS names [example-0.example-unready] changed", ca.Renewal(certPEM, []string{"other"}, renewBefore, now))
+}
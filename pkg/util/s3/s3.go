@@ -0,0 +1,287 @@
+// Package s3 is a minimal client of the S3 API, enough to store backups in
+// Amazon S3 or in any S3 compatible object storage such as MinIO. Requests
+// are signed with AWS Signature Version 4 and buckets are addressed in path
+// style, so no DNS set up is needed for the bucket names
+package s3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRegion is the region used when none is given
+	DefaultRegion = "us-east-1"
+	// RequestTimeout bounds a whole request, including the upload or download of
+	// the object. It is long enough for the largest SSTables
+	RequestTimeout = time.Hour
+	// connectTimeout bounds the connection to the object storage
+	connectTimeout = 30 * time.Second
+	// responseHeaderTimeout bounds the wait for the response once the request is sent
+	responseHeaderTimeout = time.Minute
+)
+
+// Client is a client of an S3 compatible object storage
+type Client struct {
+	endpoint   *url.URL
+	region     string
+	accessKey  string
+	secretKey  string
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// Object is an object listed in a bucket
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Error is an error response of the object storage
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("s3: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsNotFound returns if the error is a not found error response
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// New returns a client of the object storage at the endpoint, for example
+// https://s3.amazonaws.com or http://minio:9000
+func New(endpoint, region, accessKey, secretKey string) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid endpoint %q: the scheme must be http or https", endpoint)
+	}
+	if len(region) == 0 {
+		region = DefaultRegion
+	}
+	return &Client{
+		endpoint:   u,
+		region:     region,
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		httpClient: newHTTPClient(),
+		now:        time.Now,
+	}, nil
+}
+
+// newHTTPClient returns an HTTP client whose requests fail rather than hang when
+// the object storage does not answer
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: RequestTimeout,
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext,
+			TLSHandshakeTimeout:   connectTimeout,
+			ResponseHeaderTimeout: responseHeaderTimeout,
+			ExpectContinueTimeout: time.Second,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConns:          10,
+		},
+	}
+}
+
+// PutObject stores size bytes read from body in the object
+func (c *Client) PutObject(bucket, key string, body io.Reader, size int64) error {
+	resp, err := c.do(http.MethodPut, bucket, key, nil, body, size)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// GetObject returns the content of the object, the caller must close it
+func (c *Client) GetObject(bucket, key string) (io.ReadCloser, error) {
+	resp, err := c.do(http.MethodGet, bucket, key, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// DeleteObject deletes the object, deleting a missing object is not an error
+func (c *Client) DeleteObject(bucket, key string) error {
+	resp, err := c.do(http.MethodDelete, bucket, key, nil, nil, 0)
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// ListObjects returns all the objects whose key starts with prefix
+func (c *Client) ListObjects(bucket, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if len(token) > 0 {
+			query.Set("continuation-token", token)
+		}
+
+		var result struct {
+			Contents []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err := c.doXML(http.MethodGet, bucket, "", query, nil, &result)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, o := range result.Contents {
+			objects = append(objects, Object{Key: o.Key, Size: o.Size, LastModified: o.LastModified})
+		}
+		if !result.IsTruncated || len(result.NextContinuationToken) == 0 {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// CreateMultipartUpload starts a multipart upload of the object and returns its ID
+func (c *Client) CreateMultipartUpload(bucket, key string) (string, error) {
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err := c.doXML(http.MethodPost, bucket, key, url.Values{"uploads": {""}}, nil, &result)
+	return result.UploadID, err
+}
+
+// UploadPart uploads size bytes read from body as the part with the given number,
+// starting at 1, and returns its ETag
+func (c *Client) UploadPart(bucket, key, uploadID string, number int, body io.Reader, size int64) (string, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+	resp, err := c.do(http.MethodPut, bucket, key, query, body, size)
+	if err != nil {
+		return "", err
+	}
+	return resp.Header.Get("ETag"), resp.Body.Close()
+}
+
+// CompleteMultipartUpload assembles the uploaded parts, given their ETags in order
+func (c *Client) CompleteMultipartUpload(bucket, key, uploadID string, etags []string) error {
+	type part struct {
+		PartNumber int
+		ETag       string
+	}
+	var complete struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}
+	for i, etag := range etags {
+		complete.Parts = append(complete.Parts, part{PartNumber: i + 1, ETag: etag})
+	}
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+
+	// errors can be returned after a 200 OK response
+	var result struct {
+		XMLName xml.Name
+		Code    string
+		Message string
+	}
+	err = c.doXML(http.MethodPost, bucket, key, url.Values{"uploadId": {uploadID}}, body, &result)
+	if err != nil {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		return &Error{StatusCode: http.StatusOK, Code: result.Code, Message: result.Message}
+	}
+	return nil
+}
+
+// AbortMultipartUpload aborts a multipart upload and frees its uploaded parts
+func (c *Client) AbortMultipartUpload(bucket, key, uploadID string) error {
+	resp, err := c.do(http.MethodDelete, bucket, key, url.Values{"uploadId": {uploadID}}, nil, 0)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// doXML sends a request with an in memory body and decodes the XML response into v
+func (c *Client) doXML(method, bucket, key string, query url.Values, body []byte, v interface{}) error {
+	resp, err := c.do(method, bucket, key, query, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return xml.NewDecoder(resp.Body).Decode(v)
+}
+
+// do sends a signed request, responses other than 2xx are returned as an *Error
+func (c *Client) do(method, bucket, key string, query url.Values, body io.Reader, size int64) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket
+	if len(key) > 0 {
+		u.Path += "/" + key
+	}
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+
+	if body == nil {
+		body = http.NoBody
+	}
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	c.sign(req, unsignedPayload, c.now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	e := &Error{StatusCode: resp.StatusCode, Code: http.StatusText(resp.StatusCode)}
+	data, _ := ioutil.ReadAll(resp.Body)
+	var result struct {
+		Code    string
+		Message string
+	}
+	if xml.Unmarshal(data, &result) == nil && len(result.Code) > 0 {
+		e.Code = result.Code
+		e.Message = result.Message
+	}
+	return nil, e
+}
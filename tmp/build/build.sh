@@ -16,3 +16,6 @@ REPO_PATH="github.com/camilocot/cassandra-operator"
 BUILD_PATH="${REPO_PATH}/cmd/${PROJECT_NAME}"
 echo "building "${PROJECT_NAME}"..."
 GOOS=linux GOARCH=amd64 CGO_ENABLED=0 go build -o ${BIN_DIR}/${PROJECT_NAME} $BUILD_PATH
+AGENT_NAME="cassandra-backup-agent"
+echo "building "${AGENT_NAME}"..."
+GOOS=linux GOARCH=amd64 CGO_ENABLED=0 go build -o ${BIN_DIR}/${AGENT_NAME} ${REPO_PATH}/cmd/${AGENT_NAME}